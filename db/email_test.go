@@ -0,0 +1,65 @@
+package db
+
+import "testing"
+
+func TestEmailVerification(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			verified := func(want bool) {
+				t.Helper()
+				email, ok, err := store.GetEmail("alice")
+				if err != nil || email != "alice@example.com" || ok != want {
+					t.Fatalf("GetEmail = %q, %t, %v, want alice@example.com, %t", email, ok, err, want)
+				}
+			}
+
+			verified(false)
+			verification, err := store.CreateEmailVerification("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.CreateEmailVerification("alice"); err != ErrVerificationTooSoon {
+				t.Fatalf("second CreateEmailVerification = %v, want ErrVerificationTooSoon", err)
+			}
+			if _, err := store.ConsumeEmailVerification("not-a-token"); err != ErrVerificationNotFound {
+				t.Fatalf("ConsumeEmailVerification of an unknown token = %v, want ErrVerificationNotFound", err)
+			}
+
+			used, err := store.ConsumeEmailVerification(verification.Token)
+			if err != nil || used.Username != "alice" || used.Email != "alice@example.com" {
+				t.Fatalf("ConsumeEmailVerification = %+v, %v", used, err)
+			}
+			verified(true)
+			// each link works once
+			if _, err := store.ConsumeEmailVerification(verification.Token); err != ErrVerificationNotFound {
+				t.Fatalf("reusing the token = %v, want ErrVerificationNotFound", err)
+			}
+
+			if err := store.CreateUser("carol", "", "Plum-Kettle-93"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.CreateEmailVerification("carol"); err != ErrNoEmail {
+				t.Fatalf("CreateEmailVerification without an address = %v, want ErrNoEmail", err)
+			}
+		})
+	}
+}
+
+func TestEmailVerificationExpiry(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			verification, err := store.CreateEmailVerification("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expireTokens(t, store)
+
+			if _, err := store.ConsumeEmailVerification(verification.Token); err != ErrVerificationNotFound {
+				t.Fatalf("ConsumeEmailVerification of an expired token = %v, want ErrVerificationNotFound", err)
+			}
+			if _, ok, _ := store.GetEmail("alice"); ok {
+				t.Fatal("an expired link verified the address")
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMagicLink(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreateMagicLink("nobody@example.com"); err != ErrUserNotFound {
+				t.Fatalf("CreateMagicLink of an unknown address = %v, want ErrUserNotFound", err)
+			}
+
+			link, err := store.CreateMagicLink("alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if link.Username != "alice" || link.Token == "" || link.BrowserToken == "" {
+				t.Fatalf("CreateMagicLink = %+v", link)
+			}
+			if left := time.Until(link.ExpiresAt); left <= MagicLinkLifetime-time.Minute || left > MagicLinkLifetime {
+				t.Fatalf("link expires in %s, want %s", left, MagicLinkLifetime)
+			}
+
+			// another browser neither logs in nor uses the link up
+			if _, err := store.ConsumeMagicLink(link.Token, "another-browser"); err != ErrMagicLinkNotFound {
+				t.Fatalf("ConsumeMagicLink from another browser = %v, want ErrMagicLinkNotFound", err)
+			}
+			if _, err := store.ConsumeMagicLink(link.Token, ""); err != ErrMagicLinkNotFound {
+				t.Fatalf("ConsumeMagicLink without a browser token = %v, want ErrMagicLinkNotFound", err)
+			}
+
+			used, err := store.ConsumeMagicLink(link.Token, link.BrowserToken)
+			if err != nil || used.Username != "alice" || used.Email != "alice@example.com" {
+				t.Fatalf("ConsumeMagicLink = %+v, %v", used, err)
+			}
+			if _, ok, _ := store.GetEmail("alice"); !ok {
+				t.Fatal("using the link did not verify the address")
+			}
+			// each link works once
+			if _, err := store.ConsumeMagicLink(link.Token, link.BrowserToken); err != ErrMagicLinkNotFound {
+				t.Fatalf("reusing the link = %v, want ErrMagicLinkNotFound", err)
+			}
+		})
+	}
+}
+
+func TestMagicLinkReplaced(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first, err := store.CreateMagicLink("alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := store.CreateMagicLink("alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.ConsumeMagicLink(first.Token, first.BrowserToken); err != ErrMagicLinkNotFound {
+				t.Fatalf("ConsumeMagicLink of a replaced link = %v, want ErrMagicLinkNotFound", err)
+			}
+			if _, err := store.ConsumeMagicLink(second.Token, second.BrowserToken); err != nil {
+				t.Fatalf("ConsumeMagicLink of the newest link: %v", err)
+			}
+		})
+	}
+}
+
+func TestMagicLinkExpiry(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			link, err := store.CreateMagicLink("alice@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expireTokens(t, store)
+
+			if _, err := store.ConsumeMagicLink(link.Token, link.BrowserToken); err != ErrMagicLinkNotFound {
+				t.Fatalf("ConsumeMagicLink of an expired link = %v, want ErrMagicLinkNotFound", err)
+			}
+			if _, ok, _ := store.GetEmail("alice"); ok {
+				t.Fatal("an expired link verified the address")
+			}
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package db
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func TestMain(m *testing.M) {
+	go logs.ProcessLogs()
+	// the lowest cost keeps creating users fast
+	err := utils.SetPasswordHasher(utils.BcryptHasher{Cost: 4})
+	if err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
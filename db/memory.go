@@ -0,0 +1,448 @@
+package db
+
+import (
+	"crypto/subtle"
+	"sync"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+// memoryUser mirrors a row of tbl_web_auth_demo.
+type memoryUser struct {
+	id           int64
+	hashPassword string
+	totpSecret   string
+	totpEnabled  bool
+	totpLastStep int64
+
+	email           string
+	emailVerifiedAt time.Time
+}
+
+/*
+MemoryStore is a Store implementation that keeps everything in process
+memory. It is safe for concurrent use and is intended for local development
+and tests; all data is lost when the process exits.
+*/
+type MemoryStore struct {
+	mu            sync.RWMutex
+	users         map[string]*memoryUser
+	lastUserID    int64               // id given to the newest user, like the SERIAL column
+	sessions      map[string]*Session // session ID -> session
+	sessionTokens map[string]string   // session token hash -> session ID
+
+	challenges      map[string]*MFAChallenge   // challenge ID -> challenge
+	challengeTokens map[string]string          // challenge token hash -> challenge ID
+	recoveryCodes   map[string]map[string]bool // username -> unused recovery code hashes
+
+	credentials map[string]*WebAuthnCredential // credential ID -> credential
+	ceremonies  map[string]*WebAuthnCeremony   // ceremony token hash -> ceremony
+
+	passwordResets     map[string]*PasswordReset     // reset token hash -> reset
+	emailVerifications map[string]*EmailVerification // verification token hash -> verification
+	magicLinks         map[string]*MagicLink         // link token hash -> link, holding the browser token hash
+
+	loginFailures map[string]*loginFailures // username -> failed logins in a row
+
+	roles     map[string]map[string]bool // role -> permissions
+	userRoles map[string]map[string]bool // username -> roles
+
+	revocations map[string]*Revocation // kind:subject -> revoked stateless session or user
+
+	lastSweep time.Time // when expired entries were last removed, see sweepExpiredLocked
+}
+
+// memorySweepInterval is how often the MemoryStore removes expired entries
+// that were never looked up again.
+const memorySweepInterval = time.Minute
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:         make(map[string]*memoryUser),
+		sessions:      make(map[string]*Session),
+		sessionTokens: make(map[string]string),
+
+		challenges:      make(map[string]*MFAChallenge),
+		challengeTokens: make(map[string]string),
+		recoveryCodes:   make(map[string]map[string]bool),
+
+		credentials: make(map[string]*WebAuthnCredential),
+		ceremonies:  make(map[string]*WebAuthnCeremony),
+
+		passwordResets:     make(map[string]*PasswordReset),
+		emailVerifications: make(map[string]*EmailVerification),
+		magicLinks:         make(map[string]*MagicLink),
+
+		loginFailures: make(map[string]*loginFailures),
+
+		roles:     defaultRoles(),
+		userRoles: make(map[string]map[string]bool),
+
+		revocations: make(map[string]*Revocation),
+
+		lastSweep: time.Now(),
+	}
+}
+
+// Close is a no-op for the in-memory store.
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+/*
+CreateUser stores a new user with the provided username, email address and
+password. The password is hashed before being stored. It returns an error if
+the username or email address is already taken, if the username looks like
+a taken one or if hashing the password fails.
+
+Returns:
+
+- error: An error if the user cannot be created.
+*/
+func (s *MemoryStore) CreateUser(username, email, password string) error {
+	hashedPwd, err := utils.HashedPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; ok {
+		return ErrUserExists
+	}
+	skeleton := validation.UsernameSkeleton(username)
+	for name := range s.users {
+		if validation.UsernameSkeleton(name) == skeleton {
+			return ErrUsernameLookAlike
+		}
+	}
+	if email != "" {
+		for _, user := range s.users {
+			if user.email == email {
+				return ErrEmailExists
+			}
+		}
+	}
+	s.lastUserID++
+	s.users[username] = &memoryUser{id: s.lastUserID, hashPassword: hashedPwd, email: email}
+	return nil
+}
+
+/*
+AuthenticateUser checks if the provided username and password match the
+stored credentials.
+
+Returns:
+
+- bool: True if the credentials are correct, otherwise false.
+
+- error: An error if the user does not exist or the password is wrong.
+*/
+func (s *MemoryStore) AuthenticateUser(username, password string) (bool, error) {
+	s.mu.RLock()
+	user, ok := s.users[username]
+	var hashedPassword string
+	if ok {
+		hashedPassword = user.hashPassword
+	}
+	s.mu.RUnlock()
+
+	if !ok {
+		// spend as long as for a real user, so timing does not tell them apart
+		utils.VerifyDummyPassword(password)
+		return false, ErrUserNotFound
+	}
+	ok, rehash := utils.VerifyPassword(password, hashedPassword)
+	if !ok {
+		return false, ErrInvalidPassword
+	}
+
+	// upgrade hashes made with an old algorithm or old parameters
+	if rehash {
+		newHash, err := utils.HashedPassword(password)
+		if err != nil {
+			return true, nil
+		}
+		s.mu.Lock()
+		if user.hashPassword == hashedPassword {
+			user.hashPassword = newHash
+		}
+		s.mu.Unlock()
+	}
+	return true, nil
+}
+
+/*
+UpdatePassword replaces the user's password, hashing it before it is stored.
+
+Returns:
+
+- error: ErrUserNotFound if the user does not exist, or an error if hashing
+the password fails.
+*/
+func (s *MemoryStore) UpdatePassword(username, password string) error {
+	hashedPwd, err := utils.HashedPassword(password)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.hashPassword = hashedPwd
+	return nil
+}
+
+// ListPepperKeyIDs returns the pepper key IDs recorded in the stored
+// password hashes.
+func (s *MemoryStore) ListPepperKeyIDs() ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, user := range s.users {
+		if id := utils.PepperKeyID(user.hashPassword); id != "" {
+			seen[id] = true
+		}
+	}
+	return sortedKeys(seen), nil
+}
+
+// GetEmail returns the user's email address and whether it is verified.
+func (s *MemoryStore) GetEmail(username string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return "", false, ErrUserNotFound
+	}
+	return user.email, !user.emailVerifiedAt.IsZero(), nil
+}
+
+// GetUserID returns the id the user was given when created.
+func (s *MemoryStore) GetUserID(username string) (int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+	return user.id, nil
+}
+
+/*
+CreateSession issues a new session for the given user with fresh session and
+CSRF tokens, valid for 24 hours. Existing sessions of the user are left
+untouched.
+
+Returns:
+
+- Session: The new session, including its session and CSRF tokens.
+
+- error: An error if the user does not exist.
+*/
+func (s *MemoryStore) CreateSession(username, ipAddress, userAgent string) (Session, error) {
+	now := time.Now().UTC()
+	session := Session{
+		ID:         utils.GenerateToken(16),
+		Token:      utils.GenerateToken(32),
+		CSRFToken:  utils.GenerateToken(32),
+		Username:   username,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(sessionLifetime),
+		LastSeenAt: now,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; !ok {
+		return Session{}, ErrUserNotFound
+	}
+	s.sweepExpiredLocked(now)
+	// keep only hashes of the tokens, as the SQL stores do
+	stored := session
+	stored.Token = utils.HashToken(session.Token)
+	stored.CSRFToken = utils.HashToken(session.CSRFToken)
+	s.sessions[session.ID] = &stored
+	s.sessionTokens[stored.Token] = session.ID
+
+	return session, nil
+}
+
+/*
+GetSession retrieves the session holding the given session token. Expired
+sessions are deleted and reported as not found.
+
+Returns:
+
+- Session: The session the token belongs to. CSRFToken is left empty as only
+its hash is kept.
+
+- error: ErrSessionNotFound if no unexpired session holds the token.
+*/
+func (s *MemoryStore) GetSession(sessionToken string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessionID, ok := s.sessionTokens[utils.HashToken(sessionToken)]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	session := s.sessions[sessionID]
+
+	now := time.Now().UTC()
+	if now.After(session.ExpiresAt) {
+		s.deleteSessionLocked(sessionID)
+		return Session{}, ErrSessionNotFound
+	}
+	session.LastSeenAt = now
+
+	found := *session
+	found.Token = sessionToken
+	found.CSRFToken = ""
+	if user, ok := s.users[session.Username]; ok {
+		found.UserID = user.id
+	}
+	return found, nil
+}
+
+/*
+ValidateCSRFToken checks if the given CSRF token was issued with the given
+session.
+
+Returns:
+
+- bool: True if the CSRF token is valid, otherwise false.
+
+- error: ErrSessionNotFound if the session does not exist.
+*/
+func (s *MemoryStore) ValidateCSRFToken(sessionID, csrfToken string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return false, ErrSessionNotFound
+	}
+	return subtle.ConstantTimeCompare([]byte(utils.HashToken(csrfToken)), []byte(session.CSRFToken)) == 1, nil
+}
+
+/*
+RotateCSRFToken replaces the CSRF token of the session with a new one.
+
+Returns:
+
+- string: The new CSRF token.
+
+- error: ErrSessionNotFound if the session does not exist.
+*/
+func (s *MemoryStore) RotateCSRFToken(sessionID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return "", ErrSessionNotFound
+	}
+	csrfToken := utils.GenerateToken(32)
+	session.CSRFToken = utils.HashToken(csrfToken)
+	return csrfToken, nil
+}
+
+/*
+DeleteSession removes a single session. Other sessions of the same user stay
+valid.
+
+Returns:
+
+- error: Always nil; deleting an unknown session is not an error.
+*/
+func (s *MemoryStore) DeleteSession(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteSessionLocked(sessionID)
+	return nil
+}
+
+// DeleteUserSessions removes every session of the user.
+func (s *MemoryStore) DeleteUserSessions(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for sessionID, session := range s.sessions {
+		if session.Username == username {
+			s.deleteSessionLocked(sessionID)
+		}
+	}
+	return nil
+}
+
+/*
+sweepExpiredLocked removes expired sessions, MFA challenges, WebAuthn
+ceremonies and emailed tokens at most once per memorySweepInterval. Lookups
+already drop the expired entries they find; this catches the ones nobody
+looks up again, so the maps do not grow without bound. The caller must hold
+s.mu for writing.
+*/
+func (s *MemoryStore) sweepExpiredLocked(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for sessionID, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			s.deleteSessionLocked(sessionID)
+		}
+	}
+	for challengeID, challenge := range s.challenges {
+		if now.After(challenge.ExpiresAt) {
+			s.deleteChallengeLocked(challengeID)
+		}
+	}
+	for token, ceremony := range s.ceremonies {
+		if now.After(ceremony.ExpiresAt) {
+			delete(s.ceremonies, token)
+		}
+	}
+	for token, reset := range s.passwordResets {
+		if now.After(reset.ExpiresAt) {
+			delete(s.passwordResets, token)
+		}
+	}
+	for token, verification := range s.emailVerifications {
+		if now.After(verification.ExpiresAt) {
+			delete(s.emailVerifications, token)
+		}
+	}
+	for token, link := range s.magicLinks {
+		if now.After(link.ExpiresAt) {
+			delete(s.magicLinks, token)
+		}
+	}
+}
+
+// deleteSessionLocked removes a session and its token index entry. The
+// caller must hold s.mu for writing.
+func (s *MemoryStore) deleteSessionLocked(sessionID string) {
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return
+	}
+	delete(s.sessionTokens, session.Token)
+	delete(s.sessions, sessionID)
+}
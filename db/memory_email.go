@@ -0,0 +1,74 @@
+package db
+
+import (
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// CreateEmailVerification issues a token for the user's current email
+// address, replacing any earlier one, unless the last one is under a minute
+// old.
+func (s *MemoryStore) CreateEmailVerification(username string) (EmailVerification, error) {
+	now := time.Now().UTC()
+	token := utils.GenerateToken(32)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepExpiredLocked(now)
+
+	user, ok := s.users[username]
+	if !ok {
+		return EmailVerification{}, ErrUserNotFound
+	}
+	if user.email == "" {
+		return EmailVerification{}, ErrNoEmail
+	}
+	for tokenHash, existing := range s.emailVerifications {
+		if existing.Username != username {
+			continue
+		}
+		if now.Sub(existing.CreatedAt) < emailVerificationInterval {
+			return EmailVerification{}, ErrVerificationTooSoon
+		}
+		delete(s.emailVerifications, tokenHash)
+	}
+
+	verification := EmailVerification{
+		Token:     token,
+		Username:  username,
+		Email:     user.email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailVerificationLifetime),
+	}
+	stored := verification
+	stored.Token = ""
+	s.emailVerifications[utils.HashToken(token)] = &stored
+	return verification, nil
+}
+
+// ConsumeEmailVerification deletes an unexpired verification and marks its
+// address as verified, as long as the user still has that address.
+func (s *MemoryStore) ConsumeEmailVerification(token string) (EmailVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenHash := utils.HashToken(token)
+	verification, ok := s.emailVerifications[tokenHash]
+	if !ok {
+		return EmailVerification{}, ErrVerificationNotFound
+	}
+	delete(s.emailVerifications, tokenHash)
+
+	now := time.Now().UTC()
+	user, ok := s.users[verification.Username]
+	if !ok || user.email != verification.Email || now.After(verification.ExpiresAt) {
+		return EmailVerification{}, ErrVerificationNotFound
+	}
+	user.emailVerifiedAt = now
+
+	found := *verification
+	found.Token = token
+	return found, nil
+}
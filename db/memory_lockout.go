@@ -0,0 +1,54 @@
+package db
+
+import "time"
+
+// loginFailures is the failed login count of one username.
+type loginFailures struct {
+	count      int
+	lastFailed time.Time
+}
+
+// GetLoginFailures returns the failed logins in a row for the username and
+// when the last one failed.
+func (s *MemoryStore) GetLoginFailures(username string) (int, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	failures, ok := s.loginFailures[username]
+	if !ok {
+		return 0, time.Time{}, nil
+	}
+	return failures.count, failures.lastFailed, nil
+}
+
+// RecordLoginFailure counts a failed login, starting over if the previous
+// one is older than since, and forgets counts of other usernames that are.
+func (s *MemoryStore) RecordLoginFailure(username string, since time.Time) (int, error) {
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, failures := range s.loginFailures {
+		if failures.lastFailed.Before(since) {
+			delete(s.loginFailures, name)
+		}
+	}
+	failures, ok := s.loginFailures[username]
+	if !ok {
+		failures = &loginFailures{}
+		s.loginFailures[username] = failures
+	}
+	failures.count++
+	failures.lastFailed = now
+	return failures.count, nil
+}
+
+// ResetLoginFailures forgets the failed logins of the username.
+func (s *MemoryStore) ResetLoginFailures(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginFailures, username)
+	return nil
+}
@@ -0,0 +1,72 @@
+package db
+
+import (
+	"crypto/subtle"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// CreateMagicLink issues a login link for the user with the email address,
+// replacing any earlier one, and keeps only hashes of its tokens.
+func (s *MemoryStore) CreateMagicLink(email string) (MagicLink, error) {
+	link := MagicLink{
+		Token:        utils.GenerateToken(32),
+		BrowserToken: utils.GenerateToken(32),
+		Email:        email,
+		ExpiresAt:    time.Now().UTC().Add(MagicLinkLifetime),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepExpiredLocked(time.Now().UTC())
+
+	for username, user := range s.users {
+		if user.email == email {
+			link.Username = username
+			break
+		}
+	}
+	if link.Username == "" {
+		return MagicLink{}, ErrUserNotFound
+	}
+	for tokenHash, existing := range s.magicLinks {
+		if existing.Username == link.Username {
+			delete(s.magicLinks, tokenHash)
+		}
+	}
+
+	stored := link
+	stored.Token = ""
+	stored.BrowserToken = utils.HashToken(link.BrowserToken)
+	s.magicLinks[utils.HashToken(link.Token)] = &stored
+	return link, nil
+}
+
+// ConsumeMagicLink returns and deletes an unexpired link presented with its
+// browser token, and marks the address it was sent to as verified.
+func (s *MemoryStore) ConsumeMagicLink(token, browserToken string) (MagicLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenHash := utils.HashToken(token)
+	link, ok := s.magicLinks[tokenHash]
+	if !ok || subtle.ConstantTimeCompare([]byte(utils.HashToken(browserToken)), []byte(link.BrowserToken)) != 1 {
+		return MagicLink{}, ErrMagicLinkNotFound
+	}
+	delete(s.magicLinks, tokenHash)
+
+	now := time.Now().UTC()
+	if now.After(link.ExpiresAt) {
+		return MagicLink{}, ErrMagicLinkNotFound
+	}
+	if user, ok := s.users[link.Username]; ok && user.email == link.Email && user.emailVerifiedAt.IsZero() {
+		user.emailVerifiedAt = now
+	}
+
+	found := *link
+	found.Token = token
+	found.BrowserToken = browserToken
+	return found, nil
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// SetTOTPSecret stores a new, not yet enabled TOTP secret for the user.
+func (s *MemoryStore) SetTOTPSecret(username, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return ErrUserNotFound
+	}
+	user.totpSecret = secret
+	user.totpEnabled = false
+	user.totpLastStep = 0
+	return nil
+}
+
+// EnableTOTP turns on TOTP for a user who has a TOTP secret.
+func (s *MemoryStore) EnableTOTP(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok || user.totpSecret == "" {
+		return ErrUserNotFound
+	}
+	user.totpEnabled = true
+	return nil
+}
+
+// GetTOTP returns the user's TOTP secret and whether it is enabled.
+func (s *MemoryStore) GetTOTP(username string) (string, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return "", false, ErrUserNotFound
+	}
+	return user.totpSecret, user.totpEnabled, nil
+}
+
+// UseTOTPStep records a used time step, returning false if it was not newer
+// than the last one used.
+func (s *MemoryStore) UseTOTPStep(username string, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[username]
+	if !ok {
+		return false, ErrUserNotFound
+	}
+	if step <= user.totpLastStep {
+		return false, nil
+	}
+	user.totpLastStep = step
+	return true, nil
+}
+
+// CreateMFAChallenge starts a pending second factor login for the user,
+// keeping only the hash of its token.
+func (s *MemoryStore) CreateMFAChallenge(username string) (MFAChallenge, error) {
+	challenge := MFAChallenge{
+		ID:        utils.GenerateToken(16),
+		Token:     utils.GenerateToken(32),
+		Username:  username,
+		ExpiresAt: time.Now().UTC().Add(mfaChallengeLifetime),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepExpiredLocked(time.Now().UTC())
+
+	if _, ok := s.users[username]; !ok {
+		return MFAChallenge{}, ErrUserNotFound
+	}
+	stored := challenge
+	stored.Token = utils.HashToken(challenge.Token)
+	s.challenges[challenge.ID] = &stored
+	s.challengeTokens[stored.Token] = challenge.ID
+	return challenge, nil
+}
+
+// GetMFAChallenge looks up an unexpired challenge by its token.
+func (s *MemoryStore) GetMFAChallenge(token string) (MFAChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challengeID, ok := s.challengeTokens[utils.HashToken(token)]
+	if !ok {
+		return MFAChallenge{}, ErrChallengeNotFound
+	}
+	challenge := s.challenges[challengeID]
+	if time.Now().After(challenge.ExpiresAt) {
+		s.deleteChallengeLocked(challengeID)
+		return MFAChallenge{}, ErrChallengeNotFound
+	}
+
+	found := *challenge
+	found.Token = token
+	return found, nil
+}
+
+// CountMFAChallengeAttempt counts a code entered for the challenge and
+// returns the total.
+func (s *MemoryStore) CountMFAChallengeAttempt(challengeID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[challengeID]
+	if !ok {
+		return 0, ErrChallengeNotFound
+	}
+	challenge.Attempts++
+	return challenge.Attempts, nil
+}
+
+// DeleteMFAChallenge removes a challenge.
+func (s *MemoryStore) DeleteMFAChallenge(challengeID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.deleteChallengeLocked(challengeID)
+	return nil
+}
+
+// deleteChallengeLocked removes a challenge and its token index entry. The
+// caller must hold s.mu for writing.
+func (s *MemoryStore) deleteChallengeLocked(challengeID string) {
+	challenge, ok := s.challenges[challengeID]
+	if !ok {
+		return
+	}
+	delete(s.challengeTokens, challenge.Token)
+	delete(s.challenges, challengeID)
+}
@@ -0,0 +1,44 @@
+package db
+
+import "github.com/Bevs-n-Devs/WebAuthentication/utils"
+
+// ReplaceRecoveryCodes swaps the user's recovery codes for the hashes of the
+// new ones.
+func (s *MemoryStore) ReplaceRecoveryCodes(username string, codes []string) error {
+	hashes := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		hashes[utils.HashToken(utils.NormalizeRecoveryCode(code))] = true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; !ok {
+		return ErrUserNotFound
+	}
+	s.recoveryCodes[username] = hashes
+	return nil
+}
+
+// UseRecoveryCode spends an unused recovery code.
+func (s *MemoryStore) UseRecoveryCode(username, code string) (bool, error) {
+	codeHash := utils.HashToken(utils.NormalizeRecoveryCode(code))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hashes := s.recoveryCodes[username]
+	if !hashes[codeHash] {
+		return false, nil
+	}
+	delete(hashes, codeHash)
+	return true, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes the user has.
+func (s *MemoryStore) CountRecoveryCodes(username string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.recoveryCodes[username]), nil
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// CreatePasswordReset issues a reset token for the user, replacing any
+// earlier one, and keeps only the hash of the token.
+func (s *MemoryStore) CreatePasswordReset(username string) (PasswordReset, error) {
+	reset := PasswordReset{
+		Token:     utils.GenerateToken(32),
+		Username:  username,
+		ExpiresAt: time.Now().UTC().Add(passwordResetLifetime),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepExpiredLocked(time.Now().UTC())
+
+	if _, ok := s.users[username]; !ok {
+		return PasswordReset{}, ErrUserNotFound
+	}
+	for tokenHash, existing := range s.passwordResets {
+		if existing.Username == username {
+			delete(s.passwordResets, tokenHash)
+		}
+	}
+	stored := reset
+	stored.Token = ""
+	s.passwordResets[utils.HashToken(reset.Token)] = &stored
+	return reset, nil
+}
+
+// GetPasswordReset returns an unexpired reset without deleting it.
+func (s *MemoryStore) GetPasswordReset(token string) (PasswordReset, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reset, ok := s.passwordResets[utils.HashToken(token)]
+	if !ok || time.Now().After(reset.ExpiresAt) {
+		return PasswordReset{}, ErrResetNotFound
+	}
+
+	found := *reset
+	found.Token = token
+	return found, nil
+}
+
+// ConsumePasswordReset returns and deletes an unexpired reset.
+func (s *MemoryStore) ConsumePasswordReset(token string) (PasswordReset, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenHash := utils.HashToken(token)
+	reset, ok := s.passwordResets[tokenHash]
+	if !ok {
+		return PasswordReset{}, ErrResetNotFound
+	}
+	delete(s.passwordResets, tokenHash)
+	if time.Now().After(reset.ExpiresAt) {
+		return PasswordReset{}, ErrResetNotFound
+	}
+
+	found := *reset
+	found.Token = token
+	return found, nil
+}
@@ -0,0 +1,44 @@
+package db
+
+import "time"
+
+// RevokeSession adds the session ID to the revocation list until expiresAt.
+func (s *MemoryStore) RevokeSession(sessionID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := RevokedSession + ":" + sessionID
+	if _, ok := s.revocations[key]; !ok {
+		s.revocations[key] = &Revocation{Kind: RevokedSession, Subject: sessionID, RevokedAt: time.Now().UTC(), ExpiresAt: expiresAt}
+	}
+	return nil
+}
+
+// RevokeUserSessions ends every session issued to the user before revokedAt,
+// replacing any earlier revocation of the user.
+func (s *MemoryStore) RevokeUserSessions(username string, revokedAt, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revocations[RevokedUser+":"+username] = &Revocation{Kind: RevokedUser, Subject: username, RevokedAt: revokedAt, ExpiresAt: expiresAt}
+	return nil
+}
+
+// ListRevocations returns the entries that have not expired, forgetting the
+// others.
+func (s *MemoryStore) ListRevocations() ([]Revocation, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var revocations []Revocation
+	for key, revocation := range s.revocations {
+		if !now.Before(revocation.ExpiresAt) {
+			delete(s.revocations, key)
+			continue
+		}
+		revocations = append(revocations, *revocation)
+	}
+	return revocations, nil
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"slices"
+	"strings"
+)
+
+// defaultRoles mirrors the roles seeded by the 0011_roles migration: role
+// name -> permissions.
+func defaultRoles() map[string]map[string]bool {
+	return map[string]map[string]bool{
+		"admin": {"admin:users": true},
+	}
+}
+
+// GetRoles returns the names of the user's roles, sorted.
+func (s *MemoryStore) GetRoles(username string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sortedKeys(s.userRoles[username]), nil
+}
+
+// GetPermissions returns every permission of the user's roles, sorted.
+func (s *MemoryStore) GetPermissions(username string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	permissions := make(map[string]bool)
+	for role := range s.userRoles[username] {
+		for permission := range s.roles[role] {
+			permissions[permission] = true
+		}
+	}
+	return sortedKeys(permissions), nil
+}
+
+// AssignRole grants an existing role to an existing user.
+func (s *MemoryStore) AssignRole(username, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[username]; !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := s.roles[role]; !ok {
+		return ErrRoleNotFound
+	}
+	if s.userRoles[username] == nil {
+		s.userRoles[username] = make(map[string]bool)
+	}
+	s.userRoles[username][role] = true
+	return nil
+}
+
+// RevokeRole takes the role from the user, if they hold it.
+func (s *MemoryStore) RevokeRole(username, role string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.userRoles[username], role)
+	return nil
+}
+
+// ListUsers returns every user with their roles, sorted by username.
+func (s *MemoryStore) ListUsers() ([]UserSummary, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make([]UserSummary, 0, len(s.users))
+	for username, user := range s.users {
+		users = append(users, UserSummary{
+			Username:      username,
+			Email:         user.email,
+			EmailVerified: !user.emailVerifiedAt.IsZero(),
+			Roles:         sortedKeys(s.userRoles[username]),
+		})
+	}
+	slices.SortFunc(users, func(a, b UserSummary) int { return strings.Compare(a.Username, b.Username) })
+	return users, nil
+}
+
+// sortedKeys returns the keys of a set in order, nil if it is empty.
+func sortedKeys(set map[string]bool) []string {
+	var keys []string
+	for key := range set {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
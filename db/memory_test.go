@@ -0,0 +1,149 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	store := NewMemoryStore()
+	const workers = 16
+
+	// every worker signs up, logs in on two devices and uses its sessions
+	// while the others do the same; run with -race to check the locking
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			username := fmt.Sprintf("user%d", i)
+			errs <- func() error {
+				if err := store.CreateUser(username, username+"@example.com", "Plum-Kettle-93"); err != nil {
+					return err
+				}
+				if ok, err := store.AuthenticateUser(username, "Plum-Kettle-93"); !ok || err != nil {
+					return fmt.Errorf("AuthenticateUser(%s) = %t, %v", username, ok, err)
+				}
+				phone, err := store.CreateSession(username, "203.0.113.7", "phone")
+				if err != nil {
+					return err
+				}
+				laptop, err := store.CreateSession(username, "203.0.113.8", "laptop")
+				if err != nil {
+					return err
+				}
+				if _, err := store.GetSession(phone.Token); err != nil {
+					return err
+				}
+				token, err := store.RotateCSRFToken(laptop.ID)
+				if err != nil {
+					return err
+				}
+				if ok, err := store.ValidateCSRFToken(laptop.ID, token); !ok || err != nil {
+					return fmt.Errorf("ValidateCSRFToken = %t, %v", ok, err)
+				}
+				if _, err := store.RecordLoginFailure("shared", time.Now().Add(-time.Hour)); err != nil {
+					return err
+				}
+				if err := store.AssignRole(username, "admin"); err != nil {
+					return err
+				}
+				if _, err := store.ListUsers(); err != nil {
+					return err
+				}
+				return store.DeleteSession(phone.ID)
+			}()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ids := make(map[int64]bool)
+	for i := 0; i < workers; i++ {
+		id, err := store.GetUserID(fmt.Sprintf("user%d", i))
+		if err != nil || ids[id] {
+			t.Fatalf("user%d has id %d, %v, which is not unique", i, id, err)
+		}
+		ids[id] = true
+	}
+	if len(store.sessions) != workers || len(store.sessionTokens) != workers {
+		t.Fatalf("%d sessions and %d tokens left, want %d of each", len(store.sessions), len(store.sessionTokens), workers)
+	}
+	if failures, _, _ := store.GetLoginFailures("shared"); failures != workers {
+		t.Fatalf("%d failures counted, want %d", failures, workers)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	store := NewMemoryStore()
+	if err := store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93"); err != nil {
+		t.Fatal(err)
+	}
+	looked, err := store.CreateSession("alice", "203.0.113.7", "phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	forgotten, err := store.CreateSession("alice", "203.0.113.7", "laptop")
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := store.CreateMFAChallenge("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := store.CreateMagicLink("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().UTC().Add(-time.Second)
+	store.sessions[looked.ID].ExpiresAt = past
+	store.sessions[forgotten.ID].ExpiresAt = past
+	store.challenges[challenge.ID].ExpiresAt = past
+	for _, stored := range store.magicLinks {
+		stored.ExpiresAt = past
+	}
+
+	// a lookup drops the expired session it finds
+	if _, err := store.GetSession(looked.Token); err != ErrSessionNotFound {
+		t.Fatalf("GetSession of an expired session = %v, want ErrSessionNotFound", err)
+	}
+	if _, ok := store.sessions[looked.ID]; ok {
+		t.Fatal("an expired session was kept after its lookup")
+	}
+
+	// the sweep drops the ones nobody looks up again
+	if _, err := store.CreateSession("alice", "203.0.113.7", "tablet"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.sessions[forgotten.ID]; !ok {
+		t.Fatal("the sweep ran before its interval")
+	}
+	store.lastSweep = time.Now().Add(-memorySweepInterval)
+	if _, err := store.CreateSession("alice", "203.0.113.7", "watch"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.sessions[forgotten.ID]; ok {
+		t.Fatal("an expired session was not swept")
+	}
+	if len(store.sessions) != 2 || len(store.sessionTokens) != 2 {
+		t.Fatalf("%d sessions and %d tokens left, want the 2 live ones", len(store.sessions), len(store.sessionTokens))
+	}
+	if len(store.challenges) != 0 || len(store.challengeTokens) != 0 {
+		t.Fatal("an expired MFA challenge was not swept")
+	}
+	if len(store.magicLinks) != 0 {
+		t.Fatal("an expired magic link was not swept")
+	}
+	if _, err := store.ConsumeMagicLink(link.Token, link.BrowserToken); err != ErrMagicLinkNotFound {
+		t.Fatalf("ConsumeMagicLink of a swept link = %v, want ErrMagicLinkNotFound", err)
+	}
+}
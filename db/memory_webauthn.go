@@ -0,0 +1,105 @@
+package db
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// AddWebAuthnCredential stores a newly registered credential.
+func (s *MemoryStore) AddWebAuthnCredential(cred WebAuthnCredential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.users[cred.Username]; !ok {
+		return ErrUserNotFound
+	}
+	if _, ok := s.credentials[cred.ID]; ok {
+		return ErrCredentialExists
+	}
+	cred.CreatedAt = time.Now().UTC()
+	s.credentials[cred.ID] = &cred
+	return nil
+}
+
+// GetWebAuthnCredential looks up a credential by its base64url ID.
+func (s *MemoryStore) GetWebAuthnCredential(credentialID string) (WebAuthnCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cred, ok := s.credentials[credentialID]
+	if !ok {
+		return WebAuthnCredential{}, ErrCredentialNotFound
+	}
+	return *cred, nil
+}
+
+// ListWebAuthnCredentials returns every credential the user registered,
+// oldest first.
+func (s *MemoryStore) ListWebAuthnCredentials(username string) ([]WebAuthnCredential, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var creds []WebAuthnCredential
+	for _, cred := range s.credentials {
+		if cred.Username == username {
+			creds = append(creds, *cred)
+		}
+	}
+	sort.Slice(creds, func(i, j int) bool {
+		return creds[i].CreatedAt.Before(creds[j].CreatedAt)
+	})
+	return creds, nil
+}
+
+// UpdateWebAuthnSignCount records a successful login with the credential.
+func (s *MemoryStore) UpdateWebAuthnSignCount(credentialID string, signCount uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cred, ok := s.credentials[credentialID]
+	if !ok {
+		return ErrCredentialNotFound
+	}
+	cred.SignCount = signCount
+	cred.LastUsedAt = time.Now().UTC()
+	return nil
+}
+
+// CreateWebAuthnCeremony stores a ceremony in progress, keyed by the hash of
+// its token.
+func (s *MemoryStore) CreateWebAuthnCeremony(ceremony WebAuthnCeremony) (WebAuthnCeremony, error) {
+	ceremony.Token = utils.GenerateToken(32)
+	ceremony.ExpiresAt = time.Now().UTC().Add(ceremonyLifetime)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweepExpiredLocked(time.Now().UTC())
+
+	stored := ceremony
+	stored.Token = ""
+	s.ceremonies[utils.HashToken(ceremony.Token)] = &stored
+	return ceremony, nil
+}
+
+// ConsumeWebAuthnCeremony returns and deletes an unexpired ceremony.
+func (s *MemoryStore) ConsumeWebAuthnCeremony(token string) (WebAuthnCeremony, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokenHash := utils.HashToken(token)
+	ceremony, ok := s.ceremonies[tokenHash]
+	if !ok {
+		return WebAuthnCeremony{}, ErrCeremonyNotFound
+	}
+	delete(s.ceremonies, tokenHash)
+	if time.Now().After(ceremony.ExpiresAt) {
+		return WebAuthnCeremony{}, ErrCeremonyNotFound
+	}
+
+	found := *ceremony
+	found.Token = token
+	return found, nil
+}
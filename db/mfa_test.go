@@ -0,0 +1,25 @@
+package db
+
+import "testing"
+
+func TestUseTOTPStepReplay(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			steps := []struct {
+				step int64
+				ok   bool
+			}{
+				{1000, true},
+				{1000, false}, // the same code again
+				{999, false},  // an earlier code still inside the skew window
+				{1001, true},
+			}
+			for _, test := range steps {
+				ok, err := store.UseTOTPStep("alice", test.step)
+				if err != nil || ok != test.ok {
+					t.Fatalf("UseTOTPStep(%d) = %t, %v, want %t", test.step, ok, err, test.ok)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// migrationLockID is the PostgreSQL advisory lock key held while migrating.
+const migrationLockID = 7262019
+
+/*
+Migrator is implemented by stores that have a versioned schema. Stores that
+keep no schema, such as the MemoryStore, do not implement it.
+*/
+type Migrator interface {
+	// MigrateUp applies every migration that has not been applied yet.
+	MigrateUp() error
+	// MigrateDown reverts the given number of most recently applied migrations.
+	MigrateDown(steps int) error
+	// SchemaVersion returns the highest applied migration version.
+	SchemaVersion() (int, error)
+}
+
+// migration is a single versioned schema change with its up and down scripts.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// queryer runs the statements of a migration step: the migration connection
+// on SQLite, the step's transaction on PostgreSQL.
+type queryer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// migrationFuncs are changes SQL cannot express, by migration version. Each
+// runs after the up script of its version, as part of the same step.
+var migrationFuncs = map[int]func(ctx context.Context, q queryer, driver string) error{
+	2:  moveUserSessions,
+	13: canonicalizeUsernames,
+	14: addUsernameSkeletons,
+}
+
+/*
+loadMigrations reads the embedded migrations for the given driver. Files are
+named <version>_<name>.up.sql and <version>_<name>.down.sql and are returned
+sorted by version.
+
+Returns:
+
+- []migration: The migrations in ascending version order.
+
+- error: An error if a file is misnamed or a version is missing a script.
+*/
+func loadMigrations(driver string) ([]migration, error) {
+	dir := path.Join("migrations", driver)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, entry := range entries {
+		fileName := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(fileName, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(fileName, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(fileName, "."+direction+".sql")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name: %s", fileName)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %s", fileName, err.Error())
+		}
+
+		contents, err := migrationFiles.ReadFile(path.Join(dir, fileName))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(contents)
+		} else {
+			m.down = string(contents)
+		}
+	}
+
+	migrations := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d_%s is missing its up or down script", m.version, m.name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].version < migrations[j].version
+	})
+	return migrations, nil
+}
+
+/*
+withMigrationLock runs fn on a dedicated connection while holding a lock that
+stops other instances from migrating at the same time. PostgreSQL uses a
+session advisory lock; SQLite takes the database write lock with BEGIN
+IMMEDIATE, so fn runs inside that single transaction.
+
+Returns:
+
+- error: An error if the lock cannot be taken or fn fails.
+*/
+func (s *SQLStore) withMigrationLock(fn func(conn *sql.Conn) error) (err error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if s.driver == "sqlite" {
+		_, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		if err != nil {
+			return fmt.Errorf("could not lock database for migration: %s", err.Error())
+		}
+		defer func() {
+			if err != nil {
+				conn.ExecContext(ctx, "ROLLBACK")
+				return
+			}
+			_, err = conn.ExecContext(ctx, "COMMIT")
+		}()
+		return fn(conn)
+	}
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID)
+	if err != nil {
+		return fmt.Errorf("could not lock database for migration: %s", err.Error())
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+	return fn(conn)
+}
+
+// runMigrationStep executes a migration script, then fn if it is not nil, and
+// records the change to schema_migrations. On PostgreSQL each step gets its
+// own transaction; on SQLite the surrounding BEGIN IMMEDIATE already provides
+// one.
+func (s *SQLStore) runMigrationStep(conn *sql.Conn, script string, fn func(context.Context, queryer, string) error, record string, version int) error {
+	ctx := context.Background()
+	if s.driver == "sqlite" {
+		return runMigrationStatements(ctx, conn, s.driver, script, fn, record, version)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	err = runMigrationStatements(ctx, tx, s.driver, script, fn, record, version)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// runMigrationStatements runs the statements of one migration step.
+func runMigrationStatements(ctx context.Context, q queryer, driver, script string, fn func(context.Context, queryer, string) error, record string, version int) error {
+	_, err := q.ExecContext(ctx, script)
+	if err != nil {
+		return err
+	}
+	if fn != nil {
+		err = fn(ctx, q, driver)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = q.ExecContext(ctx, record, version)
+	return err
+}
+
+// appliedVersions creates the schema_migrations table if needed and returns
+// the applied versions in ascending order.
+func appliedVersions(conn *sql.Conn) ([]int, error) {
+	ctx := context.Background()
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []int
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, rows.Err()
+}
+
+/*
+MigrateUp applies every embedded migration newer than the ones recorded in
+schema_migrations, in version order.
+
+Returns:
+
+- error: An error if a migration fails. Migrations applied before the failure
+are kept.
+*/
+func (s *SQLStore) MigrateUp() error {
+	migrations, err := loadMigrations(s.driver)
+	if err != nil {
+		return err
+	}
+
+	return s.withMigrationLock(func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		applied := make(map[int]bool, len(versions))
+		for _, version := range versions {
+			applied[version] = true
+		}
+
+		for _, m := range migrations {
+			if applied[m.version] {
+				continue
+			}
+			logs.Logs(logDb, fmt.Sprintf("Applying migration %d_%s...", m.version, m.name))
+			err := s.runMigrationStep(conn, m.up, migrationFuncs[m.version], `INSERT INTO schema_migrations (version) VALUES ($1)`, m.version)
+			if err != nil {
+				logs.Logs(logDbErr, fmt.Sprintf("Migration %d_%s failed: %s", m.version, m.name, err.Error()))
+				return err
+			}
+		}
+		logs.Logs(logDb, "Database schema is up to date.")
+		return nil
+	})
+}
+
+/*
+MigrateDown reverts the given number of most recently applied migrations,
+newest first.
+
+Returns:
+
+- error: An error if a migration fails or an applied version has no
+embedded down script.
+*/
+func (s *SQLStore) MigrateDown(steps int) error {
+	migrations, err := loadMigrations(s.driver)
+	if err != nil {
+		return err
+	}
+	byVersion := make(map[int]migration, len(migrations))
+	for _, m := range migrations {
+		byVersion[m.version] = m
+	}
+
+	return s.withMigrationLock(func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(versions) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+			m, ok := byVersion[versions[i]]
+			if !ok {
+				return fmt.Errorf("no migration found for applied version %d", versions[i])
+			}
+			logs.Logs(logDb, fmt.Sprintf("Reverting migration %d_%s...", m.version, m.name))
+			err := s.runMigrationStep(conn, m.down, nil, `DELETE FROM schema_migrations WHERE version=$1`, m.version)
+			if err != nil {
+				logs.Logs(logDbErr, fmt.Sprintf("Reverting migration %d_%s failed: %s", m.version, m.name, err.Error()))
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+/*
+SchemaVersion returns the highest migration version recorded in
+schema_migrations, or 0 if none have been applied.
+
+Returns:
+
+- int: The current schema version.
+
+- error: An error if the version cannot be read.
+*/
+func (s *SQLStore) SchemaVersion() (int, error) {
+	var version int
+	err := s.withMigrationLock(func(conn *sql.Conn) error {
+		versions, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		if len(versions) > 0 {
+			version = versions[len(versions)-1]
+		}
+		return nil
+	})
+	return version, err
+}
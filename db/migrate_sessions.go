@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// legacySession is a login stored on the user row before sessions had a
+// table of their own.
+type legacySession struct {
+	username     string
+	sessionToken string
+	csrfToken    string
+	expiry       time.Time
+}
+
+/*
+moveUserSessions copies the unexpired login each user row holds into
+tbl_web_auth_sessions, then drops the session columns from the user row, so
+upgrading logs nobody out. Those logins lasted sessionLifetime, which dates
+when they began; they keep their raw tokens, which are hashed the first time
+they are used once tokens are stored hashed. It runs in Go because SQLite
+stores the expiry in a format its date functions cannot read.
+
+Returns:
+
+- error: An error if a statement fails.
+*/
+func moveUserSessions(ctx context.Context, q queryer, driver string) error {
+	sessions, err := queryLegacySessions(ctx, q)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	moved := 0
+	for _, session := range sessions {
+		if !now.Before(session.expiry) {
+			continue
+		}
+		createdAt := session.expiry.UTC().Add(-sessionLifetime)
+		_, err = q.ExecContext(ctx, `
+		INSERT INTO tbl_web_auth_sessions
+			(session_id, session_token, csrf_token, username, created_at, expires_at, last_seen_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		`, utils.GenerateToken(16), session.sessionToken, session.csrfToken, session.username,
+			createdAt, session.expiry.UTC(), createdAt)
+		if err != nil {
+			return err
+		}
+		moved++
+	}
+	logs.Logs(logDb, fmt.Sprintf("Moved %d unexpired logins to the sessions table", moved))
+
+	for _, column := range []string{"session_token", "csrf_token", "token_expiry"} {
+		_, err = q.ExecContext(ctx, fmt.Sprintf("ALTER TABLE tbl_web_auth_demo DROP COLUMN %s", column))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryLegacySessions returns the logins stored on user rows. The rows are
+// read in full before returning, so the caller can run statements on the
+// same connection.
+func queryLegacySessions(ctx context.Context, q queryer) ([]legacySession, error) {
+	rows, err := q.QueryContext(ctx, `
+	SELECT username, session_token, csrf_token, token_expiry
+	FROM tbl_web_auth_demo
+	WHERE session_token IS NOT NULL AND csrf_token IS NOT NULL AND token_expiry IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []legacySession
+	for rows.Next() {
+		var session legacySession
+		if err := rows.Scan(&session.username, &session.sessionToken, &session.csrfToken, &session.expiry); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
@@ -0,0 +1,280 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newSQLiteTestStore opens an empty SQLite database in a temporary file.
+func newSQLiteTestStore(t *testing.T) *SQLStore {
+	store, err := connectSQLite("sqlite://" + filepath.Join(t.TempDir(), "auth.db"))
+	if err != nil {
+		t.Fatalf("connectSQLite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func checkSchemaVersion(t *testing.T, store *SQLStore, want int) {
+	t.Helper()
+	version, err := store.SchemaVersion()
+	if err != nil || version != want {
+		t.Fatalf("SchemaVersion = %d, %v, want %d", version, err, want)
+	}
+}
+
+func TestMigrateUpDownUp(t *testing.T) {
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	latest := migrations[len(migrations)-1].version
+	store := newSQLiteTestStore(t)
+	checkSchemaVersion(t, store, 0)
+
+	err = store.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	checkSchemaVersion(t, store, latest)
+	// applying again is a no-op
+	err = store.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp when up to date: %v", err)
+	}
+	checkSchemaVersion(t, store, latest)
+
+	// revert one step at a time, so every down script runs
+	for i := len(migrations) - 1; i >= 0; i-- {
+		err = store.MigrateDown(1)
+		if err != nil {
+			t.Fatalf("MigrateDown from %d: %v", migrations[i].version, err)
+		}
+		want := 0
+		if i > 0 {
+			want = migrations[i-1].version
+		}
+		checkSchemaVersion(t, store, want)
+	}
+	var table string
+	err = store.db.QueryRow(`SELECT name FROM sqlite_master WHERE name='tbl_web_auth_demo'`).Scan(&table)
+	if err != sql.ErrNoRows {
+		t.Errorf("users table after reverting every migration: %q, %v", table, err)
+	}
+
+	err = store.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp after reverting: %v", err)
+	}
+	checkSchemaVersion(t, store, latest)
+}
+
+func TestMigrateMovesUserSessions(t *testing.T) {
+	store := migrateToVersion(t, 1)
+	// logins were stored on the user row, with expiry times in local time
+	zone := time.FixedZone("CEST", 2*60*60)
+	expiry := time.Now().In(zone).Add(2 * time.Hour).Truncate(time.Second)
+	users := []struct {
+		username                        string
+		sessionToken, csrfToken, expiry any
+	}{
+		{"alice", "alice-session", "alice-csrf", expiry},
+		{"bob", "bob-session", "bob-csrf", time.Now().In(zone).Add(-time.Minute)},
+		{"carol", nil, nil, nil},
+	}
+	for _, user := range users {
+		_, err := store.db.Exec(`INSERT INTO tbl_web_auth_demo (username, hash_password, session_token, csrf_token, token_expiry) VALUES ($1, 'hash', $2, $3, $4)`,
+			user.username, user.sessionToken, user.csrfToken, user.expiry)
+		if err != nil {
+			t.Fatalf("adding user %q: %v", user.username, err)
+		}
+	}
+
+	err := store.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	got := queryColumn(t, store, `SELECT username FROM tbl_web_auth_sessions`)
+	if !reflect.DeepEqual(got, []string{"alice"}) {
+		t.Fatalf("sessions moved for %q, want only the unexpired one of alice", got)
+	}
+
+	// the browser's cookies still work after the upgrade
+	session, err := store.GetSession("alice-session")
+	if err != nil {
+		t.Fatalf("GetSession after the upgrade: %v", err)
+	}
+	if session.Username != "alice" || !session.ExpiresAt.Equal(expiry) || !session.CreatedAt.Equal(expiry.Add(-sessionLifetime)) {
+		t.Errorf("GetSession = %s created %v expiring %v, want alice created %v expiring %v",
+			session.Username, session.CreatedAt, session.ExpiresAt, expiry.Add(-sessionLifetime), expiry)
+	}
+	valid, err := store.ValidateCSRFToken(session.ID, "alice-csrf")
+	if !valid || err != nil {
+		t.Errorf("ValidateCSRFToken after the upgrade = %t, %v, want true", valid, err)
+	}
+}
+
+// latestVersion returns the version of the newest SQLite migration.
+func latestVersion(t *testing.T) int {
+	t.Helper()
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatalf("loadMigrations: %v", err)
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// migrateToVersion migrates a new database up to the given version and adds
+// users created back then.
+func migrateToVersion(t *testing.T, version int, usernames ...string) *SQLStore {
+	store := newSQLiteTestStore(t)
+	err := store.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	err = store.MigrateDown(latestVersion(t) - version)
+	if err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	checkSchemaVersion(t, store, version)
+
+	for i, username := range usernames {
+		_, err = store.db.Exec(`INSERT INTO tbl_web_auth_demo (username, hash_password, email) VALUES ($1, 'hash', $2)`,
+			username, fmt.Sprintf("user%d@example.com", i))
+		if err != nil {
+			t.Fatalf("adding user %q: %v", username, err)
+		}
+	}
+	return store
+}
+
+// migrateToUsernameMigration migrates a new database up to the version
+// before usernames were made canonical, and adds users created back then.
+func migrateToUsernameMigration(t *testing.T, usernames ...string) *SQLStore {
+	return migrateToVersion(t, 12, usernames...)
+}
+
+func queryColumn(t *testing.T, store *SQLStore, query string) []string {
+	t.Helper()
+	rows, err := store.db.Query(query)
+	if err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestMigrateCanonicalUsernames(t *testing.T) {
+	store := migrateToUsernameMigration(t, "Ärger", "ＡＢＣ", "bob", "Ωmega")
+	now := time.Now().UTC()
+	for _, statement := range []string{
+		`INSERT INTO tbl_web_auth_user_roles (username, role, granted_at) VALUES ('Ärger', 'admin', $1)`,
+		`INSERT INTO tbl_web_auth_email_verifications (token_hash, username, email, created_at, expires_at) VALUES ('hash', 'ＡＢＣ', 'user1@example.com', $1, $1)`,
+		`INSERT INTO tbl_web_auth_login_failures (username, failed_logins, last_failed_at) VALUES ('Ärger', 3, $1)`,
+		`INSERT INTO tbl_web_auth_login_failures (username, failed_logins, last_failed_at) VALUES ('bob', 2, $1)`,
+	} {
+		_, err := store.db.Exec(statement, now)
+		if err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+	}
+
+	err := store.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	checkSchemaVersion(t, store, latestVersion(t))
+
+	checks := []struct {
+		query string
+		want  []string
+	}{
+		// full-width letters are mapped to ASCII and Unicode case folded,
+		// which SQLite's LOWER does neither of
+		{`SELECT username FROM tbl_web_auth_demo ORDER BY username`, []string{"abc", "bob", "ärger", "ωmega"}},
+		{`SELECT username FROM tbl_web_auth_user_roles`, []string{"ärger"}},
+		{`SELECT username FROM tbl_web_auth_email_verifications`, []string{"abc"}},
+		{`SELECT username FROM tbl_web_auth_login_failures`, []string{"bob"}},
+	}
+	for _, check := range checks {
+		got := queryColumn(t, store, check.query)
+		if !reflect.DeepEqual(got, check.want) {
+			t.Errorf("%s = %q, want %q", check.query, got, check.want)
+		}
+	}
+}
+
+func TestMigrateCanonicalUsernamesCollision(t *testing.T) {
+	store := migrateToUsernameMigration(t, "Ärger", "äRGER", "ＢＯＢ", "bob")
+
+	err := store.MigrateUp()
+	if err == nil {
+		t.Fatal("MigrateUp with colliding usernames succeeded")
+	}
+	names := func(a, b string) bool {
+		return strings.Contains(err.Error(), a) && strings.Contains(err.Error(), b)
+	}
+	if !names("Ärger", "äRGER") && !names("ＢＯＢ", "bob") {
+		t.Errorf("MigrateUp error %q does not name the colliding users", err)
+	}
+	// nothing was renamed and the migration can run again once fixed
+	checkSchemaVersion(t, store, 12)
+	got := queryColumn(t, store, `SELECT username FROM tbl_web_auth_demo ORDER BY id`)
+	want := []string{"Ärger", "äRGER", "ＢＯＢ", "bob"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("usernames after the failed migration = %q, want %q", got, want)
+	}
+}
+
+func TestMigrateUsernameSkeletons(t *testing.T) {
+	store := migrateToVersion(t, 13, "paypal", "alice")
+	err := store.MigrateUp()
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	got := queryColumn(t, store, `SELECT username_skeleton FROM tbl_web_auth_demo ORDER BY username`)
+	want := []string{"alice", "paypal"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("skeletons = %q, want %q", got, want)
+	}
+
+	// a single-script look-alike passes validation but not the store
+	tests := []struct {
+		username string
+		want     error
+	}{
+		{"paypal", ErrUserExists},
+		{"раураӏ", ErrUsernameLookAlike},
+		{"a.lice", ErrUsernameLookAlike},
+		{"alicia", nil},
+	}
+	for i, test := range tests {
+		err := store.CreateUser(test.username, fmt.Sprintf("new%d@example.com", i), "Plum-Kettle-93")
+		if err != test.want {
+			t.Errorf("CreateUser(%q) = %v, want %v", test.username, err, test.want)
+		}
+	}
+}
+
+func TestMigrateUsernameSkeletonsCollision(t *testing.T) {
+	store := migrateToVersion(t, 13, "alice", "a.lice")
+
+	err := store.MigrateUp()
+	if err == nil || !strings.Contains(err.Error(), `"alice"`) || !strings.Contains(err.Error(), `"a.lice"`) {
+		t.Fatalf("MigrateUp error %v does not name the look-alike users", err)
+	}
+	checkSchemaVersion(t, store, 13)
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+// usernameTables are the tables whose username column references the users
+// table, renamed along with it where foreign keys cannot cascade the rename.
+var usernameTables = []string{
+	"tbl_web_auth_sessions",
+	"tbl_web_auth_mfa_challenges",
+	"tbl_web_auth_credentials",
+	"tbl_web_auth_recovery_codes",
+	"tbl_web_auth_password_resets",
+	"tbl_web_auth_email_verifications",
+	"tbl_web_auth_magic_links",
+	"tbl_web_auth_user_roles",
+}
+
+/*
+canonicalizeUsernames renames users created before usernames were stored in
+canonical form to the name validation.CanonicalUsername maps them to, which
+is what logins look up: "Ärger" becomes "ärger" and full-width "ＡＢＣ"
+becomes "abc". It runs in Go because SQL's LOWER neither folds Unicode case
+the same way nor maps widths. Two users whose names only differ in that way
+cannot both keep them, so the migration stops, naming them, until one is
+renamed by hand.
+
+On PostgreSQL the references follow through ON UPDATE CASCADE, added by the
+up script; SQLite checks foreign keys at commit instead, so each reference is
+renamed here first. Failed login counts and pending passkey ceremonies kept
+under an old spelling are short lived and dropped rather than merged.
+
+Returns:
+
+- error: An error if two names collide or a statement fails.
+*/
+func canonicalizeUsernames(ctx context.Context, q queryer, driver string) error {
+	usernames, err := queryUsernames(ctx, q, "tbl_web_auth_demo")
+	if err != nil {
+		return err
+	}
+
+	renames := make(map[string]string)
+	owners := make(map[string]string, len(usernames)) // canonical -> username
+	for _, username := range usernames {
+		canonical := validation.CanonicalUsername(username)
+		if other, ok := owners[canonical]; ok {
+			return fmt.Errorf("usernames %q and %q are both %q in canonical form, rename one of them first", other, username, canonical)
+		}
+		owners[canonical] = username
+		if canonical != username {
+			renames[username] = canonical
+		}
+	}
+
+	for username, canonical := range renames {
+		if driver == "sqlite" {
+			for _, table := range usernameTables {
+				_, err = q.ExecContext(ctx, fmt.Sprintf("UPDATE %s SET username=$1 WHERE username=$2", table), canonical, username)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		_, err = q.ExecContext(ctx, "UPDATE tbl_web_auth_demo SET username=$1 WHERE username=$2", canonical, username)
+		if err != nil {
+			return err
+		}
+		logs.Logs(logDb, fmt.Sprintf("Renamed user %q to %q", username, canonical))
+	}
+
+	for _, table := range []string{"tbl_web_auth_login_failures", "tbl_web_auth_webauthn_ceremonies"} {
+		usernames, err := queryUsernames(ctx, q, table)
+		if err != nil {
+			return err
+		}
+		for _, username := range usernames {
+			if validation.CanonicalUsername(username) == username {
+				continue
+			}
+			_, err = q.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE username=$1", table), username)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// queryUsernames returns the distinct usernames in the table. The rows are
+// read in full before returning, so the caller can run statements on the
+// same connection.
+func queryUsernames(ctx context.Context, q queryer, table string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT username FROM %s", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+	return usernames, rows.Err()
+}
+
+/*
+addUsernameSkeletons stores the validation.UsernameSkeleton of every user
+and makes it unique, so nobody can register a look-alike of a taken name.
+Existing users whose names look alike cannot both keep them, so the migration
+stops, naming them, until one is renamed by hand.
+
+Returns:
+
+- error: An error if two names look alike or a statement fails.
+*/
+func addUsernameSkeletons(ctx context.Context, q queryer, driver string) error {
+	usernames, err := queryUsernames(ctx, q, "tbl_web_auth_demo")
+	if err != nil {
+		return err
+	}
+
+	owners := make(map[string]string, len(usernames)) // skeleton -> username
+	for _, username := range usernames {
+		skeleton := validation.UsernameSkeleton(username)
+		if other, ok := owners[skeleton]; ok {
+			return fmt.Errorf("usernames %q and %q look alike, rename one of them first", other, username)
+		}
+		owners[skeleton] = username
+	}
+
+	for skeleton, username := range owners {
+		_, err = q.ExecContext(ctx, "UPDATE tbl_web_auth_demo SET username_skeleton=$1 WHERE username=$2", skeleton, username)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = q.ExecContext(ctx, "CREATE UNIQUE INDEX idx_web_auth_demo_username_skeleton ON tbl_web_auth_demo (username_skeleton)")
+	return err
+}
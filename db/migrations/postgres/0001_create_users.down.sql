@@ -0,0 +1,9 @@
+-- a users table that predates the migrations holds the original app's users
+-- and is kept, along with the columns the up script added to it
+DO $$
+BEGIN
+    IF obj_description(to_regclass('tbl_web_auth_demo'), 'pg_class') = 'created by migration 0001_create_users' THEN
+        DROP TABLE tbl_web_auth_demo;
+    END IF;
+END
+$$;
@@ -0,0 +1,39 @@
+-- the users table may predate the migrations, made by hand for the original
+-- app without an id or a unique username, which later tables reference. Such
+-- a table is brought up to this shape and kept; a table made here is marked
+-- so that only it is dropped by the down script.
+DO $$
+DECLARE
+    col TEXT;
+BEGIN
+    IF to_regclass('tbl_web_auth_demo') IS NULL THEN
+        CREATE TABLE tbl_web_auth_demo (
+            id            SERIAL PRIMARY KEY,
+            username      VARCHAR(255) NOT NULL UNIQUE,
+            hash_password TEXT NOT NULL,
+            session_token TEXT,
+            csrf_token    TEXT,
+            token_expiry  TIMESTAMPTZ
+        );
+        COMMENT ON TABLE tbl_web_auth_demo IS 'created by migration 0001_create_users';
+        RETURN;
+    END IF;
+
+    ALTER TABLE tbl_web_auth_demo
+        ADD COLUMN IF NOT EXISTS id SERIAL,
+        ADD COLUMN IF NOT EXISTS session_token TEXT,
+        ADD COLUMN IF NOT EXISTS csrf_token TEXT,
+        ADD COLUMN IF NOT EXISTS token_expiry TIMESTAMPTZ;
+
+    FOREACH col IN ARRAY ARRAY['id', 'username'] LOOP
+        IF NOT EXISTS (
+            SELECT 1
+            FROM pg_constraint c
+            JOIN pg_attribute a ON a.attrelid = c.conrelid AND c.conkey = ARRAY[a.attnum]
+            WHERE c.conrelid = 'tbl_web_auth_demo'::regclass AND c.contype IN ('p', 'u') AND a.attname = col
+        ) THEN
+            EXECUTE format('ALTER TABLE tbl_web_auth_demo ADD CONSTRAINT %I UNIQUE (%I)', 'tbl_web_auth_demo_' || col || '_key', col);
+        END IF;
+    END LOOP;
+END
+$$;
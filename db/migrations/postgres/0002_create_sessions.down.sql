@@ -0,0 +1,7 @@
+-- sessions are not moved back onto the user row, so reverting logs everyone out
+ALTER TABLE tbl_web_auth_demo
+    ADD COLUMN session_token TEXT,
+    ADD COLUMN csrf_token TEXT,
+    ADD COLUMN token_expiry TIMESTAMPTZ;
+
+DROP TABLE IF EXISTS tbl_web_auth_sessions;
@@ -0,0 +1,3 @@
+-- hashed tokens cannot be turned back into raw ones, those sessions must log in again
+DELETE FROM tbl_web_auth_sessions WHERE token_hashed;
+ALTER TABLE tbl_web_auth_sessions DROP COLUMN token_hashed;
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS tbl_web_auth_mfa_challenges;
+
+ALTER TABLE tbl_web_auth_demo
+    DROP COLUMN totp_secret,
+    DROP COLUMN totp_enabled,
+    DROP COLUMN totp_last_step;
@@ -0,0 +1,13 @@
+ALTER TABLE tbl_web_auth_demo
+    ADD COLUMN totp_secret TEXT,
+    ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- logins that passed the password check and are waiting for a second factor
+CREATE TABLE tbl_web_auth_mfa_challenges (
+    challenge_id VARCHAR(64) PRIMARY KEY,
+    token_hash   VARCHAR(64) NOT NULL UNIQUE,
+    username     VARCHAR(255) NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    expires_at   TIMESTAMPTZ NOT NULL,
+    attempts     INTEGER NOT NULL DEFAULT 0
+);
@@ -0,0 +1,22 @@
+CREATE TABLE tbl_web_auth_credentials (
+    credential_id VARCHAR(1400) PRIMARY KEY,
+    username       VARCHAR(255) NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    user_handle   BYTEA NOT NULL,
+    public_key    BYTEA NOT NULL,
+    sign_count    BIGINT NOT NULL DEFAULT 0,
+    transports    TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL,
+    last_used_at  TIMESTAMPTZ
+);
+
+CREATE INDEX idx_web_auth_credentials_username ON tbl_web_auth_credentials (username);
+
+-- challenges of registration and login ceremonies in progress
+CREATE TABLE tbl_web_auth_webauthn_ceremonies (
+    token_hash  VARCHAR(64) PRIMARY KEY,
+    kind        VARCHAR(16) NOT NULL,
+    username    VARCHAR(255) NOT NULL DEFAULT '',
+    challenge   BYTEA NOT NULL,
+    user_handle BYTEA,
+    expires_at  TIMESTAMPTZ NOT NULL
+);
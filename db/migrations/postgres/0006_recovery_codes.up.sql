@@ -0,0 +1,7 @@
+CREATE TABLE tbl_web_auth_recovery_codes (
+    username   VARCHAR(255) NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    code_hash  VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    PRIMARY KEY (username, code_hash)
+);
@@ -0,0 +1,9 @@
+-- single-use tokens emailed to users who forgot their password
+CREATE TABLE tbl_web_auth_password_resets (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    username   VARCHAR(255) NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_web_auth_password_resets_username ON tbl_web_auth_password_resets (username);
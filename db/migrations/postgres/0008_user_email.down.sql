@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS tbl_web_auth_email_verifications;
+
+DROP INDEX IF EXISTS idx_web_auth_demo_email;
+
+ALTER TABLE tbl_web_auth_demo
+    DROP COLUMN email,
+    DROP COLUMN email_verified_at;
@@ -0,0 +1,16 @@
+ALTER TABLE tbl_web_auth_demo
+    ADD COLUMN email VARCHAR(320),
+    ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+CREATE UNIQUE INDEX idx_web_auth_demo_email ON tbl_web_auth_demo (email);
+
+-- tokens emailed to users to prove they own their address
+CREATE TABLE tbl_web_auth_email_verifications (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    username   VARCHAR(255) NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    email      VARCHAR(320) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_web_auth_email_verifications_username ON tbl_web_auth_email_verifications (username);
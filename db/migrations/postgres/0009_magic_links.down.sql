@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tbl_web_auth_magic_links;
@@ -0,0 +1,11 @@
+-- single-use passwordless login links, bound to the browser that asked for them
+CREATE TABLE tbl_web_auth_magic_links (
+    token_hash   VARCHAR(64) PRIMARY KEY,
+    browser_hash VARCHAR(64) NOT NULL,
+    username     VARCHAR(255) NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    email        VARCHAR(320) NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_web_auth_magic_links_username ON tbl_web_auth_magic_links (username);
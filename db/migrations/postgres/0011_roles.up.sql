@@ -0,0 +1,21 @@
+-- roles group permissions, such as "admin:users", and are granted to users
+CREATE TABLE tbl_web_auth_roles (
+    name        VARCHAR(64) PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE tbl_web_auth_role_permissions (
+    role       VARCHAR(64) NOT NULL REFERENCES tbl_web_auth_roles (name) ON DELETE CASCADE,
+    permission VARCHAR(128) NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE tbl_web_auth_user_roles (
+    username   VARCHAR(255) NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    role       VARCHAR(64) NOT NULL REFERENCES tbl_web_auth_roles (name) ON DELETE CASCADE,
+    granted_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (username, role)
+);
+
+INSERT INTO tbl_web_auth_roles (name, description) VALUES ('admin', 'Manages users and their roles');
+INSERT INTO tbl_web_auth_role_permissions (role, permission) VALUES ('admin', 'admin:users');
@@ -0,0 +1,11 @@
+-- stateless sessions are not stored, so ending one early means listing it
+-- here until it would have expired anyway
+CREATE TABLE tbl_web_auth_session_revocations (
+    kind       VARCHAR(16) NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    revoked_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (kind, subject)
+);
+
+CREATE INDEX idx_web_auth_session_revocations_expires_at ON tbl_web_auth_session_revocations (expires_at);
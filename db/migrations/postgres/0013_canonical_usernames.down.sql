@@ -0,0 +1,34 @@
+-- lowercased usernames cannot be turned back into the names as typed, only
+-- the foreign keys are restored
+ALTER TABLE tbl_web_auth_sessions
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_sessions_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_sessions_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
+ALTER TABLE tbl_web_auth_mfa_challenges
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_mfa_challenges_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_mfa_challenges_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
+ALTER TABLE tbl_web_auth_credentials
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_credentials_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_credentials_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
+ALTER TABLE tbl_web_auth_recovery_codes
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_recovery_codes_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_recovery_codes_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
+ALTER TABLE tbl_web_auth_password_resets
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_password_resets_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_password_resets_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
+ALTER TABLE tbl_web_auth_email_verifications
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_email_verifications_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_email_verifications_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
+ALTER TABLE tbl_web_auth_magic_links
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_magic_links_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_magic_links_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
+ALTER TABLE tbl_web_auth_user_roles
+    DROP CONSTRAINT IF EXISTS tbl_web_auth_user_roles_username_fkey,
+    ADD CONSTRAINT tbl_web_auth_user_roles_username_fkey FOREIGN KEY (username)
+        REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+-- what each username looks like, so look-alikes of taken names are refused;
+-- filled in and made unique by addUsernameSkeletons in
+-- db/migrate_usernames.go, as SQL cannot compute it
+ALTER TABLE tbl_web_auth_demo ADD COLUMN username_skeleton VARCHAR(255);
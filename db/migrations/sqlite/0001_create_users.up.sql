@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS tbl_web_auth_demo (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    username      TEXT NOT NULL UNIQUE,
+    hash_password TEXT NOT NULL,
+    session_token TEXT,
+    csrf_token    TEXT,
+    token_expiry  TIMESTAMP
+);
@@ -0,0 +1,6 @@
+-- sessions are not moved back onto the user row, so reverting logs everyone out
+ALTER TABLE tbl_web_auth_demo ADD COLUMN session_token TEXT;
+ALTER TABLE tbl_web_auth_demo ADD COLUMN csrf_token TEXT;
+ALTER TABLE tbl_web_auth_demo ADD COLUMN token_expiry TIMESTAMP;
+
+DROP TABLE IF EXISTS tbl_web_auth_sessions;
@@ -0,0 +1,16 @@
+CREATE TABLE tbl_web_auth_sessions (
+    session_id    TEXT PRIMARY KEY,
+    session_token TEXT NOT NULL UNIQUE,
+    csrf_token    TEXT NOT NULL,
+    username      TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    created_at    TIMESTAMP NOT NULL,
+    expires_at    TIMESTAMP NOT NULL,
+    last_seen_at  TIMESTAMP NOT NULL,
+    ip_address    TEXT NOT NULL DEFAULT '',
+    user_agent    TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_web_auth_sessions_username ON tbl_web_auth_sessions (username);
+
+-- sessions are no longer stored on the user row; moveUserSessions copies
+-- the logins still in use here before dropping the columns
@@ -0,0 +1,3 @@
+-- rows created before this migration still hold raw tokens; they are
+-- rewritten to hashes the first time they are used
+ALTER TABLE tbl_web_auth_sessions ADD COLUMN token_hashed BOOLEAN NOT NULL DEFAULT FALSE;
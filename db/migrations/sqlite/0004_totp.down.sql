@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS tbl_web_auth_mfa_challenges;
+
+ALTER TABLE tbl_web_auth_demo DROP COLUMN totp_secret;
+ALTER TABLE tbl_web_auth_demo DROP COLUMN totp_enabled;
+ALTER TABLE tbl_web_auth_demo DROP COLUMN totp_last_step;
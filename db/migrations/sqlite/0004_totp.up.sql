@@ -0,0 +1,12 @@
+ALTER TABLE tbl_web_auth_demo ADD COLUMN totp_secret TEXT;
+ALTER TABLE tbl_web_auth_demo ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE tbl_web_auth_demo ADD COLUMN totp_last_step INTEGER NOT NULL DEFAULT 0;
+
+-- logins that passed the password check and are waiting for a second factor
+CREATE TABLE tbl_web_auth_mfa_challenges (
+    challenge_id TEXT PRIMARY KEY,
+    token_hash   TEXT NOT NULL UNIQUE,
+    username     TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    expires_at   TIMESTAMP NOT NULL,
+    attempts     INTEGER NOT NULL DEFAULT 0
+);
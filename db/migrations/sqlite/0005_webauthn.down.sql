@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS tbl_web_auth_webauthn_ceremonies;
+DROP TABLE IF EXISTS tbl_web_auth_credentials;
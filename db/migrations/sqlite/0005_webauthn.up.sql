@@ -0,0 +1,22 @@
+CREATE TABLE tbl_web_auth_credentials (
+    credential_id TEXT PRIMARY KEY,
+    username       TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    user_handle   BLOB NOT NULL,
+    public_key    BLOB NOT NULL,
+    sign_count    INTEGER NOT NULL DEFAULT 0,
+    transports    TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL,
+    last_used_at  TIMESTAMP
+);
+
+CREATE INDEX idx_web_auth_credentials_username ON tbl_web_auth_credentials (username);
+
+-- challenges of registration and login ceremonies in progress
+CREATE TABLE tbl_web_auth_webauthn_ceremonies (
+    token_hash  TEXT PRIMARY KEY,
+    kind        TEXT NOT NULL,
+    username    TEXT NOT NULL DEFAULT '',
+    challenge   BLOB NOT NULL,
+    user_handle BLOB,
+    expires_at  TIMESTAMP NOT NULL
+);
@@ -0,0 +1,7 @@
+CREATE TABLE tbl_web_auth_recovery_codes (
+    username   TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    code_hash  TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    used_at    TIMESTAMP,
+    PRIMARY KEY (username, code_hash)
+);
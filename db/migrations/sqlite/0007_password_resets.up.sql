@@ -0,0 +1,9 @@
+-- single-use tokens emailed to users who forgot their password
+CREATE TABLE tbl_web_auth_password_resets (
+    token_hash TEXT PRIMARY KEY,
+    username   TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_web_auth_password_resets_username ON tbl_web_auth_password_resets (username);
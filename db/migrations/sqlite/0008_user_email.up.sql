@@ -0,0 +1,15 @@
+ALTER TABLE tbl_web_auth_demo ADD COLUMN email TEXT;
+ALTER TABLE tbl_web_auth_demo ADD COLUMN email_verified_at TIMESTAMP;
+
+CREATE UNIQUE INDEX idx_web_auth_demo_email ON tbl_web_auth_demo (email);
+
+-- tokens emailed to users to prove they own their address
+CREATE TABLE tbl_web_auth_email_verifications (
+    token_hash TEXT PRIMARY KEY,
+    username   TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    email      TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_web_auth_email_verifications_username ON tbl_web_auth_email_verifications (username);
@@ -0,0 +1,11 @@
+-- single-use passwordless login links, bound to the browser that asked for them
+CREATE TABLE tbl_web_auth_magic_links (
+    token_hash   TEXT PRIMARY KEY,
+    browser_hash TEXT NOT NULL,
+    username     TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    email        TEXT NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    expires_at   TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_web_auth_magic_links_username ON tbl_web_auth_magic_links (username);
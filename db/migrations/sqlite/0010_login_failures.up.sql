@@ -0,0 +1,9 @@
+-- failed password logins per username, kept for unknown usernames too so a
+-- lockout does not reveal which accounts exist
+CREATE TABLE tbl_web_auth_login_failures (
+    username       TEXT PRIMARY KEY,
+    failed_logins  INTEGER NOT NULL,
+    last_failed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_web_auth_login_failures_last_failed_at ON tbl_web_auth_login_failures (last_failed_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS tbl_web_auth_user_roles;
+DROP TABLE IF EXISTS tbl_web_auth_role_permissions;
+DROP TABLE IF EXISTS tbl_web_auth_roles;
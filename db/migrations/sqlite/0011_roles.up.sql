@@ -0,0 +1,21 @@
+-- roles group permissions, such as "admin:users", and are granted to users
+CREATE TABLE tbl_web_auth_roles (
+    name        TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE tbl_web_auth_role_permissions (
+    role       TEXT NOT NULL REFERENCES tbl_web_auth_roles (name) ON DELETE CASCADE,
+    permission TEXT NOT NULL,
+    PRIMARY KEY (role, permission)
+);
+
+CREATE TABLE tbl_web_auth_user_roles (
+    username   TEXT NOT NULL REFERENCES tbl_web_auth_demo (username) ON DELETE CASCADE,
+    role       TEXT NOT NULL REFERENCES tbl_web_auth_roles (name) ON DELETE CASCADE,
+    granted_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username, role)
+);
+
+INSERT INTO tbl_web_auth_roles (name, description) VALUES ('admin', 'Manages users and their roles');
+INSERT INTO tbl_web_auth_role_permissions (role, permission) VALUES ('admin', 'admin:users');
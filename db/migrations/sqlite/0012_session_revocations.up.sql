@@ -0,0 +1,11 @@
+-- stateless sessions are not stored, so ending one early means listing it
+-- here until it would have expired anyway
+CREATE TABLE tbl_web_auth_session_revocations (
+    kind       TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    revoked_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (kind, subject)
+);
+
+CREATE INDEX idx_web_auth_session_revocations_expires_at ON tbl_web_auth_session_revocations (expires_at);
@@ -0,0 +1,2 @@
+-- lowercased usernames cannot be turned back into the names as typed
+SELECT 1;
@@ -0,0 +1,6 @@
+-- logins look usernames up in canonical form; the users created before
+-- that are renamed by canonicalizeUsernames in db/migrate_usernames.go, as
+-- SQL's LOWER does not map names the same way. SQLite cannot add ON UPDATE
+-- CASCADE to existing foreign keys, so check them at commit instead while
+-- every reference is renamed along with the user.
+PRAGMA defer_foreign_keys = ON;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_web_auth_demo_username_skeleton;
+
+ALTER TABLE tbl_web_auth_demo DROP COLUMN username_skeleton;
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "github.com/lib/pq"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+connectPostgres opens and verifies a connection to the PostgreSQL database at
+dbURL.
+
+Returns:
+
+- *SQLStore: The store backed by the database connection.
+
+- error: An error object if the connection cannot be established.
+*/
+func connectPostgres(dbURL string) (*SQLStore, error) {
+	logs.Logs(logDb, "Connecting to database...")
+	conn, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Could not connect to database: %s", err.Error()))
+		return nil, err
+	}
+
+	// verify connection
+	logs.Logs(logDb, "Verifying database connection...")
+	if conn == nil {
+		logs.Logs(logDbErr, "Database connection is empty!")
+		return nil, errors.New("database connection not established")
+	}
+	err = conn.Ping()
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Cannot ping database: %s", err.Error()))
+		return nil, err
+	}
+	logs.Logs(logDb, "Database connection established.")
+	return NewPostgresStore(conn), nil
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			codes := utils.GenerateRecoveryCodes(3)
+			if err := store.ReplaceRecoveryCodes("alice", codes); err != nil {
+				t.Fatal(err)
+			}
+			use := func(code string, want bool) {
+				t.Helper()
+				ok, err := store.UseRecoveryCode("alice", code)
+				if err != nil || ok != want {
+					t.Fatalf("UseRecoveryCode(%q) = %t, %v, want %t", code, ok, err, want)
+				}
+			}
+			count := func(want int) {
+				t.Helper()
+				if n, err := store.CountRecoveryCodes("alice"); err != nil || n != want {
+					t.Fatalf("CountRecoveryCodes = %d, %v, want %d", n, err, want)
+				}
+			}
+
+			count(3)
+			// typed in capitals, with spaces or without the hyphen
+			use(" "+strings.ToUpper(codes[0])+" ", true)
+			use(strings.ReplaceAll(codes[1], "-", ""), true)
+			count(1)
+			// each code works once
+			use(codes[0], false)
+			// codes belong to their user
+			if ok, err := store.UseRecoveryCode("bob", codes[2]); ok || err != nil {
+				t.Fatalf("UseRecoveryCode for another user = %t, %v, want false", ok, err)
+			}
+
+			// new codes replace every old one
+			fresh := utils.GenerateRecoveryCodes(2)
+			if err := store.ReplaceRecoveryCodes("alice", fresh); err != nil {
+				t.Fatal(err)
+			}
+			count(2)
+			use(codes[2], false)
+			use(fresh[0], true)
+			count(1)
+		})
+	}
+}
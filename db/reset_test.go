@@ -0,0 +1,80 @@
+package db
+
+import "testing"
+
+func TestPasswordReset(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := store.CreatePasswordReset("nobody"); err != ErrUserNotFound {
+				t.Fatalf("CreatePasswordReset of an unknown user = %v, want ErrUserNotFound", err)
+			}
+			reset, err := store.CreatePasswordReset("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// looking a reset up leaves it usable
+			for i := 0; i < 2; i++ {
+				found, err := store.GetPasswordReset(reset.Token)
+				if err != nil || found.Username != "alice" {
+					t.Fatalf("GetPasswordReset = %+v, %v", found, err)
+				}
+			}
+			if _, err := store.GetPasswordReset("not-a-token"); err != ErrResetNotFound {
+				t.Fatalf("GetPasswordReset of an unknown token = %v, want ErrResetNotFound", err)
+			}
+
+			used, err := store.ConsumePasswordReset(reset.Token)
+			if err != nil || used.Username != "alice" {
+				t.Fatalf("ConsumePasswordReset = %+v, %v", used, err)
+			}
+			// each reset works once
+			if _, err := store.GetPasswordReset(reset.Token); err != ErrResetNotFound {
+				t.Fatalf("GetPasswordReset after use = %v, want ErrResetNotFound", err)
+			}
+			if _, err := store.ConsumePasswordReset(reset.Token); err != ErrResetNotFound {
+				t.Fatalf("reusing the token = %v, want ErrResetNotFound", err)
+			}
+		})
+	}
+}
+
+func TestPasswordResetReplaced(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first, err := store.CreatePasswordReset("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := store.CreatePasswordReset("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.ConsumePasswordReset(first.Token); err != ErrResetNotFound {
+				t.Fatalf("ConsumePasswordReset of a replaced token = %v, want ErrResetNotFound", err)
+			}
+			if _, err := store.ConsumePasswordReset(second.Token); err != nil {
+				t.Fatalf("ConsumePasswordReset of the newest token: %v", err)
+			}
+		})
+	}
+}
+
+func TestPasswordResetExpiry(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			reset, err := store.CreatePasswordReset("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			expireTokens(t, store)
+
+			if _, err := store.GetPasswordReset(reset.Token); err != ErrResetNotFound {
+				t.Fatalf("GetPasswordReset of an expired token = %v, want ErrResetNotFound", err)
+			}
+			if _, err := store.ConsumePasswordReset(reset.Token); err != ErrResetNotFound {
+				t.Fatalf("ConsumePasswordReset of an expired token = %v, want ErrResetNotFound", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// storedSessionTokens returns the session and CSRF token columns of a
+// session row and whether they hold hashes.
+func storedSessionTokens(t *testing.T, store *SQLStore, sessionID string) (string, string, bool) {
+	t.Helper()
+	var sessionToken, csrfToken string
+	var hashed bool
+	query := `SELECT session_token, csrf_token, token_hashed FROM tbl_web_auth_sessions WHERE session_id=$1`
+	if err := store.db.QueryRow(query, sessionID).Scan(&sessionToken, &csrfToken, &hashed); err != nil {
+		t.Fatal(err)
+	}
+	return sessionToken, csrfToken, hashed
+}
+
+func TestSQLSessionTokensHashed(t *testing.T) {
+	store := testStores(t)["sqlite"].(*SQLStore)
+	session, err := store.CreateSession("alice", "192.0.2.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionToken, csrfToken, hashed := storedSessionTokens(t, store, session.ID)
+	if !hashed || sessionToken != utils.HashToken(session.Token) || csrfToken != utils.HashToken(session.CSRFToken) {
+		t.Fatalf("stored tokens %q, %q, hashed %t, want the hashes of the issued tokens", sessionToken, csrfToken, hashed)
+	}
+
+	found, err := store.GetSession(session.Token)
+	if err != nil || found.ID != session.ID || found.Username != "alice" {
+		t.Fatalf("GetSession = %+v, %v", found, err)
+	}
+	// the stored hash does not work as a token
+	if _, err := store.GetSession(sessionToken); err != ErrSessionNotFound {
+		t.Fatalf("GetSession of the stored hash = %v, want ErrSessionNotFound", err)
+	}
+	if ok, err := store.ValidateCSRFToken(session.ID, session.CSRFToken); err != nil || !ok {
+		t.Fatalf("ValidateCSRFToken = %t, %v, want true", ok, err)
+	}
+	if ok, _ := store.ValidateCSRFToken(session.ID, csrfToken); ok {
+		t.Fatal("the stored CSRF hash validated as a token")
+	}
+}
+
+func TestSQLLegacySessionRehashed(t *testing.T) {
+	store := testStores(t)["sqlite"].(*SQLStore)
+	// a session stored before tokens were hashed
+	now := time.Now().UTC()
+	query := `
+	INSERT INTO tbl_web_auth_sessions
+		(session_id, session_token, csrf_token, token_hashed, username, created_at, expires_at, last_seen_at, ip_address, user_agent)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := store.db.Exec(query, "legacy", "raw-session-token", "raw-csrf-token", false,
+		"alice", now, now.Add(time.Hour), now, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := store.ValidateCSRFToken("legacy", "raw-csrf-token"); err != nil || !ok {
+		t.Fatalf("ValidateCSRFToken of a legacy session = %t, %v, want true", ok, err)
+	}
+	found, err := store.GetSession("raw-session-token")
+	if err != nil || found.ID != "legacy" || found.Username != "alice" {
+		t.Fatalf("GetSession of a legacy session = %+v, %v", found, err)
+	}
+
+	// the first lookup rewrote the row to hashes
+	sessionToken, csrfToken, hashed := storedSessionTokens(t, store, "legacy")
+	if !hashed || sessionToken != utils.HashToken("raw-session-token") || csrfToken != utils.HashToken("raw-csrf-token") {
+		t.Fatalf("stored tokens after use %q, %q, hashed %t, want hashes", sessionToken, csrfToken, hashed)
+	}
+	// and the client's tokens keep working
+	if found, err := store.GetSession("raw-session-token"); err != nil || found.ID != "legacy" {
+		t.Fatalf("GetSession after rehashing = %+v, %v", found, err)
+	}
+	if ok, err := store.ValidateCSRFToken("legacy", "raw-csrf-token"); err != nil || !ok {
+		t.Fatalf("ValidateCSRFToken after rehashing = %t, %v, want true", ok, err)
+	}
+	if _, err := store.GetSession(sessionToken); err != ErrSessionNotFound {
+		t.Fatalf("GetSession of the stored hash = %v, want ErrSessionNotFound", err)
+	}
+}
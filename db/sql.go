@@ -0,0 +1,533 @@
+package db
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+/*
+SQLStore is the Store implementation backed by a database/sql connection. The
+same queries are used for PostgreSQL and SQLite; driver records which one the
+connection was opened with. Its schema is created by the embedded migrations
+(see Migrator).
+*/
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+}
+
+// NewPostgresStore wraps an open PostgreSQL connection in a SQLStore.
+func NewPostgresStore(conn *sql.DB) *SQLStore {
+	return &SQLStore{db: conn, driver: "postgres"}
+}
+
+// NewSQLiteStore wraps an open SQLite connection in a SQLStore.
+func NewSQLiteStore(conn *sql.DB) *SQLStore {
+	return &SQLStore{db: conn, driver: "sqlite"}
+}
+
+// Close closes the underlying database connection.
+func (s *SQLStore) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+/*
+CreateUser inserts a new user into the database with the provided username,
+email address and password. The password is hashed before being stored and
+the email address starts out unverified. It returns an error if hashing the
+password fails or if the database execution encounters an error.
+
+Returns:
+
+- error: ErrUserExists if the username is taken, ErrUsernameLookAlike if it
+has the skeleton of a taken one, ErrEmailExists if another user registered
+the email address, or an error if the database execution fails.
+*/
+func (s *SQLStore) CreateUser(username, email, password string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	hashedPwd, err := utils.HashedPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// the unique indexes have the final say, this gives clearer errors
+	var exists int
+	err = s.db.QueryRow(`SELECT 1 FROM tbl_web_auth_demo WHERE username=$1`, username).Scan(&exists)
+	if err == nil {
+		return ErrUserExists
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	skeleton := validation.UsernameSkeleton(username)
+	err = s.db.QueryRow(`SELECT 1 FROM tbl_web_auth_demo WHERE username_skeleton=$1`, skeleton).Scan(&exists)
+	if err == nil {
+		return ErrUsernameLookAlike
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+	if email != "" {
+		err = s.db.QueryRow(`SELECT 1 FROM tbl_web_auth_demo WHERE email=$1`, email).Scan(&exists)
+		if err == nil {
+			return ErrEmailExists
+		}
+		if err != sql.ErrNoRows {
+			return err
+		}
+	}
+
+	query := `INSERT INTO tbl_web_auth_demo (username, username_skeleton, email, hash_password) VALUES ($1, $2, $3, $4)`
+	_, err = s.db.Exec(query, username, skeleton, sql.NullString{String: email, Valid: email != ""}, hashedPwd)
+	return err
+}
+
+/*
+AuthenticateUser checks if the provided username and password match the stored credentials.
+
+It returns true if the credentials are correct, otherwise false. An error is returned if the query fails.
+
+Returns:
+
+- bool: True if the credentials are correct, otherwise false.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) AuthenticateUser(username, password string) (bool, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return false, ErrNotInitialized
+	}
+
+	var hashedPassword string
+	query := `SELECT hash_password FROM tbl_web_auth_demo WHERE username=$1`
+	err := s.db.QueryRow(query, username).Scan(&hashedPassword)
+	if err == sql.ErrNoRows {
+		// spend as long as for a real user, so timing does not tell them apart
+		utils.VerifyDummyPassword(password)
+		return false, ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ok, rehash := utils.VerifyPassword(password, hashedPassword)
+	if !ok {
+		return false, ErrInvalidPassword
+	}
+
+	// upgrade hashes made with an old algorithm or old parameters while the
+	// plain password is at hand, a failure only delays the upgrade
+	if rehash {
+		s.rehashPassword(username, password, hashedPassword)
+	}
+
+	return true, nil
+}
+
+// rehashPassword replaces a verified password hash with one made by the
+// current hasher, unless the password changed in the meantime.
+func (s *SQLStore) rehashPassword(username, password, oldHash string) {
+	newHash, err := utils.HashedPassword(password)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to rehash password for user %s: %s", username, err.Error()))
+		return
+	}
+
+	query := `UPDATE tbl_web_auth_demo SET hash_password=$1 WHERE username=$2 AND hash_password=$3`
+	_, err = s.db.Exec(query, newHash, username, oldHash)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to save rehashed password for user %s: %s", username, err.Error()))
+		return
+	}
+	logs.Logs(logDb, fmt.Sprintf("Password hash of user %s upgraded", username))
+}
+
+/*
+UpdatePassword replaces the user's password. The new password is hashed
+before being stored. Existing sessions are not touched; callers that reset a
+forgotten password should also call DeleteUserSessions.
+
+Returns:
+
+- error: ErrUserNotFound if the user does not exist, or an error if hashing
+the password or the update query fails.
+*/
+func (s *SQLStore) UpdatePassword(username, password string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	hashedPwd, err := utils.HashedPassword(password)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE tbl_web_auth_demo SET hash_password=$1 WHERE username=$2`
+	result, err := s.db.Exec(query, hashedPwd, username)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to update password: %s", err.Error()))
+		return err
+	}
+	return requireRow(result, ErrUserNotFound)
+}
+
+/*
+ListPepperKeyIDs collects the pepper key IDs recorded in the stored password
+hashes, so startup can refuse to run without a pepper that some are made with.
+
+Returns:
+
+- []string: The key IDs, sorted and without duplicates.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) ListPepperKeyIDs() ([]string, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return nil, ErrNotInitialized
+	}
+
+	query := `SELECT hash_password FROM tbl_web_auth_demo WHERE hash_password LIKE '%keyid=%'`
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var hashedPassword string
+		if err := rows.Scan(&hashedPassword); err != nil {
+			return nil, err
+		}
+		if id := utils.PepperKeyID(hashedPassword); id != "" {
+			seen[id] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortedKeys(seen), nil
+}
+
+/*
+GetEmail retrieves the user's email address and whether it has been verified.
+
+Returns:
+
+- string: The email address, empty if the user has none on file.
+
+- bool: True if the address has been verified.
+
+- error: ErrUserNotFound if the user does not exist, or an error if the query
+fails.
+*/
+func (s *SQLStore) GetEmail(username string) (string, bool, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return "", false, ErrNotInitialized
+	}
+
+	var email sql.NullString
+	var verifiedAt sql.NullTime
+	query := `SELECT email, email_verified_at FROM tbl_web_auth_demo WHERE username=$1`
+	err := s.db.QueryRow(query, username).Scan(&email, &verifiedAt)
+	if err == sql.ErrNoRows {
+		return "", false, ErrUserNotFound
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return email.String, verifiedAt.Valid, nil
+}
+
+/*
+GetUserID retrieves the id of the user's row in tbl_web_auth_demo.
+
+Returns:
+
+- int64: The user's id.
+
+- error: ErrUserNotFound if the user does not exist, or an error if the query
+fails.
+*/
+func (s *SQLStore) GetUserID(username string) (int64, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return 0, ErrNotInitialized
+	}
+
+	var id int64
+	err := s.db.QueryRow(`SELECT id FROM tbl_web_auth_demo WHERE username=$1`, username).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrUserNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+/*
+CreateSession issues a new session for the given user with fresh session and
+CSRF tokens, valid for 24 hours. Existing sessions of the user are left
+untouched, so the user can stay logged in on several devices. Only hashes of
+the tokens are written to the database.
+
+Returns:
+
+- Session: The new session, including its session and CSRF tokens.
+
+- error: An error if the insert query fails.
+*/
+func (s *SQLStore) CreateSession(username, ipAddress, userAgent string) (Session, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return Session{}, ErrNotInitialized
+	}
+
+	now := time.Now().UTC()
+	session := Session{
+		ID:         utils.GenerateToken(16),
+		Token:      utils.GenerateToken(32),
+		CSRFToken:  utils.GenerateToken(32),
+		Username:   username,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(sessionLifetime),
+		LastSeenAt: now,
+		IPAddress:  ipAddress,
+		UserAgent:  userAgent,
+	}
+
+	query := `
+	INSERT INTO tbl_web_auth_sessions
+		(session_id, session_token, csrf_token, token_hashed, username, created_at, expires_at, last_seen_at, ip_address, user_agent)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	_, err := s.db.Exec(query, session.ID, utils.HashToken(session.Token), utils.HashToken(session.CSRFToken), true,
+		session.Username, session.CreatedAt, session.ExpiresAt, session.LastSeenAt, session.IPAddress, session.UserAgent)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to create session: %s", err.Error()))
+		return Session{}, err
+	}
+
+	logs.Logs(logDb, "Session created successfully")
+	return session, nil
+}
+
+/*
+GetSession retrieves the session holding the given session token, looking it
+up by the token's hash. Sessions issued before tokens were hashed are found by
+their raw token instead and rewritten to hashes on the spot. Expired sessions
+are deleted and reported as not found. The session's last seen time is
+refreshed at most once a minute.
+
+Returns:
+
+- Session: The session the token belongs to. CSRFToken is left empty as only
+its hash is stored.
+
+- error: ErrSessionNotFound if no unexpired session holds the token, or an
+error if the query fails.
+*/
+func (s *SQLStore) GetSession(sessionToken string) (Session, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return Session{}, ErrNotInitialized
+	}
+
+	session, err := s.scanSession(utils.HashToken(sessionToken), true)
+	if err == sql.ErrNoRows {
+		// fall back to sessions stored before tokens were hashed
+		session, err = s.scanSession(sessionToken, false)
+		if err == nil {
+			err = s.hashLegacySession(session.ID)
+		}
+	}
+	if err == sql.ErrNoRows {
+		return Session{}, ErrSessionNotFound
+	}
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to get session: %s", err.Error()))
+		return Session{}, err
+	}
+	session.Token = sessionToken
+
+	now := time.Now().UTC()
+	if now.After(session.ExpiresAt) {
+		s.DeleteSession(session.ID)
+		return Session{}, ErrSessionNotFound
+	}
+
+	if now.Sub(session.LastSeenAt) >= lastSeenGranularity {
+		_, err = s.db.Exec(`UPDATE tbl_web_auth_sessions SET last_seen_at=$1 WHERE session_id=$2`, now, session.ID)
+		if err != nil {
+			logs.Logs(logDbErr, fmt.Sprintf("Failed to update session last seen time: %s", err.Error()))
+		} else {
+			session.LastSeenAt = now
+		}
+	}
+
+	return session, nil
+}
+
+// scanSession reads the session whose stored session_token equals
+// storedToken, limited to rows whose tokens are (or are not) hashed.
+func (s *SQLStore) scanSession(storedToken string, hashed bool) (Session, error) {
+	var session Session
+	query := `
+	SELECT s.session_id, u.id, s.username, s.created_at, s.expires_at, s.last_seen_at, s.ip_address, s.user_agent
+	FROM tbl_web_auth_sessions s
+	JOIN tbl_web_auth_demo u ON u.username = s.username
+	WHERE s.session_token = $1 AND s.token_hashed = $2
+	`
+	err := s.db.QueryRow(query, storedToken, hashed).Scan(&session.ID, &session.UserID, &session.Username,
+		&session.CreatedAt, &session.ExpiresAt, &session.LastSeenAt, &session.IPAddress, &session.UserAgent)
+	return session, err
+}
+
+// hashLegacySession replaces the raw session and CSRF tokens of a session
+// created before tokens were hashed with their hashes.
+func (s *SQLStore) hashLegacySession(sessionID string) error {
+	var sessionToken, csrfToken string
+	query := `SELECT session_token, csrf_token FROM tbl_web_auth_sessions WHERE session_id=$1 AND token_hashed=$2`
+	err := s.db.QueryRow(query, sessionID, false).Scan(&sessionToken, &csrfToken)
+	if err != nil {
+		return err
+	}
+
+	query = `UPDATE tbl_web_auth_sessions SET session_token=$1, csrf_token=$2, token_hashed=$3 WHERE session_id=$4 AND token_hashed=$5`
+	_, err = s.db.Exec(query, utils.HashToken(sessionToken), utils.HashToken(csrfToken), true, sessionID, false)
+	if err != nil {
+		return err
+	}
+	logs.Logs(logDb, fmt.Sprintf("Session %s migrated to hashed tokens", sessionID))
+	return nil
+}
+
+/*
+ValidateCSRFToken checks if the given CSRF token was issued with the given
+session. It returns true if the CSRF token is valid, otherwise false.
+
+Returns:
+
+- bool: True if the CSRF token is valid, otherwise false.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) ValidateCSRFToken(sessionID, csrfToken string) (bool, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return false, ErrNotInitialized
+	}
+
+	// query DB to get the stored CSRF token
+	var dbCSRFToken string
+	var hashed bool
+	query := `SELECT csrf_token, token_hashed FROM tbl_web_auth_sessions WHERE session_id=$1`
+	err := s.db.QueryRow(query, sessionID).Scan(&dbCSRFToken, &hashed)
+	if err == sql.ErrNoRows {
+		return false, ErrSessionNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// compare the input CSRF token with DB CSRF token, hashing it first
+	// unless the session predates hashed tokens
+	if hashed {
+		csrfToken = utils.HashToken(csrfToken)
+	}
+	return subtle.ConstantTimeCompare([]byte(csrfToken), []byte(dbCSRFToken)) == 1, nil
+}
+
+/*
+RotateCSRFToken replaces the CSRF token of the session with a new one. Tokens
+handed out before stop validating.
+
+Returns:
+
+- string: The new CSRF token.
+
+- error: ErrSessionNotFound if the session does not exist, or an error if
+the update fails.
+*/
+func (s *SQLStore) RotateCSRFToken(sessionID string) (string, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return "", ErrNotInitialized
+	}
+
+	csrfToken := utils.GenerateToken(32)
+	query := `UPDATE tbl_web_auth_sessions SET csrf_token=$1 WHERE session_id=$2 AND token_hashed=$3`
+	result, err := s.db.Exec(query, utils.HashToken(csrfToken), sessionID, true)
+	if err != nil {
+		return "", err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if rows == 0 {
+		return "", ErrSessionNotFound
+	}
+	return csrfToken, nil
+}
+
+/*
+DeleteSession removes a single session, logging the user out on the device
+that held it. Other sessions of the same user stay valid.
+
+Returns:
+
+- error: An error if the delete query fails.
+*/
+func (s *SQLStore) DeleteSession(sessionID string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	_, err := s.db.Exec(`DELETE FROM tbl_web_auth_sessions WHERE session_id=$1`, sessionID)
+	return err
+}
+
+/*
+DeleteUserSessions removes every session of the user, logging them out on all
+devices.
+
+Returns:
+
+- error: An error if the delete query fails.
+*/
+func (s *SQLStore) DeleteUserSessions(username string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	result, err := s.db.Exec(`DELETE FROM tbl_web_auth_sessions WHERE username=$1`, username)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to delete sessions: %s", err.Error()))
+		return err
+	}
+	if rows, err := result.RowsAffected(); err == nil {
+		logs.Logs(logDb, fmt.Sprintf("Deleted %d sessions of user %s", rows, username))
+	}
+	return nil
+}
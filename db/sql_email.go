@@ -0,0 +1,159 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+/*
+CreateEmailVerification issues a token to verify the user's current email
+address, valid for 24 hours. Earlier verifications of the user are deleted so
+only the newest emailed link works. To keep the endpoint that sends these
+from flooding an inbox, a new token is refused if the last one is less than a
+minute old. Only the hash of the token is stored.
+
+Returns:
+
+- EmailVerification: The verification, including the token to email the user.
+
+- error: ErrUserNotFound if the user does not exist, ErrNoEmail if they have
+no email address, ErrVerificationTooSoon if the last token is too recent, or
+an error if the query fails.
+*/
+func (s *SQLStore) CreateEmailVerification(username string) (EmailVerification, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return EmailVerification{}, ErrNotInitialized
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return EmailVerification{}, err
+	}
+	defer tx.Rollback()
+
+	var email sql.NullString
+	err = tx.QueryRow(`SELECT email FROM tbl_web_auth_demo WHERE username=$1`, username).Scan(&email)
+	if err == sql.ErrNoRows {
+		return EmailVerification{}, ErrUserNotFound
+	}
+	if err != nil {
+		return EmailVerification{}, err
+	}
+	if email.String == "" {
+		return EmailVerification{}, ErrNoEmail
+	}
+
+	now := time.Now().UTC()
+	var lastSent time.Time
+	query := `SELECT created_at FROM tbl_web_auth_email_verifications WHERE username=$1 ORDER BY created_at DESC LIMIT 1`
+	err = tx.QueryRow(query, username).Scan(&lastSent)
+	if err == nil && now.Sub(lastSent) < emailVerificationInterval {
+		return EmailVerification{}, ErrVerificationTooSoon
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return EmailVerification{}, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM tbl_web_auth_email_verifications WHERE username=$1`, username)
+	if err != nil {
+		return EmailVerification{}, err
+	}
+
+	verification := EmailVerification{
+		Token:     utils.GenerateToken(32),
+		Username:  username,
+		Email:     email.String,
+		CreatedAt: now,
+		ExpiresAt: now.Add(emailVerificationLifetime),
+	}
+	query = `
+	INSERT INTO tbl_web_auth_email_verifications (token_hash, username, email, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5)
+	`
+	_, err = tx.Exec(query, utils.HashToken(verification.Token), username, verification.Email,
+		verification.CreatedAt, verification.ExpiresAt)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to create email verification: %s", err.Error()))
+		return EmailVerification{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return EmailVerification{}, err
+	}
+	return verification, nil
+}
+
+/*
+ConsumeEmailVerification deletes the verification holding the given token and
+marks its email address as verified. If the user changed their address since
+the token was issued, the token no longer verifies anything.
+
+Returns:
+
+- EmailVerification: The verification the token belonged to.
+
+- error: ErrVerificationNotFound if no unexpired verification of the user's
+current address holds the token, or an error if the query fails.
+*/
+func (s *SQLStore) ConsumeEmailVerification(token string) (EmailVerification, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return EmailVerification{}, ErrNotInitialized
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return EmailVerification{}, err
+	}
+	defer tx.Rollback()
+
+	tokenHash := utils.HashToken(token)
+	verification := EmailVerification{Token: token}
+	query := `SELECT username, email, created_at, expires_at FROM tbl_web_auth_email_verifications WHERE token_hash=$1`
+	err = tx.QueryRow(query, tokenHash).Scan(&verification.Username, &verification.Email,
+		&verification.CreatedAt, &verification.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return EmailVerification{}, ErrVerificationNotFound
+	}
+	if err != nil {
+		return EmailVerification{}, err
+	}
+
+	// only the request that deletes the row may use the token
+	result, err := tx.Exec(`DELETE FROM tbl_web_auth_email_verifications WHERE token_hash=$1`, tokenHash)
+	if err != nil {
+		return EmailVerification{}, err
+	}
+	if err := requireRow(result, ErrVerificationNotFound); err != nil {
+		return EmailVerification{}, err
+	}
+
+	now := time.Now().UTC()
+	if now.After(verification.ExpiresAt) {
+		// keep the delete of the expired token
+		tx.Commit()
+		return EmailVerification{}, ErrVerificationNotFound
+	}
+
+	query = `UPDATE tbl_web_auth_demo SET email_verified_at=$1 WHERE username=$2 AND email=$3`
+	result, err = tx.Exec(query, now, verification.Username, verification.Email)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to verify email: %s", err.Error()))
+		return EmailVerification{}, err
+	}
+	if err := requireRow(result, ErrVerificationNotFound); err != nil {
+		return EmailVerification{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return EmailVerification{}, err
+	}
+	return verification, nil
+}
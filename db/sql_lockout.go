@@ -0,0 +1,101 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+GetLoginFailures retrieves the number of failed logins in a row for the
+username and the time of the last one.
+
+Returns:
+
+- int: The number of failed logins, 0 if there are none.
+
+- time.Time: When the last login failed, the zero time if none did.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) GetLoginFailures(username string) (int, time.Time, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return 0, time.Time{}, ErrNotInitialized
+	}
+
+	var failures int
+	var lastFailed time.Time
+	query := `SELECT failed_logins, last_failed_at FROM tbl_web_auth_login_failures WHERE username=$1`
+	err := s.db.QueryRow(query, username).Scan(&failures, &lastFailed)
+	if err == sql.ErrNoRows {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return failures, lastFailed, nil
+}
+
+/*
+RecordLoginFailure counts a failed login for the username in a single
+statement, so concurrent guesses cannot lose a count. If the previous failure
+is older than since, counting starts over at one. Rows of other usernames
+whose last failure is older than since are cleaned up on the way.
+
+Returns:
+
+- int: The number of failed logins in a row, including this one.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) RecordLoginFailure(username string, since time.Time) (int, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return 0, ErrNotInitialized
+	}
+
+	now := time.Now().UTC()
+	var failures int
+	query := `
+	INSERT INTO tbl_web_auth_login_failures (username, failed_logins, last_failed_at)
+	VALUES ($1, 1, $2)
+	ON CONFLICT (username) DO UPDATE SET
+		failed_logins = CASE
+			WHEN tbl_web_auth_login_failures.last_failed_at < $3 THEN 1
+			ELSE tbl_web_auth_login_failures.failed_logins + 1
+		END,
+		last_failed_at = $2
+	RETURNING failed_logins
+	`
+	err := s.db.QueryRow(query, username, now, since.UTC()).Scan(&failures)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to record login failure: %s", err.Error()))
+		return 0, err
+	}
+
+	_, err = s.db.Exec(`DELETE FROM tbl_web_auth_login_failures WHERE last_failed_at < $1`, since.UTC())
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to clean up old login failures: %s", err.Error()))
+	}
+	return failures, nil
+}
+
+/*
+ResetLoginFailures forgets the failed logins of the username.
+
+Returns:
+
+- error: An error if the delete query fails.
+*/
+func (s *SQLStore) ResetLoginFailures(username string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	_, err := s.db.Exec(`DELETE FROM tbl_web_auth_login_failures WHERE username=$1`, username)
+	return err
+}
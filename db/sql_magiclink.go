@@ -0,0 +1,144 @@
+package db
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+/*
+CreateMagicLink issues a passwordless login link for the user with the given
+email address, valid for 15 minutes. Earlier links of the user are deleted so
+only the newest one works. Only hashes of the link and browser tokens are
+stored.
+
+Returns:
+
+- MagicLink: The link, including the tokens to hand the user and browser.
+
+- error: ErrUserNotFound if no user has the email address, or an error if the
+query fails.
+*/
+func (s *SQLStore) CreateMagicLink(email string) (MagicLink, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return MagicLink{}, ErrNotInitialized
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return MagicLink{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	link := MagicLink{
+		Token:        utils.GenerateToken(32),
+		BrowserToken: utils.GenerateToken(32),
+		Email:        email,
+		ExpiresAt:    now.Add(MagicLinkLifetime),
+	}
+	err = tx.QueryRow(`SELECT username FROM tbl_web_auth_demo WHERE email=$1`, email).Scan(&link.Username)
+	if err == sql.ErrNoRows {
+		return MagicLink{}, ErrUserNotFound
+	}
+	if err != nil {
+		return MagicLink{}, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM tbl_web_auth_magic_links WHERE username=$1`, link.Username)
+	if err != nil {
+		return MagicLink{}, err
+	}
+
+	query := `
+	INSERT INTO tbl_web_auth_magic_links (token_hash, browser_hash, username, email, created_at, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err = tx.Exec(query, utils.HashToken(link.Token), utils.HashToken(link.BrowserToken), link.Username,
+		link.Email, now, link.ExpiresAt)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to create magic link: %s", err.Error()))
+		return MagicLink{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return MagicLink{}, err
+	}
+	return link, nil
+}
+
+/*
+ConsumeMagicLink retrieves the link holding the given token and deletes it,
+so each link can only be used once. The link is left alone if browserToken
+does not match, so a forwarded or prefetched link cannot use it up before its
+owner does. As the link could only be read from the user's inbox, using it
+also verifies the email address it was sent to.
+
+Returns:
+
+- MagicLink: The link the token belongs to.
+
+- error: ErrMagicLinkNotFound if no unexpired link holds the token or the
+browser token does not match, or an error if the query fails.
+*/
+func (s *SQLStore) ConsumeMagicLink(token, browserToken string) (MagicLink, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return MagicLink{}, ErrNotInitialized
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return MagicLink{}, err
+	}
+	defer tx.Rollback()
+
+	tokenHash := utils.HashToken(token)
+	link := MagicLink{Token: token, BrowserToken: browserToken}
+	var browserHash string
+	query := `SELECT browser_hash, username, email, expires_at FROM tbl_web_auth_magic_links WHERE token_hash=$1`
+	err = tx.QueryRow(query, tokenHash).Scan(&browserHash, &link.Username, &link.Email, &link.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return MagicLink{}, ErrMagicLinkNotFound
+	}
+	if err != nil {
+		return MagicLink{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(utils.HashToken(browserToken)), []byte(browserHash)) != 1 {
+		return MagicLink{}, ErrMagicLinkNotFound
+	}
+
+	// only the request that deletes the row may use the link
+	result, err := tx.Exec(`DELETE FROM tbl_web_auth_magic_links WHERE token_hash=$1`, tokenHash)
+	if err != nil {
+		return MagicLink{}, err
+	}
+	if err := requireRow(result, ErrMagicLinkNotFound); err != nil {
+		return MagicLink{}, err
+	}
+
+	now := time.Now().UTC()
+	if now.After(link.ExpiresAt) {
+		// keep the delete of the expired link
+		tx.Commit()
+		return MagicLink{}, ErrMagicLinkNotFound
+	}
+
+	query = `UPDATE tbl_web_auth_demo SET email_verified_at=$1 WHERE username=$2 AND email=$3 AND email_verified_at IS NULL`
+	_, err = tx.Exec(query, now, link.Username, link.Email)
+	if err != nil {
+		return MagicLink{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return MagicLink{}, err
+	}
+	return link, nil
+}
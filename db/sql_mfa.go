@@ -0,0 +1,242 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+/*
+SetTOTPSecret stores a new TOTP secret for the user. The second factor stays
+disabled until EnableTOTP is called, so an unfinished enrollment never locks
+the user out.
+
+Returns:
+
+- error: ErrUserNotFound if the user does not exist, or an error if the
+update query fails.
+*/
+func (s *SQLStore) SetTOTPSecret(username, secret string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	query := `UPDATE tbl_web_auth_demo SET totp_secret=$1, totp_enabled=$2, totp_last_step=0 WHERE username=$3`
+	result, err := s.db.Exec(query, secret, false, username)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to store TOTP secret: %s", err.Error()))
+		return err
+	}
+	return requireRow(result, ErrUserNotFound)
+}
+
+/*
+EnableTOTP turns on TOTP for the user after they confirmed their secret with
+a valid code.
+
+Returns:
+
+- error: ErrUserNotFound if the user has no TOTP secret, or an error if the
+update query fails.
+*/
+func (s *SQLStore) EnableTOTP(username string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	query := `UPDATE tbl_web_auth_demo SET totp_enabled=$1 WHERE username=$2 AND totp_secret IS NOT NULL`
+	result, err := s.db.Exec(query, true, username)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to enable TOTP: %s", err.Error()))
+		return err
+	}
+	return requireRow(result, ErrUserNotFound)
+}
+
+/*
+GetTOTP retrieves the TOTP secret of the user and whether it is enabled.
+
+Returns:
+
+- string: The base32 TOTP secret, empty if the user never enrolled.
+
+- bool: True if TOTP is enabled for the user.
+
+- error: ErrUserNotFound if the user does not exist, or an error if the
+query fails.
+*/
+func (s *SQLStore) GetTOTP(username string) (string, bool, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return "", false, ErrNotInitialized
+	}
+
+	var secret sql.NullString
+	var enabled bool
+	query := `SELECT totp_secret, totp_enabled FROM tbl_web_auth_demo WHERE username=$1`
+	err := s.db.QueryRow(query, username).Scan(&secret, &enabled)
+	if err == sql.ErrNoRows {
+		return "", false, ErrUserNotFound
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return secret.String, enabled, nil
+}
+
+/*
+UseTOTPStep records that a code from the given time step was used. It only
+succeeds if the step is newer than the last one used, so each code can log
+in at most once.
+
+Returns:
+
+- bool: True if the step had not been used yet.
+
+- error: An error if the update query fails.
+*/
+func (s *SQLStore) UseTOTPStep(username string, step int64) (bool, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return false, ErrNotInitialized
+	}
+
+	query := `UPDATE tbl_web_auth_demo SET totp_last_step=$1 WHERE username=$2 AND totp_last_step < $1`
+	result, err := s.db.Exec(query, step, username)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+/*
+CreateMFAChallenge starts a pending second factor login for the user, valid
+for five minutes. Only the hash of the challenge token is stored.
+
+Returns:
+
+- MFAChallenge: The new challenge, including the token to hand the client.
+
+- error: An error if the insert query fails.
+*/
+func (s *SQLStore) CreateMFAChallenge(username string) (MFAChallenge, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return MFAChallenge{}, ErrNotInitialized
+	}
+
+	challenge := MFAChallenge{
+		ID:        utils.GenerateToken(16),
+		Token:     utils.GenerateToken(32),
+		Username:  username,
+		ExpiresAt: time.Now().UTC().Add(mfaChallengeLifetime),
+	}
+
+	query := `INSERT INTO tbl_web_auth_mfa_challenges (challenge_id, token_hash, username, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err := s.db.Exec(query, challenge.ID, utils.HashToken(challenge.Token), challenge.Username, challenge.ExpiresAt)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to create MFA challenge: %s", err.Error()))
+		return MFAChallenge{}, err
+	}
+	return challenge, nil
+}
+
+/*
+GetMFAChallenge retrieves the challenge holding the given token. Expired
+challenges are deleted and reported as not found.
+
+Returns:
+
+- MFAChallenge: The challenge the token belongs to.
+
+- error: ErrChallengeNotFound if no unexpired challenge holds the token, or
+an error if the query fails.
+*/
+func (s *SQLStore) GetMFAChallenge(token string) (MFAChallenge, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return MFAChallenge{}, ErrNotInitialized
+	}
+
+	var challenge MFAChallenge
+	query := `SELECT challenge_id, username, expires_at, attempts FROM tbl_web_auth_mfa_challenges WHERE token_hash=$1`
+	err := s.db.QueryRow(query, utils.HashToken(token)).Scan(&challenge.ID, &challenge.Username, &challenge.ExpiresAt, &challenge.Attempts)
+	if err == sql.ErrNoRows {
+		return MFAChallenge{}, ErrChallengeNotFound
+	}
+	if err != nil {
+		return MFAChallenge{}, err
+	}
+
+	if time.Now().After(challenge.ExpiresAt) {
+		s.DeleteMFAChallenge(challenge.ID)
+		return MFAChallenge{}, ErrChallengeNotFound
+	}
+	challenge.Token = token
+	return challenge, nil
+}
+
+/*
+CountMFAChallengeAttempt counts a second factor entered for the challenge,
+before it is checked, so concurrent attempts cannot all pass as the first.
+
+Returns:
+
+- int: The number of attempts so far, this one included.
+
+- error: ErrChallengeNotFound if the challenge does not exist, or an error
+if the query fails.
+*/
+func (s *SQLStore) CountMFAChallengeAttempt(challengeID string) (int, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return 0, ErrNotInitialized
+	}
+
+	var attempts int
+	query := `UPDATE tbl_web_auth_mfa_challenges SET attempts = attempts + 1 WHERE challenge_id=$1 RETURNING attempts`
+	err := s.db.QueryRow(query, challengeID).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return 0, ErrChallengeNotFound
+	}
+	return attempts, err
+}
+
+/*
+DeleteMFAChallenge removes a challenge once the login completed or was
+abandoned.
+
+Returns:
+
+- error: An error if the delete query fails.
+*/
+func (s *SQLStore) DeleteMFAChallenge(challengeID string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	_, err := s.db.Exec(`DELETE FROM tbl_web_auth_mfa_challenges WHERE challenge_id=$1`, challengeID)
+	return err
+}
+
+// requireRow returns notFound if the statement did not affect any row.
+func requireRow(result sql.Result, notFound error) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+/*
+ReplaceRecoveryCodes deletes every recovery code of the user and stores the
+new ones in a single transaction. Codes are normalized and only their hashes
+are stored, so they can only be shown to the user once.
+
+Returns:
+
+- error: An error if the codes cannot be replaced.
+*/
+func (s *SQLStore) ReplaceRecoveryCodes(username string, codes []string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM tbl_web_auth_recovery_codes WHERE username=$1`, username)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to delete recovery codes: %s", err.Error()))
+		return err
+	}
+
+	now := time.Now().UTC()
+	query := `INSERT INTO tbl_web_auth_recovery_codes (username, code_hash, created_at) VALUES ($1, $2, $3)`
+	for _, code := range codes {
+		_, err = tx.Exec(query, username, utils.HashToken(utils.NormalizeRecoveryCode(code)), now)
+		if err != nil {
+			logs.Logs(logDbErr, fmt.Sprintf("Failed to store recovery code: %s", err.Error()))
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+/*
+UseRecoveryCode marks an unused recovery code of the user as used. The update
+only matches codes that were not used yet, so two requests racing with the
+same code cannot both succeed.
+
+Returns:
+
+- bool: True if the code was valid and unused.
+
+- error: An error if the update query fails.
+*/
+func (s *SQLStore) UseRecoveryCode(username, code string) (bool, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return false, ErrNotInitialized
+	}
+
+	query := `
+	UPDATE tbl_web_auth_recovery_codes SET used_at=$1
+	WHERE username=$2 AND code_hash=$3 AND used_at IS NULL
+	`
+	result, err := s.db.Exec(query, time.Now().UTC(), username, utils.HashToken(utils.NormalizeRecoveryCode(code)))
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to use recovery code: %s", err.Error()))
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rows == 1, nil
+}
+
+/*
+CountRecoveryCodes counts the recovery codes the user has not used yet.
+
+Returns:
+
+- int: The number of unused codes.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) CountRecoveryCodes(username string) (int, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return 0, ErrNotInitialized
+	}
+
+	var count int
+	query := `SELECT COUNT(*) FROM tbl_web_auth_recovery_codes WHERE username=$1 AND used_at IS NULL`
+	err := s.db.QueryRow(query, username).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+/*
+CreatePasswordReset issues a password reset token for the user, valid for one
+hour. Earlier resets of the user are deleted so only the newest emailed link
+works. Only the hash of the token is stored.
+
+Returns:
+
+- PasswordReset: The reset, including the token to email the user.
+
+- error: ErrUserNotFound if the user does not exist, or an error if the
+query fails.
+*/
+func (s *SQLStore) CreatePasswordReset(username string) (PasswordReset, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return PasswordReset{}, ErrNotInitialized
+	}
+
+	now := time.Now().UTC()
+	reset := PasswordReset{
+		Token:     utils.GenerateToken(32),
+		Username:  username,
+		ExpiresAt: now.Add(passwordResetLifetime),
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return PasswordReset{}, err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(`SELECT 1 FROM tbl_web_auth_demo WHERE username=$1`, username).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return PasswordReset{}, ErrUserNotFound
+	}
+	if err != nil {
+		return PasswordReset{}, err
+	}
+
+	_, err = tx.Exec(`DELETE FROM tbl_web_auth_password_resets WHERE username=$1`, username)
+	if err != nil {
+		return PasswordReset{}, err
+	}
+
+	query := `INSERT INTO tbl_web_auth_password_resets (token_hash, username, created_at, expires_at) VALUES ($1, $2, $3, $4)`
+	_, err = tx.Exec(query, utils.HashToken(reset.Token), username, now, reset.ExpiresAt)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to create password reset: %s", err.Error()))
+		return PasswordReset{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return PasswordReset{}, err
+	}
+	return reset, nil
+}
+
+/*
+GetPasswordReset retrieves the reset holding the given token without deleting
+it, so the new password can be checked before the token is used up.
+
+Returns:
+
+- PasswordReset: The reset the token belongs to.
+
+- error: ErrResetNotFound if no unexpired reset holds the token, or an error
+if the query fails.
+*/
+func (s *SQLStore) GetPasswordReset(token string) (PasswordReset, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return PasswordReset{}, ErrNotInitialized
+	}
+
+	reset := PasswordReset{Token: token}
+	query := `SELECT username, expires_at FROM tbl_web_auth_password_resets WHERE token_hash=$1`
+	err := s.db.QueryRow(query, utils.HashToken(token)).Scan(&reset.Username, &reset.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return PasswordReset{}, ErrResetNotFound
+	}
+	if err != nil {
+		return PasswordReset{}, err
+	}
+
+	if time.Now().After(reset.ExpiresAt) {
+		return PasswordReset{}, ErrResetNotFound
+	}
+	return reset, nil
+}
+
+/*
+ConsumePasswordReset retrieves the reset holding the given token and deletes
+it, so each emailed link can only be used once.
+
+Returns:
+
+- PasswordReset: The reset the token belongs to.
+
+- error: ErrResetNotFound if no unexpired reset holds the token, or an error
+if the query fails.
+*/
+func (s *SQLStore) ConsumePasswordReset(token string) (PasswordReset, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return PasswordReset{}, ErrNotInitialized
+	}
+
+	tokenHash := utils.HashToken(token)
+	reset := PasswordReset{Token: token}
+	query := `SELECT username, expires_at FROM tbl_web_auth_password_resets WHERE token_hash=$1`
+	err := s.db.QueryRow(query, tokenHash).Scan(&reset.Username, &reset.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return PasswordReset{}, ErrResetNotFound
+	}
+	if err != nil {
+		return PasswordReset{}, err
+	}
+
+	// only the request that deletes the row may use the token
+	result, err := s.db.Exec(`DELETE FROM tbl_web_auth_password_resets WHERE token_hash=$1`, tokenHash)
+	if err != nil {
+		return PasswordReset{}, err
+	}
+	if err := requireRow(result, ErrResetNotFound); err != nil {
+		return PasswordReset{}, err
+	}
+
+	if time.Now().After(reset.ExpiresAt) {
+		return PasswordReset{}, ErrResetNotFound
+	}
+	return reset, nil
+}
@@ -0,0 +1,101 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+RevokeSession adds the session ID to the revocation list until expiresAt.
+Revoking a session twice is a no-op.
+
+Returns:
+
+- error: An error if the insert query fails.
+*/
+func (s *SQLStore) RevokeSession(sessionID string, expiresAt time.Time) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	query := `
+	INSERT INTO tbl_web_auth_session_revocations (kind, subject, revoked_at, expires_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (kind, subject) DO NOTHING
+	`
+	_, err := s.db.Exec(query, RevokedSession, sessionID, time.Now().UTC(), expiresAt.UTC())
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to revoke session: %s", err.Error()))
+	}
+	return err
+}
+
+/*
+RevokeUserSessions adds the username to the revocation list, ending every
+session issued to the user before revokedAt. A later revocation of the same
+user replaces the earlier one.
+
+Returns:
+
+- error: An error if the insert query fails.
+*/
+func (s *SQLStore) RevokeUserSessions(username string, revokedAt, expiresAt time.Time) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	query := `
+	INSERT INTO tbl_web_auth_session_revocations (kind, subject, revoked_at, expires_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (kind, subject) DO UPDATE SET revoked_at = $3, expires_at = $4
+	`
+	_, err := s.db.Exec(query, RevokedUser, username, revokedAt.UTC(), expiresAt.UTC())
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to revoke user sessions: %s", err.Error()))
+	}
+	return err
+}
+
+/*
+ListRevocations retrieves the revocation list, first deleting the entries
+that have expired.
+
+Returns:
+
+- []Revocation: The entries that have not expired.
+
+- error: An error if a query fails.
+*/
+func (s *SQLStore) ListRevocations() ([]Revocation, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return nil, ErrNotInitialized
+	}
+
+	now := time.Now().UTC()
+	_, err := s.db.Exec(`DELETE FROM tbl_web_auth_session_revocations WHERE expires_at <= $1`, now)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to clean up expired revocations: %s", err.Error()))
+	}
+
+	rows, err := s.db.Query(`SELECT kind, subject, revoked_at, expires_at FROM tbl_web_auth_session_revocations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revocations []Revocation
+	for rows.Next() {
+		var revocation Revocation
+		err = rows.Scan(&revocation.Kind, &revocation.Subject, &revocation.RevokedAt, &revocation.ExpiresAt)
+		if err != nil {
+			return nil, err
+		}
+		revocations = append(revocations, revocation)
+	}
+	return revocations, rows.Err()
+}
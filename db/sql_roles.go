@@ -0,0 +1,195 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+GetRoles retrieves the names of the roles granted to the user.
+
+Returns:
+
+- []string: The role names, sorted. Empty if the user has no roles.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) GetRoles(username string) ([]string, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return nil, ErrNotInitialized
+	}
+
+	query := `SELECT role FROM tbl_web_auth_user_roles WHERE username=$1 ORDER BY role`
+	return s.queryStrings(query, username)
+}
+
+/*
+GetPermissions retrieves every permission carried by the roles granted to the
+user.
+
+Returns:
+
+- []string: The permissions, sorted and without duplicates. Empty if the user
+has none.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) GetPermissions(username string) ([]string, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return nil, ErrNotInitialized
+	}
+
+	query := `SELECT DISTINCT p.permission FROM tbl_web_auth_user_roles u
+		JOIN tbl_web_auth_role_permissions p ON p.role = u.role
+		WHERE u.username=$1 ORDER BY p.permission`
+	return s.queryStrings(query, username)
+}
+
+/*
+AssignRole grants the role to the user. Granting a role the user already
+holds changes nothing.
+
+Returns:
+
+- error: ErrUserNotFound if the user does not exist, ErrRoleNotFound if the
+role does not exist, or an error if the query fails.
+*/
+func (s *SQLStore) AssignRole(username, role string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow(`SELECT 1 FROM tbl_web_auth_demo WHERE username=$1`, username).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	err = tx.QueryRow(`SELECT 1 FROM tbl_web_auth_roles WHERE name=$1`, role).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrRoleNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO tbl_web_auth_user_roles (username, role, granted_at) VALUES ($1, $2, $3)
+		ON CONFLICT (username, role) DO NOTHING`
+	_, err = tx.Exec(query, username, role, time.Now().UTC())
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to assign role: %s", err.Error()))
+		return err
+	}
+	return tx.Commit()
+}
+
+/*
+RevokeRole takes the role from the user. Revoking a role the user does not
+hold changes nothing.
+
+Returns:
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) RevokeRole(username, role string) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	_, err := s.db.Exec(`DELETE FROM tbl_web_auth_user_roles WHERE username=$1 AND role=$2`, username, role)
+	return err
+}
+
+/*
+ListUsers retrieves every user with their email address and roles.
+
+Returns:
+
+- []UserSummary: The users, sorted by username.
+
+- error: An error if a query fails.
+*/
+func (s *SQLStore) ListUsers() ([]UserSummary, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return nil, ErrNotInitialized
+	}
+
+	rows, err := s.db.Query(`SELECT username, email, email_verified_at FROM tbl_web_auth_demo ORDER BY username`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []UserSummary
+	index := make(map[string]int) // username -> position in users
+	for rows.Next() {
+		var user UserSummary
+		var email sql.NullString
+		var verifiedAt sql.NullTime
+		err = rows.Scan(&user.Username, &email, &verifiedAt)
+		if err != nil {
+			return nil, err
+		}
+		user.Email = email.String
+		user.EmailVerified = verifiedAt.Valid
+		index[user.Username] = len(users)
+		users = append(users, user)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// one query for the roles of everyone rather than one per user
+	roleRows, err := s.db.Query(`SELECT username, role FROM tbl_web_auth_user_roles ORDER BY role`)
+	if err != nil {
+		return nil, err
+	}
+	defer roleRows.Close()
+	for roleRows.Next() {
+		var username, role string
+		err = roleRows.Scan(&username, &role)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[username]; ok {
+			users[i].Roles = append(users[i].Roles, role)
+		}
+	}
+	return users, roleRows.Err()
+}
+
+// queryStrings runs a query selecting a single text column.
+func (s *SQLStore) queryStrings(query string, args ...any) ([]string, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		err = rows.Scan(&value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
@@ -0,0 +1,225 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+/*
+AddWebAuthnCredential stores a newly registered WebAuthn credential for its
+user.
+
+Returns:
+
+- error: ErrCredentialExists if the credential ID is already registered, or
+an error if the insert query fails.
+*/
+func (s *SQLStore) AddWebAuthnCredential(cred WebAuthnCredential) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	_, err := s.GetWebAuthnCredential(cred.ID)
+	if err == nil {
+		return ErrCredentialExists
+	}
+	if err != ErrCredentialNotFound {
+		return err
+	}
+
+	query := `
+	INSERT INTO tbl_web_auth_credentials
+		(credential_id, username, user_handle, public_key, sign_count, transports, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	_, err = s.db.Exec(query, cred.ID, cred.Username, cred.UserHandle, cred.PublicKey, int64(cred.SignCount),
+		strings.Join(cred.Transports, ","), time.Now().UTC())
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to store WebAuthn credential: %s", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// scanCredential reads a credential row selected with credentialColumns.
+func scanCredential(row interface{ Scan(...any) error }) (WebAuthnCredential, error) {
+	var cred WebAuthnCredential
+	var signCount int64
+	var transports string
+	var lastUsed sql.NullTime
+	err := row.Scan(&cred.ID, &cred.Username, &cred.UserHandle, &cred.PublicKey, &signCount, &transports, &cred.CreatedAt, &lastUsed)
+	if err != nil {
+		return WebAuthnCredential{}, err
+	}
+	cred.SignCount = uint32(signCount)
+	if transports != "" {
+		cred.Transports = strings.Split(transports, ",")
+	}
+	cred.LastUsedAt = lastUsed.Time
+	return cred, nil
+}
+
+const credentialColumns = `credential_id, username, user_handle, public_key, sign_count, transports, created_at, last_used_at`
+
+/*
+GetWebAuthnCredential retrieves a credential by its base64url credential ID.
+
+Returns:
+
+- WebAuthnCredential: The stored credential.
+
+- error: ErrCredentialNotFound if no credential has the ID, or an error if
+the query fails.
+*/
+func (s *SQLStore) GetWebAuthnCredential(credentialID string) (WebAuthnCredential, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return WebAuthnCredential{}, ErrNotInitialized
+	}
+
+	query := `SELECT ` + credentialColumns + ` FROM tbl_web_auth_credentials WHERE credential_id=$1`
+	cred, err := scanCredential(s.db.QueryRow(query, credentialID))
+	if err == sql.ErrNoRows {
+		return WebAuthnCredential{}, ErrCredentialNotFound
+	}
+	return cred, err
+}
+
+/*
+ListWebAuthnCredentials retrieves every credential registered to the user,
+oldest first.
+
+Returns:
+
+- []WebAuthnCredential: The user's credentials, empty if there are none.
+
+- error: An error if the query fails.
+*/
+func (s *SQLStore) ListWebAuthnCredentials(username string) ([]WebAuthnCredential, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return nil, ErrNotInitialized
+	}
+
+	query := `SELECT ` + credentialColumns + ` FROM tbl_web_auth_credentials WHERE username=$1 ORDER BY created_at`
+	rows, err := s.db.Query(query, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var creds []WebAuthnCredential
+	for rows.Next() {
+		cred, err := scanCredential(rows)
+		if err != nil {
+			return nil, err
+		}
+		creds = append(creds, cred)
+	}
+	return creds, rows.Err()
+}
+
+/*
+UpdateWebAuthnSignCount stores the signature counter reported by the
+authenticator on a successful login and records when it was used.
+
+Returns:
+
+- error: ErrCredentialNotFound if the credential does not exist, or an error
+if the update query fails.
+*/
+func (s *SQLStore) UpdateWebAuthnSignCount(credentialID string, signCount uint32) error {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return ErrNotInitialized
+	}
+
+	query := `UPDATE tbl_web_auth_credentials SET sign_count=$1, last_used_at=$2 WHERE credential_id=$3`
+	result, err := s.db.Exec(query, int64(signCount), time.Now().UTC(), credentialID)
+	if err != nil {
+		return err
+	}
+	return requireRow(result, ErrCredentialNotFound)
+}
+
+/*
+CreateWebAuthnCeremony stores a registration or login ceremony, valid for
+five minutes. Only the hash of the ceremony token is stored.
+
+Returns:
+
+- WebAuthnCeremony: The ceremony, including the token to hand the client.
+
+- error: An error if the insert query fails.
+*/
+func (s *SQLStore) CreateWebAuthnCeremony(ceremony WebAuthnCeremony) (WebAuthnCeremony, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return WebAuthnCeremony{}, ErrNotInitialized
+	}
+
+	ceremony.Token = utils.GenerateToken(32)
+	ceremony.ExpiresAt = time.Now().UTC().Add(ceremonyLifetime)
+
+	query := `
+	INSERT INTO tbl_web_auth_webauthn_ceremonies (token_hash, kind, username, challenge, user_handle, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	_, err := s.db.Exec(query, utils.HashToken(ceremony.Token), ceremony.Kind, ceremony.Username, ceremony.Challenge,
+		ceremony.UserHandle, ceremony.ExpiresAt)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Failed to create WebAuthn ceremony: %s", err.Error()))
+		return WebAuthnCeremony{}, err
+	}
+	return ceremony, nil
+}
+
+/*
+ConsumeWebAuthnCeremony retrieves the ceremony holding the given token and
+deletes it, so each challenge can only be answered once.
+
+Returns:
+
+- WebAuthnCeremony: The ceremony the token belongs to.
+
+- error: ErrCeremonyNotFound if no unexpired ceremony holds the token, or an
+error if the query fails.
+*/
+func (s *SQLStore) ConsumeWebAuthnCeremony(token string) (WebAuthnCeremony, error) {
+	if s.db == nil {
+		logs.Logs(logDbErr, "Database connection is not initialized")
+		return WebAuthnCeremony{}, ErrNotInitialized
+	}
+
+	tokenHash := utils.HashToken(token)
+	ceremony := WebAuthnCeremony{Token: token}
+	query := `SELECT kind, username, challenge, user_handle, expires_at FROM tbl_web_auth_webauthn_ceremonies WHERE token_hash=$1`
+	err := s.db.QueryRow(query, tokenHash).Scan(&ceremony.Kind, &ceremony.Username, &ceremony.Challenge,
+		&ceremony.UserHandle, &ceremony.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return WebAuthnCeremony{}, ErrCeremonyNotFound
+	}
+	if err != nil {
+		return WebAuthnCeremony{}, err
+	}
+
+	// only the request that deletes the row may use the challenge
+	result, err := s.db.Exec(`DELETE FROM tbl_web_auth_webauthn_ceremonies WHERE token_hash=$1`, tokenHash)
+	if err != nil {
+		return WebAuthnCeremony{}, err
+	}
+	if err := requireRow(result, ErrCeremonyNotFound); err != nil {
+		return WebAuthnCeremony{}, err
+	}
+
+	if time.Now().After(ceremony.ExpiresAt) {
+		return WebAuthnCeremony{}, ErrCeremonyNotFound
+	}
+	return ceremony, nil
+}
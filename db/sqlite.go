@@ -0,0 +1,57 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+connectSQLite opens the SQLite database file named by a sqlite:// URL, such
+as sqlite://./auth.db, creating the file if it does not exist yet. The schema
+itself is created by the migrations.
+
+Returns:
+
+- *SQLStore: The store backed by the database file.
+
+- error: An error object if the database cannot be opened.
+*/
+func connectSQLite(dbURL string) (*SQLStore, error) {
+	path := strings.TrimPrefix(dbURL, "sqlite://")
+	if path == "" {
+		logs.Logs(logDbErr, "SQLite database path is empty!")
+		return nil, fmt.Errorf("sqlite database path is empty")
+	}
+
+	// foreign keys are off by default in SQLite and concurrent writers
+	// should wait for the lock rather than fail immediately
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	dsn := fmt.Sprintf("file:%s%s_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)", path, separator)
+
+	logs.Logs(logDb, fmt.Sprintf("Opening SQLite database %s...", path))
+	conn, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Could not open SQLite database: %s", err.Error()))
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising through one connection also
+	// keeps :memory: databases shared across queries
+	conn.SetMaxOpenConns(1)
+
+	err = conn.Ping()
+	if err != nil {
+		logs.Logs(logDbErr, fmt.Sprintf("Cannot open SQLite database: %s", err.Error()))
+		conn.Close()
+		return nil, err
+	}
+	logs.Logs(logDb, "SQLite database ready.")
+	return NewSQLiteStore(conn), nil
+}
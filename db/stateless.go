@@ -0,0 +1,353 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// statelessTokenName is the cookie name session tokens are sealed for, so a
+// token cannot be passed off as any other sealed cookie.
+const statelessTokenName = "session_token"
+
+// statelessCSRFName is the name CSRF tokens are signed for.
+const statelessCSRFName = "session_csrf"
+
+// statelessClaims is what a stateless session token carries.
+type statelessClaims struct {
+	SessionID   string    `json:"sid"`
+	UserID      int64     `json:"uid"`
+	Username    string    `json:"sub"`
+	IssuedAt    time.Time `json:"iat"`
+	ExpiresAt   time.Time `json:"exp"`
+	Roles       []string  `json:"roles,omitempty"`
+	Permissions []string  `json:"perms,omitempty"`
+}
+
+// StatelessConfig holds the settings of a StatelessStore.
+type StatelessConfig struct {
+	Codec        *utils.CookieCodec // seals and opens the session tokens
+	Revocations  bool               // check the revocation list, so sessions can end before they expire
+	RefreshEvery time.Duration      // how often the list is reloaded to pick up other instances' revocations
+}
+
+/*
+StatelessStore wraps a Store so sessions live in their token instead of the
+database. The token is sealed with the cookie codec and carries the session
+ID, the user's ID, name, roles and permissions and the expiry, and the CSRF
+token is signed from the session ID with the same keys, so authenticating a request needs no
+query at all.
+
+As nothing is stored, ending a session early means listing it on the
+revocation list. The list only holds revoked sessions, is kept in memory and
+reloaded every RefreshEvery, so a logout on another instance takes up to that
+long to apply there. One request reloads it while the others keep checking
+the previous list; until the first list has loaded, every token is refused.
+Without the list, logging out only clears the cookie.
+
+Roles and permissions are read at login: a granted role applies from the next
+login, and revoking a role ends the user's sessions so it applies at once.
+Every other method is passed on to the wrapped Store.
+*/
+type StatelessStore struct {
+	Store
+	config StatelessConfig
+
+	reloadMu        sync.Mutex           // held by the request reloading the list
+	mu              sync.RWMutex         // guards the fields below
+	revokedSessions map[string]bool      // session ID -> revoked
+	revokedUsers    map[string]time.Time // username -> sessions issued before this are revoked
+	recent          []localRevocation    // revocations made here, kept until a reload has seen them
+	loaded          bool                 // whether the list was ever loaded
+	loadedAt        time.Time            // when the list was last reloaded
+}
+
+// localRevocation is a revocation made by this instance.
+type localRevocation struct {
+	revocation Revocation
+	at         time.Time
+}
+
+// NewStatelessStore wraps the store to keep sessions in their tokens.
+func NewStatelessStore(store Store, config StatelessConfig) *StatelessStore {
+	return &StatelessStore{
+		Store:           store,
+		config:          config,
+		revokedSessions: make(map[string]bool),
+		revokedUsers:    make(map[string]time.Time),
+	}
+}
+
+/*
+CreateSession issues a new session token for the given user, valid for 24
+hours. The user's id, roles and permissions are looked up once and sealed
+into the token.
+
+Returns:
+
+- Session: The new session, including its session and CSRF tokens.
+
+- error: An error if the user cannot be looked up or the token sealed.
+*/
+func (s *StatelessStore) CreateSession(username, ipAddress, userAgent string) (Session, error) {
+	userID, err := s.Store.GetUserID(username)
+	if err != nil {
+		return Session{}, err
+	}
+	roles, err := s.Store.GetRoles(username)
+	if err != nil {
+		return Session{}, err
+	}
+	permissions, err := s.Store.GetPermissions(username)
+	if err != nil {
+		return Session{}, err
+	}
+
+	now := time.Now().UTC()
+	claims := statelessClaims{
+		SessionID:   utils.GenerateToken(16),
+		UserID:      userID,
+		Username:    username,
+		IssuedAt:    now,
+		ExpiresAt:   now.Add(sessionLifetime),
+		Roles:       roles,
+		Permissions: permissions,
+	}
+	token, err := s.config.Codec.Encode(statelessTokenName, claims, claims.ExpiresAt)
+	if err != nil {
+		return Session{}, err
+	}
+
+	session := claims.session()
+	session.Token = token
+	session.CSRFToken = s.csrfToken(claims.SessionID)
+	session.IPAddress = ipAddress
+	session.UserAgent = userAgent
+	return session, nil
+}
+
+/*
+GetSession opens the session token, refusing it if it was tampered with, has
+expired or is on the revocation list.
+
+Returns:
+
+- Session: The session the token belongs to.
+
+- error: ErrSessionNotFound if the token is not a valid, unrevoked session.
+*/
+func (s *StatelessStore) GetSession(sessionToken string) (Session, error) {
+	var claims statelessClaims
+	err := s.config.Codec.Decode(statelessTokenName, sessionToken, &claims)
+	if err != nil {
+		if !errors.Is(err, utils.ErrCookieExpired) {
+			logs.Logs(logWarning, fmt.Sprintf("Refused session token: %s", err.Error()))
+		}
+		return Session{}, ErrSessionNotFound
+	}
+
+	if s.config.Revocations && s.isRevoked(claims) {
+		return Session{}, ErrSessionNotFound
+	}
+
+	session := claims.session()
+	session.Token = sessionToken
+	return session, nil
+}
+
+/*
+DeleteSession ends the session by adding it to the revocation list, where it
+stays until the token would have expired.
+
+Returns:
+
+- error: An error if the revocation cannot be stored.
+*/
+func (s *StatelessStore) DeleteSession(sessionID string) error {
+	if !s.config.Revocations {
+		return nil
+	}
+	err := s.Store.RevokeSession(sessionID, time.Now().UTC().Add(sessionLifetime))
+	if err != nil {
+		return err
+	}
+
+	s.recordRevocation(Revocation{Kind: RevokedSession, Subject: sessionID})
+	return nil
+}
+
+/*
+DeleteUserSessions ends every session of the user, revoking the tokens
+issued until now along with any sessions still in the database from before
+stateless mode was turned on. Without the revocation list the tokens stay
+valid until they expire.
+
+Returns:
+
+- error: An error if the sessions cannot be deleted or revoked.
+*/
+func (s *StatelessStore) DeleteUserSessions(username string) error {
+	err := s.Store.DeleteUserSessions(username)
+	if err != nil || !s.config.Revocations {
+		return err
+	}
+
+	now := time.Now().UTC()
+	err = s.Store.RevokeUserSessions(username, now, now.Add(sessionLifetime))
+	if err != nil {
+		return err
+	}
+
+	s.recordRevocation(Revocation{Kind: RevokedUser, Subject: username, RevokedAt: now})
+	return nil
+}
+
+// ValidateCSRFToken checks the CSRF token against the one signed from the
+// session ID, with any of the cookie keys.
+func (s *StatelessStore) ValidateCSRFToken(sessionID, csrfToken string) (bool, error) {
+	return s.config.Codec.Verify(statelessCSRFName, sessionID, csrfToken), nil
+}
+
+// RotateCSRFToken returns the CSRF token signed from the session ID again;
+// it cannot change without changing the session.
+func (s *StatelessStore) RotateCSRFToken(sessionID string) (string, error) {
+	return s.csrfToken(sessionID), nil
+}
+
+/*
+RevokeRole takes the role from the user and ends their sessions, which would
+otherwise keep the role until they expire.
+
+Returns:
+
+- error: An error if the role cannot be revoked or the sessions ended.
+*/
+func (s *StatelessStore) RevokeRole(username, role string) error {
+	err := s.Store.RevokeRole(username, role)
+	if err != nil {
+		return err
+	}
+	return s.DeleteUserSessions(username)
+}
+
+// isRevoked reports whether the session is on the revocation list,
+// reloading the list first if it is older than RefreshEvery.
+func (s *StatelessStore) isRevoked(claims statelessClaims) bool {
+	s.refreshRevocations()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.loaded {
+		return true
+	}
+	if s.revokedSessions[claims.SessionID] {
+		return true
+	}
+	revokedAt, ok := s.revokedUsers[claims.Username]
+	return ok && !claims.IssuedAt.After(revokedAt)
+}
+
+// refreshRevocations reloads the revocation list if it is older than
+// RefreshEvery. The query runs without holding mu, and only one request runs
+// it: the others keep using the current list, or wait for the first one. If
+// the list cannot be reloaded the previous one is kept until the next refresh.
+func (s *StatelessStore) refreshRevocations() {
+	s.mu.RLock()
+	stale, loaded := time.Since(s.loadedAt) >= s.config.RefreshEvery, s.loaded
+	s.mu.RUnlock()
+	if !stale {
+		return
+	}
+	if loaded {
+		if !s.reloadMu.TryLock() {
+			return
+		}
+	} else {
+		s.reloadMu.Lock()
+	}
+	defer s.reloadMu.Unlock()
+
+	// another request may have reloaded it while this one waited
+	s.mu.RLock()
+	stale = time.Since(s.loadedAt) >= s.config.RefreshEvery
+	s.mu.RUnlock()
+	if !stale {
+		return
+	}
+
+	started := time.Now()
+	revocations, err := s.Store.ListRevocations()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to reload session revocation list: %s", err.Error()))
+		if s.loaded {
+			s.loadedAt = started
+		}
+		return
+	}
+
+	s.revokedSessions = make(map[string]bool)
+	s.revokedUsers = make(map[string]time.Time)
+	for _, revocation := range revocations {
+		s.applyRevocation(revocation)
+	}
+	// revocations made here while the query ran may be missing from it
+	recent := s.recent[:0]
+	for _, local := range s.recent {
+		if !local.at.Before(started) {
+			s.applyRevocation(local.revocation)
+			recent = append(recent, local)
+		}
+	}
+	s.recent = recent
+	s.loaded, s.loadedAt = true, started
+}
+
+// recordRevocation adds a revocation this instance just stored to the list,
+// so it applies here at once.
+func (s *StatelessStore) recordRevocation(revocation Revocation) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.applyRevocation(revocation)
+	s.recent = append(s.recent, localRevocation{revocation: revocation, at: time.Now()})
+}
+
+// applyRevocation adds a revocation to the in-memory list. The caller must
+// hold mu.
+func (s *StatelessStore) applyRevocation(revocation Revocation) {
+	switch revocation.Kind {
+	case RevokedSession:
+		s.revokedSessions[revocation.Subject] = true
+	case RevokedUser:
+		if revocation.RevokedAt.After(s.revokedUsers[revocation.Subject]) {
+			s.revokedUsers[revocation.Subject] = revocation.RevokedAt
+		}
+	}
+}
+
+// session returns the Session the claims describe.
+func (c statelessClaims) session() Session {
+	return Session{
+		ID:          c.SessionID,
+		UserID:      c.UserID,
+		Username:    c.Username,
+		CreatedAt:   c.IssuedAt,
+		ExpiresAt:   c.ExpiresAt,
+		LastSeenAt:  c.IssuedAt,
+		Roles:       c.Roles,
+		Permissions: c.Permissions,
+		HasRoles:    true,
+	}
+}
+
+// csrfToken signs the CSRF token of a stateless session from its ID with the
+// cookie keys, so it needs no storage and every instance derives the same one.
+func (s *StatelessStore) csrfToken(sessionID string) string {
+	return s.config.Codec.Sign(statelessCSRFName, sessionID)
+}
@@ -0,0 +1,228 @@
+package db
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// newStatelessInstance returns a StatelessStore over store, as run by one
+// instance behind a load balancer: each has its own codec from the shared
+// keys and its own copy of the revocation list.
+func newStatelessInstance(t *testing.T, store Store) *StatelessStore {
+	codec, err := utils.NewCookieCodec(map[string][]byte{"k1": bytes.Repeat([]byte{1}, 32)}, "k1")
+	if err != nil {
+		t.Fatalf("NewCookieCodec: %v", err)
+	}
+	return NewStatelessStore(store, StatelessConfig{Codec: codec, Revocations: true, RefreshEvery: time.Hour})
+}
+
+// newStatelessUser returns a MemoryStore holding the user alice.
+func newStatelessUser(t *testing.T) *MemoryStore {
+	store := NewMemoryStore()
+	err := store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93")
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return store
+}
+
+// expireRevocations makes the next request reload the revocation list.
+func expireRevocations(s *StatelessStore) {
+	s.mu.Lock()
+	s.loadedAt = time.Time{}
+	s.mu.Unlock()
+}
+
+func createSession(t *testing.T, s *StatelessStore) Session {
+	session, err := s.CreateSession("alice", "127.0.0.1", "test")
+	if err != nil {
+		t.Fatalf("CreateSession: %v", err)
+	}
+	return session
+}
+
+func TestStatelessCSRFAcrossInstances(t *testing.T) {
+	store := newStatelessUser(t)
+	first, second := newStatelessInstance(t, store), newStatelessInstance(t, store)
+
+	fromFirst := createSession(t, first)
+	fromSecond := createSession(t, second)
+	for _, test := range []struct {
+		instance *StatelessStore
+		session  Session
+	}{
+		{second, fromFirst},
+		{first, fromSecond},
+	} {
+		session, err := test.instance.GetSession(test.session.Token)
+		if err != nil {
+			t.Fatalf("GetSession on the other instance: %v", err)
+		}
+		valid, err := test.instance.ValidateCSRFToken(session.ID, test.session.CSRFToken)
+		if err != nil || !valid {
+			t.Errorf("ValidateCSRFToken on the other instance = %v, %v, want true", valid, err)
+		}
+		rotated, err := test.instance.RotateCSRFToken(session.ID)
+		if err != nil || rotated != test.session.CSRFToken {
+			t.Errorf("RotateCSRFToken on the other instance = %q, %v, want %q", rotated, err, test.session.CSRFToken)
+		}
+	}
+
+	// the token belongs to its own session only
+	valid, _ := first.ValidateCSRFToken(fromFirst.ID, fromSecond.CSRFToken)
+	if valid {
+		t.Error("ValidateCSRFToken accepted the token of another session")
+	}
+}
+
+func TestStatelessRevokeThenReload(t *testing.T) {
+	store := newStatelessUser(t)
+	first, second := newStatelessInstance(t, store), newStatelessInstance(t, store)
+	session := createSession(t, first)
+	if _, err := second.GetSession(session.Token); err != nil {
+		t.Fatalf("GetSession before logout: %v", err)
+	}
+
+	err := first.DeleteSession(session.ID)
+	if err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	// the logout applies at once here, and on the other instance only once
+	// it reloads the list
+	if _, err := first.GetSession(session.Token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession after logout = %v, want %v", err, ErrSessionNotFound)
+	}
+	if _, err := second.GetSession(session.Token); err != nil {
+		t.Errorf("GetSession on the other instance before it reloads = %v, want nil", err)
+	}
+	expireRevocations(second)
+	if _, err := second.GetSession(session.Token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession on the other instance after it reloads = %v, want %v", err, ErrSessionNotFound)
+	}
+
+	// reloading keeps the revocation here as well
+	expireRevocations(first)
+	if _, err := first.GetSession(session.Token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession after reloading = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+// slowRevocations returns the revocation list as it was when it was asked
+// for, but only once released, like a query that misses a revocation stored
+// while it runs.
+type slowRevocations struct {
+	*MemoryStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s slowRevocations) ListRevocations() ([]Revocation, error) {
+	revocations, err := s.MemoryStore.ListRevocations()
+	s.started <- struct{}{}
+	<-s.release
+	return revocations, err
+}
+
+func TestStatelessRevokeDuringReload(t *testing.T) {
+	store := slowRevocations{MemoryStore: newStatelessUser(t), started: make(chan struct{}), release: make(chan struct{})}
+	instance := newStatelessInstance(t, store)
+	session := createSession(t, instance)
+
+	reloaded := make(chan error)
+	go func() {
+		_, err := instance.GetSession(session.Token)
+		reloaded <- err
+	}()
+	<-store.started
+	err := instance.DeleteSession(session.ID)
+	if err != nil {
+		t.Fatalf("DeleteSession: %v", err)
+	}
+	close(store.release)
+	<-reloaded
+
+	// the reloaded list missed the logout, which must still apply
+	if _, err := instance.GetSession(session.Token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession after a reload that missed the logout = %v, want %v", err, ErrSessionNotFound)
+	}
+	expireRevocations(instance)
+	go func() { <-store.started }()
+	if _, err := instance.GetSession(session.Token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession after the next reload = %v, want %v", err, ErrSessionNotFound)
+	}
+	instance.mu.RLock()
+	recent := len(instance.recent)
+	instance.mu.RUnlock()
+	if recent != 0 {
+		t.Errorf("%d local revocations kept after a reload saw them, want 0", recent)
+	}
+}
+
+func TestStatelessRevokeUserSessions(t *testing.T) {
+	store := newStatelessUser(t)
+	first, second := newStatelessInstance(t, store), newStatelessInstance(t, store)
+	before := createSession(t, first)
+
+	err := first.DeleteUserSessions("alice")
+	if err != nil {
+		t.Fatalf("DeleteUserSessions: %v", err)
+	}
+	after := createSession(t, first)
+
+	expireRevocations(second)
+	for _, instance := range []*StatelessStore{first, second} {
+		if _, err := instance.GetSession(before.Token); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("GetSession issued before the revocation = %v, want %v", err, ErrSessionNotFound)
+		}
+		if _, err := instance.GetSession(after.Token); err != nil {
+			t.Errorf("GetSession issued after the revocation = %v, want nil", err)
+		}
+	}
+}
+
+func TestStatelessRevokeRole(t *testing.T) {
+	store := newStatelessUser(t)
+	instance := newStatelessInstance(t, store)
+	err := store.AssignRole("alice", "admin")
+	if err != nil {
+		t.Fatalf("AssignRole: %v", err)
+	}
+	admin := createSession(t, instance)
+	session, err := instance.GetSession(admin.Token)
+	if err != nil || len(session.Permissions) != 1 || session.Permissions[0] != "admin:users" {
+		t.Fatalf("GetSession = %+v, %v, want the admin:users permission", session, err)
+	}
+
+	err = instance.RevokeRole("alice", "admin")
+	if err != nil {
+		t.Fatalf("RevokeRole: %v", err)
+	}
+	if _, err := instance.GetSession(admin.Token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession after RevokeRole = %v, want %v", err, ErrSessionNotFound)
+	}
+	session, err = instance.GetSession(createSession(t, instance).Token)
+	if err != nil || len(session.Roles) != 0 || len(session.Permissions) != 0 {
+		t.Errorf("GetSession of a new session = %+v, %v, want no roles", session, err)
+	}
+}
+
+// failingRevocations cannot load the revocation list.
+type failingRevocations struct {
+	*MemoryStore
+}
+
+func (failingRevocations) ListRevocations() ([]Revocation, error) {
+	return nil, errors.New("database is down")
+}
+
+func TestStatelessRefusesUntilLoaded(t *testing.T) {
+	instance := newStatelessInstance(t, failingRevocations{newStatelessUser(t)})
+	session := createSession(t, instance)
+	if _, err := instance.GetSession(session.Token); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("GetSession before the list ever loaded = %v, want %v", err, ErrSessionNotFound)
+	}
+}
@@ -0,0 +1,227 @@
+package db
+
+import "time"
+
+/*
+UserStore persists user accounts and their hashed passwords.
+*/
+type UserStore interface {
+	// CreateUser stores a new user with an unverified email address, hashing
+	// the password before it is saved. Usernames with the same
+	// validation.UsernameSkeleton as an existing one are refused.
+	CreateUser(username, email, password string) error
+	// AuthenticateUser reports whether the password matches the stored hash.
+	AuthenticateUser(username, password string) (bool, error)
+	// UpdatePassword replaces the user's password, hashing it before it is
+	// saved.
+	UpdatePassword(username, password string) error
+	// GetEmail returns the user's email address, empty if none is on file,
+	// and whether it has been verified.
+	GetEmail(username string) (string, bool, error)
+	// GetUserID returns the id of the user's row, for tokens that carry it.
+	GetUserID(username string) (int64, error)
+	// ListPepperKeyIDs returns the key IDs of the peppers stored password
+	// hashes were made with, sorted and without duplicates.
+	ListPepperKeyIDs() ([]string, error)
+}
+
+/*
+SessionStore persists the login sessions issued to users. A user may hold
+any number of sessions at once, one per browser or device.
+*/
+type SessionStore interface {
+	// CreateSession issues a new session for the user. The returned session
+	// carries the session and CSRF tokens to hand to the client.
+	CreateSession(username, ipAddress, userAgent string) (Session, error)
+	// GetSession looks up an unexpired session by its token and records it
+	// as seen.
+	GetSession(sessionToken string) (Session, error)
+	// DeleteSession ends a single session, leaving the user's others intact.
+	DeleteSession(sessionID string) error
+	// DeleteUserSessions ends every session of the user, logging them out on
+	// all devices.
+	DeleteUserSessions(username string) error
+}
+
+/*
+CSRFStore validates the CSRF tokens issued alongside a session.
+*/
+type CSRFStore interface {
+	// ValidateCSRFToken reports whether the CSRF token belongs to the session.
+	ValidateCSRFToken(sessionID, csrfToken string) (bool, error)
+	// RotateCSRFToken issues the session a new CSRF token, for clients that
+	// lost the one handed out at login.
+	RotateCSRFToken(sessionID string) (string, error)
+}
+
+/*
+MFAStore persists TOTP second factor enrollment and the challenges of logins
+that passed the password check and still need a second factor.
+*/
+type MFAStore interface {
+	// SetTOTPSecret stores a new, not yet confirmed TOTP secret for the user.
+	SetTOTPSecret(username, secret string) error
+	// EnableTOTP turns on the second factor once the user confirmed a code.
+	EnableTOTP(username string) error
+	// GetTOTP returns the user's TOTP secret and whether it is enabled.
+	GetTOTP(username string) (string, bool, error)
+	// UseTOTPStep records a used time step, returning false if it (or a later
+	// step) was already used so codes cannot be replayed.
+	UseTOTPStep(username string, step int64) (bool, error)
+
+	// ReplaceRecoveryCodes discards the user's recovery codes and stores the
+	// hashes of the new ones.
+	ReplaceRecoveryCodes(username string, codes []string) error
+	// UseRecoveryCode spends an unused recovery code, returning false if the
+	// code is wrong or was already used.
+	UseRecoveryCode(username, code string) (bool, error)
+	// CountRecoveryCodes returns how many unused recovery codes the user has.
+	CountRecoveryCodes(username string) (int, error)
+
+	// CreateMFAChallenge starts a pending second factor login for the user.
+	CreateMFAChallenge(username string) (MFAChallenge, error)
+	// GetMFAChallenge looks up an unexpired challenge by its token.
+	GetMFAChallenge(token string) (MFAChallenge, error)
+	// CountMFAChallengeAttempt counts a code entered for the challenge,
+	// before it is checked, and returns the total.
+	CountMFAChallengeAttempt(challengeID string) (int, error)
+	// DeleteMFAChallenge removes a challenge once it is used or abandoned.
+	DeleteMFAChallenge(challengeID string) error
+}
+
+/*
+WebAuthnStore persists registered WebAuthn credentials (passkeys and security
+keys) and the challenges of registration and login ceremonies in progress.
+*/
+type WebAuthnStore interface {
+	// AddWebAuthnCredential stores a newly registered credential.
+	AddWebAuthnCredential(cred WebAuthnCredential) error
+	// GetWebAuthnCredential looks up a credential by its base64url ID.
+	GetWebAuthnCredential(credentialID string) (WebAuthnCredential, error)
+	// ListWebAuthnCredentials returns every credential the user registered.
+	ListWebAuthnCredentials(username string) ([]WebAuthnCredential, error)
+	// UpdateWebAuthnSignCount records a successful login with the credential.
+	UpdateWebAuthnSignCount(credentialID string, signCount uint32) error
+
+	// CreateWebAuthnCeremony stores a ceremony in progress and returns it with
+	// the token to hand the client.
+	CreateWebAuthnCeremony(ceremony WebAuthnCeremony) (WebAuthnCeremony, error)
+	// ConsumeWebAuthnCeremony returns and deletes an unexpired ceremony.
+	ConsumeWebAuthnCeremony(token string) (WebAuthnCeremony, error)
+}
+
+/*
+PasswordResetStore persists the single-use tokens emailed to users who forgot
+their password.
+*/
+type PasswordResetStore interface {
+	// CreatePasswordReset issues a reset token for the user, replacing any
+	// earlier one.
+	CreatePasswordReset(username string) (PasswordReset, error)
+	// GetPasswordReset returns an unexpired reset without using it up.
+	GetPasswordReset(token string) (PasswordReset, error)
+	// ConsumePasswordReset returns and deletes an unexpired reset.
+	ConsumePasswordReset(token string) (PasswordReset, error)
+}
+
+/*
+EmailVerificationStore persists the tokens emailed to users to verify their
+address.
+*/
+type EmailVerificationStore interface {
+	// CreateEmailVerification issues a verification token for the user's
+	// current email address, replacing any earlier one. It returns
+	// ErrVerificationTooSoon if the last one was issued under a minute ago.
+	CreateEmailVerification(username string) (EmailVerification, error)
+	// ConsumeEmailVerification deletes an unexpired verification and marks
+	// its address as verified.
+	ConsumeEmailVerification(token string) (EmailVerification, error)
+}
+
+/*
+MagicLinkStore persists the passwordless login links emailed to users.
+*/
+type MagicLinkStore interface {
+	// CreateMagicLink issues a login link for the user with the email
+	// address, replacing any earlier one.
+	CreateMagicLink(email string) (MagicLink, error)
+	// ConsumeMagicLink returns and deletes an unexpired link, provided it is
+	// presented together with the browser token it was issued with.
+	ConsumeMagicLink(token, browserToken string) (MagicLink, error)
+}
+
+/*
+LoginFailureStore counts failed password logins per username so repeated
+guessing can be slowed down and locked out. Failures are counted for any
+username, whether or not an account exists.
+*/
+type LoginFailureStore interface {
+	// GetLoginFailures returns how many logins failed in a row for the
+	// username and when the last one failed, or zero values if none did.
+	GetLoginFailures(username string) (int, time.Time, error)
+	// RecordLoginFailure counts a failed login and returns the new total.
+	// Failures from before since are forgotten and counting starts over.
+	RecordLoginFailure(username string, since time.Time) (int, error)
+	// ResetLoginFailures forgets the username's failed logins, after a
+	// successful login or to unlock the account.
+	ResetLoginFailures(username string) error
+}
+
+/*
+RoleStore persists the roles granted to users and the permissions each role
+carries. Permissions are strings such as "admin:users"; a user holds the
+permissions of all their roles. Roles themselves are created by migrations.
+*/
+type RoleStore interface {
+	// GetRoles returns the names of the user's roles, sorted.
+	GetRoles(username string) ([]string, error)
+	// GetPermissions returns every permission of the user's roles, sorted and
+	// without duplicates.
+	GetPermissions(username string) ([]string, error)
+	// AssignRole grants the role to the user. Granting a held role is a no-op.
+	AssignRole(username, role string) error
+	// RevokeRole takes the role from the user. Revoking a role the user does
+	// not hold is a no-op.
+	RevokeRole(username, role string) error
+	// ListUsers returns every user with their roles, sorted by username.
+	ListUsers() ([]UserSummary, error)
+}
+
+/*
+RevocationStore persists the revocation list of stateless sessions. Those are
+not stored anywhere else, so the list is how logging out or resetting a
+password ends them before they expire.
+*/
+type RevocationStore interface {
+	// RevokeSession ends the session with the ID. The entry is kept until
+	// expiresAt, when the session would have ended anyway.
+	RevokeSession(sessionID string, expiresAt time.Time) error
+	// RevokeUserSessions ends every session issued to the user before
+	// revokedAt, keeping the entry until expiresAt.
+	RevokeUserSessions(username string, revokedAt, expiresAt time.Time) error
+	// ListRevocations returns every entry that has not expired, cleaning up
+	// the others.
+	ListRevocations() ([]Revocation, error)
+}
+
+/*
+Store is the full persistence layer used by the handlers and middleware.
+The PostgreSQL implementation is returned by ConnectDB; other backends only
+need to satisfy this interface to be plugged in.
+*/
+type Store interface {
+	UserStore
+	SessionStore
+	CSRFStore
+	MFAStore
+	WebAuthnStore
+	PasswordResetStore
+	EmailVerificationStore
+	MagicLinkStore
+	LoginFailureStore
+	RoleStore
+	RevocationStore
+
+	// Close releases any resources held by the store.
+	Close() error
+}
@@ -0,0 +1,52 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+// testStores returns a MemoryStore and a migrated SQLite store, each with
+// the user alice.
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	sqlite := newSQLiteTestStore(t)
+	if err := sqlite.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	stores := map[string]Store{"memory": NewMemoryStore(), "sqlite": sqlite}
+	for name, store := range stores {
+		if err := store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93"); err != nil {
+			t.Fatalf("%s: CreateUser: %v", name, err)
+		}
+	}
+	return stores
+}
+
+// expireTokens moves the expiry of every password reset, email verification
+// and magic link the store holds into the past.
+func expireTokens(t *testing.T, store Store) {
+	t.Helper()
+	past := time.Now().UTC().Add(-time.Second)
+	switch store := store.(type) {
+	case *MemoryStore:
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		for _, reset := range store.passwordResets {
+			reset.ExpiresAt = past
+		}
+		for _, verification := range store.emailVerifications {
+			verification.ExpiresAt = past
+		}
+		for _, link := range store.magicLinks {
+			link.ExpiresAt = past
+		}
+	case *SQLStore:
+		for _, table := range []string{"tbl_web_auth_password_resets", "tbl_web_auth_email_verifications", "tbl_web_auth_magic_links"} {
+			if _, err := store.db.Exec("UPDATE "+table+" SET expires_at=$1", past); err != nil {
+				t.Fatal(err)
+			}
+		}
+	default:
+		t.Fatalf("cannot expire the tokens of a %T", store)
+	}
+}
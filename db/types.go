@@ -1,6 +1,9 @@
 package db
 
-import "database/sql"
+import (
+	"errors"
+	"time"
+)
 
 const (
 	logWarning = 2
@@ -9,6 +12,168 @@ const (
 	logDbErr   = 5
 )
 
+const (
+	sessionLifetime           = 24 * time.Hour   // how long a session stays valid after login
+	lastSeenGranularity       = time.Minute      // minimum gap between last_seen_at updates
+	mfaChallengeLifetime      = 5 * time.Minute  // time allowed to enter the second factor
+	ceremonyLifetime          = 5 * time.Minute  // time allowed to finish a WebAuthn ceremony
+	passwordResetLifetime     = time.Hour        // how long an emailed password reset link works
+	emailVerificationLifetime = 24 * time.Hour   // how long an emailed verification link works
+	emailVerificationInterval = time.Minute      // minimum gap between verification emails to one user
+	MagicLinkLifetime         = 15 * time.Minute // how long an emailed login link works, also shown to users
+)
+
 var (
-	db *sql.DB // global DB variable to hold DB connection
+	ErrNotInitialized       = errors.New("database connection is not initialized")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrUserExists           = errors.New("user already exists")
+	ErrUsernameLookAlike    = errors.New("username looks like an existing one")
+	ErrSessionNotFound      = errors.New("session not found")
+	ErrChallengeNotFound    = errors.New("MFA challenge not found")
+	ErrCredentialNotFound   = errors.New("WebAuthn credential not found")
+	ErrCredentialExists     = errors.New("WebAuthn credential already registered")
+	ErrCeremonyNotFound     = errors.New("WebAuthn ceremony not found")
+	ErrResetNotFound        = errors.New("password reset not found")
+	ErrEmailExists          = errors.New("email address already registered")
+	ErrNoEmail              = errors.New("user has no email address")
+	ErrVerificationNotFound = errors.New("email verification not found")
+	ErrVerificationTooSoon  = errors.New("verification email sent too recently")
+	ErrMagicLinkNotFound    = errors.New("magic link not found")
+	ErrRoleNotFound         = errors.New("role not found")
+	ErrInvalidPassword      = errors.New("invalid password")
+)
+
+/*
+Session is a single login of a user on one browser or device. ID identifies
+the session and is safe to show to the user; Token and CSRFToken are the
+secrets handed to the client. Stores keep only hashes of the two tokens, so
+CSRFToken is only filled in on the session returned by CreateSession.
+
+Stateless sessions carry the user's roles and permissions from login, so
+they need not be looked up on every request; HasRoles is false for sessions
+kept in the database.
+*/
+type Session struct {
+	ID         string
+	Token      string
+	CSRFToken  string
+	UserID     int64 // id of the user in tbl_web_auth_demo, filled in by GetSession
+	Username   string
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+	LastSeenAt time.Time
+	IPAddress  string
+	UserAgent  string
+
+	Roles       []string
+	Permissions []string
+	HasRoles    bool
+}
+
+// Kinds of Revocation.
+const (
+	RevokedSession = "session" // Subject is a session ID
+	RevokedUser    = "user"    // Subject is a username
 )
+
+/*
+Revocation is an entry of the stateless session revocation list. It ends the
+session named by Subject, or for RevokedUser every session the user was
+issued before RevokedAt.
+*/
+type Revocation struct {
+	Kind      string
+	Subject   string
+	RevokedAt time.Time
+	ExpiresAt time.Time
+}
+
+/*
+MFAChallenge is a login that passed the password check and is waiting for the
+user to enter a second factor. Token is handed to the client and only its
+hash is stored, so Token is only filled in by CreateMFAChallenge.
+*/
+type MFAChallenge struct {
+	ID        string
+	Token     string
+	Username  string
+	ExpiresAt time.Time
+	Attempts  int
+}
+
+/*
+WebAuthnCredential is a passkey or security key registered to a user. ID is
+the base64url encoded credential ID and PublicKey the raw COSE key.
+*/
+type WebAuthnCredential struct {
+	ID         string
+	Username   string
+	UserHandle []byte
+	PublicKey  []byte
+	SignCount  uint32
+	Transports []string
+	CreatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+/*
+WebAuthnCeremony is a registration or login ceremony in progress. Kind is
+"register" or "login"; Username is empty for a passwordless login where the
+browser picks the passkey. UserHandle is the WebAuthn user ID a registration
+was started with. Token is handed to the client and only its hash is stored.
+*/
+type WebAuthnCeremony struct {
+	Token      string
+	Kind       string
+	Username   string
+	Challenge  []byte
+	UserHandle []byte
+	ExpiresAt  time.Time
+}
+
+/*
+PasswordReset is a request to set a new password without knowing the old one.
+Token is emailed to the user and only its hash is stored, so Token is only
+filled in by CreatePasswordReset.
+*/
+type PasswordReset struct {
+	Token     string
+	Username  string
+	ExpiresAt time.Time
+}
+
+/*
+EmailVerification is a link emailed to a user to prove they own Email. Token
+is only filled in by CreateEmailVerification, as only its hash is stored.
+*/
+type EmailVerification struct {
+	Token     string
+	Username  string
+	Email     string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+/*
+MagicLink is a passwordless login link emailed to a user. Token goes in the
+link and BrowserToken in a cookie of the browser that asked for it; the link
+only works when both are presented, so a forwarded link is useless. Only
+hashes of the two are stored, so they are only filled in by CreateMagicLink.
+*/
+type MagicLink struct {
+	Token        string
+	BrowserToken string
+	Username     string
+	Email        string
+	ExpiresAt    time.Time
+}
+
+/*
+UserSummary is a user as listed to administrators, with the roles they hold.
+*/
+type UserSummary struct {
+	Username      string
+	Email         string
+	EmailVerified bool
+	Roles         []string
+}
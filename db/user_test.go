@@ -0,0 +1,144 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUserRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			tests := []struct {
+				username, email string
+				want            error
+			}{
+				{"alice", "alice2@example.com", ErrUserExists},
+				{"bob", "alice@example.com", ErrEmailExists},
+				{"bob", "bob@example.com", nil},
+			}
+			for _, test := range tests {
+				if err := store.CreateUser(test.username, test.email, "Plum-Kettle-93"); err != test.want {
+					t.Fatalf("CreateUser(%s, %s) = %v, want %v", test.username, test.email, err, test.want)
+				}
+			}
+
+			if ok, err := store.AuthenticateUser("alice", "Plum-Kettle-93"); !ok || err != nil {
+				t.Fatalf("AuthenticateUser with the right password = %t, %v", ok, err)
+			}
+			if ok, err := store.AuthenticateUser("alice", "plum-kettle-93"); ok || err != ErrInvalidPassword {
+				t.Fatalf("AuthenticateUser with a wrong password = %t, %v, want ErrInvalidPassword", ok, err)
+			}
+			if ok, err := store.AuthenticateUser("nobody", "Plum-Kettle-93"); ok || err != ErrUserNotFound {
+				t.Fatalf("AuthenticateUser of an unknown user = %t, %v, want ErrUserNotFound", ok, err)
+			}
+
+			if err := store.UpdatePassword("alice", "correct horse battery staple"); err != nil {
+				t.Fatal(err)
+			}
+			if ok, _ := store.AuthenticateUser("alice", "Plum-Kettle-93"); ok {
+				t.Fatal("the old password still works")
+			}
+			if ok, err := store.AuthenticateUser("alice", "correct horse battery staple"); !ok || err != nil {
+				t.Fatalf("AuthenticateUser with the new password = %t, %v", ok, err)
+			}
+		})
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			first, err := store.CreateSession("alice", "192.0.2.1", "laptop")
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := store.CreateSession("alice", "192.0.2.2", "phone")
+			if err != nil {
+				t.Fatal(err)
+			}
+			id, err := store.GetUserID("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found, err := store.GetSession(first.Token)
+			if err != nil || found.ID != first.ID || found.UserID != id || found.Username != "alice" ||
+				found.IPAddress != "192.0.2.1" || found.UserAgent != "laptop" {
+				t.Fatalf("GetSession = %+v, %v, want the first session", found, err)
+			}
+			if left := time.Until(found.ExpiresAt); left <= sessionLifetime-time.Minute || left > sessionLifetime {
+				t.Fatalf("session expires in %s, want %s", left, sessionLifetime)
+			}
+			if ok, err := store.ValidateCSRFToken(first.ID, first.CSRFToken); !ok || err != nil {
+				t.Fatalf("ValidateCSRFToken = %t, %v, want true", ok, err)
+			}
+			if ok, _ := store.ValidateCSRFToken(first.ID, second.CSRFToken); ok {
+				t.Fatal("another session's CSRF token validated")
+			}
+			if _, err := store.GetSession("not-a-token"); err != ErrSessionNotFound {
+				t.Fatalf("GetSession of an unknown token = %v, want ErrSessionNotFound", err)
+			}
+
+			// ending one session leaves the others
+			if err := store.DeleteSession(first.ID); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.GetSession(first.Token); err != ErrSessionNotFound {
+				t.Fatalf("GetSession after DeleteSession = %v, want ErrSessionNotFound", err)
+			}
+			if _, err := store.GetSession(second.Token); err != nil {
+				t.Fatalf("GetSession of the other session: %v", err)
+			}
+
+			if err := store.DeleteUserSessions("alice"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := store.GetSession(second.Token); err != ErrSessionNotFound {
+				t.Fatalf("GetSession after DeleteUserSessions = %v, want ErrSessionNotFound", err)
+			}
+		})
+	}
+}
+
+func TestLoginFailuresRoundTrip(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			check := func(username string, want int) {
+				t.Helper()
+				n, last, err := store.GetLoginFailures(username)
+				if err != nil || n != want || (n == 0) != last.IsZero() {
+					t.Fatalf("GetLoginFailures(%s) = %d, %s, %v, want %d", username, n, last, err, want)
+				}
+			}
+			check("alice", 0)
+
+			since := time.Now().Add(-time.Hour)
+			for want := 1; want <= 3; want++ {
+				if n, err := store.RecordLoginFailure("alice", since); err != nil || n != want {
+					t.Fatalf("RecordLoginFailure = %d, %v, want %d", n, err, want)
+				}
+			}
+			// usernames without an account are counted too
+			if _, err := store.RecordLoginFailure("nobody", since); err != nil {
+				t.Fatal(err)
+			}
+			check("alice", 3)
+			check("nobody", 1)
+
+			// failures from before since are forgotten, for every username
+			if n, err := store.RecordLoginFailure("alice", time.Now().Add(time.Minute)); err != nil || n != 1 {
+				t.Fatalf("RecordLoginFailure after the window = %d, %v, want 1", n, err)
+			}
+			check("nobody", 0)
+			if _, err := store.RecordLoginFailure("nobody", since); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := store.ResetLoginFailures("alice"); err != nil {
+				t.Fatal(err)
+			}
+			check("alice", 0)
+			check("nobody", 1)
+		})
+	}
+}
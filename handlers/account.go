@@ -1,16 +1,26 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
+	"strings"
 
-	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
 )
 
 func Account(w http.ResponseWriter, r *http.Request) {
-	err := Templates.ExecuteTemplate(w, "account.html", nil)
-	if err != nil {
-		logs.Logs(logErr, fmt.Sprintf("Failed to execute template: %s", err.Error()))
-		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+	renderAccount(w, r, AccountPage{})
+}
+
+// ruleNames lists the rules broken by a form for the logs, without the
+// values entered.
+func ruleNames(errs validation.Errors) string {
+	names := make([]string, len(errs))
+	for i, err := range errs {
+		names[i] = err.Field + "." + err.Rule
 	}
+	return strings.Join(names, ", ")
+}
+
+func renderAccount(w http.ResponseWriter, r *http.Request, page AccountPage) {
+	renderTemplate(w, r, "account.html", page)
 }
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+// AdminUsers lists every user and their roles. It is wrapped in
+// RequirePermission("admin:users") in StartHTTPServer.
+func (s *Server) AdminUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to dashboard...", r.Method))
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	s.renderAdminUsers(w, r, AdminUsersPage{})
+}
+
+func (s *Server) renderAdminUsers(w http.ResponseWriter, r *http.Request, page AdminUsersPage) {
+	users, err := s.store.ListUsers()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to list users: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	page.Users = users
+
+	renderTemplate(w, r, "admin_users.html", page)
+}
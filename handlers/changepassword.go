@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+func ChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to dashboard page...", r.Method))
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+	renderChangePassword(w, r, ChangePasswordPage{})
+}
+
+func renderChangePassword(w http.ResponseWriter, r *http.Request, page ChangePasswordPage) {
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplate(w, r, "change_password.html", page)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func (s *Server) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to MFA setup page...", r.Method))
+		http.Redirect(w, r, "/mfa-setup", http.StatusSeeOther)
+		return
+	}
+
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		logs.Logs(logWarning, "Request has no logged in user. Redirecting back to login page...")
+		middleware.RedirectToLogin(w, r)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	code := r.FormValue("code")
+
+	secret, enabled, err := s.store.GetTOTP(user.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to get TOTP secret: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if enabled || secret == "" {
+		http.Redirect(w, r, "/mfa-setup", http.StatusSeeOther)
+		return
+	}
+
+	// the first code proves the authenticator app holds the secret
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if ok {
+		ok, err = s.store.UseTOTPStep(user.Username, step)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to record TOTP code: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+	}
+	if !ok {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid TOTP confirmation code for user %s", user.Username))
+		page, err := newMFASetupPage(user.Username, secret)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to create QR code: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		page.Error = "That code is not valid. Check your authenticator app and try again."
+		w.WriteHeader(http.StatusBadRequest)
+		renderMFASetup(w, r, page)
+		return
+	}
+
+	err = s.store.EnableTOTP(user.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to enable TOTP: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("TOTP enabled for user %s. Issuing recovery codes...", user.Username))
+	s.issueRecoveryCodes(w, r, user.Username)
+}
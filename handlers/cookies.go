@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+/*
+newCookieManager reads the cookie settings from the environment:
+
+- COOKIE_SECURE: true (the default) to only send cookies over HTTPS, with the
+__Host- or __Secure- prefix. Browsers treat http://localhost as secure too,
+so only plain HTTP development on another host needs it set to false.
+
+- COOKIE_SAMESITE: lax (the default) or strict.
+
+- COOKIE_DOMAIN: a parent domain to share cookies with its subdomains, such
+as example.com. Unset keeps cookies to the host that set them.
+
+- COOKIE_KEYS and COOKIE_KEY_ID: the keys sealed cookies are encrypted with,
+see utils.LoadCookieCodec.
+*/
+func newCookieManager() middleware.CookieManager {
+	site, err := url.Parse(siteURL())
+	if err != nil {
+		site = &url.URL{}
+	}
+	https := site.Scheme == "https"
+	manager := middleware.CookieManager{
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		Domain:   strings.ToLower(os.Getenv("COOKIE_DOMAIN")),
+	}
+
+	if value := os.Getenv("COOKIE_SECURE"); value != "" {
+		secure, err := strconv.ParseBool(value)
+		if err != nil {
+			logs.Logs(logWarning, fmt.Sprintf("Invalid COOKIE_SECURE %q. Defaulting to %t...", value, manager.Secure))
+		} else {
+			manager.Secure = secure
+		}
+	}
+	switch {
+	case !manager.Secure:
+		logs.Logs(logWarning, "COOKIE_SECURE is false. Session cookies can be sent over plain HTTP, use this for local development only.")
+	case !https && manager.Secure && site.Hostname() != "localhost":
+		logs.Logs(logWarning, fmt.Sprintf("COOKIE_SECURE is true but the site URL %s is not HTTPS. Browsers will refuse the cookies.", site))
+	}
+
+	switch value := strings.ToLower(os.Getenv("COOKIE_SAMESITE")); value {
+	case "", "lax":
+	case "strict":
+		manager.SameSite = http.SameSiteStrictMode
+	default:
+		// None would hand cookies to cross-site requests
+		logs.Logs(logWarning, fmt.Sprintf("Invalid COOKIE_SAMESITE %q, use lax or strict. Defaulting to lax...", value))
+	}
+
+	if domain := strings.TrimPrefix(manager.Domain, "."); domain != "" {
+		host := site.Hostname()
+		if host != domain && !strings.HasSuffix(host, "."+domain) {
+			logs.Logs(logWarning, fmt.Sprintf("COOKIE_DOMAIN %s does not contain the site host %s. Browsers will refuse the cookies.", domain, host))
+		}
+	}
+
+	manager.Codec, err = utils.LoadCookieCodec()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to load cookie keys: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("Cookies are stored as %s (Secure: %t)", manager.Name(middleware.SessionCookie), manager.Secure))
+	return manager
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func TestNewCookieManager(t *testing.T) {
+	tests := []struct {
+		name       string
+		secure     string
+		sameSite   string
+		domain     string
+		wantSecure bool
+		wantSame   http.SameSite
+		wantName   string
+	}{
+		{"defaults", "", "", "", true, http.SameSiteLaxMode, "__Host-session_token"},
+		{"strict", "", "strict", "", true, http.SameSiteStrictMode, "__Host-session_token"},
+		{"None refused", "", "none", "", true, http.SameSiteLaxMode, "__Host-session_token"},
+		{"shared with subdomains", "", "", "example.com", true, http.SameSiteLaxMode, "__Secure-session_token"},
+		{"plain HTTP", "false", "", "", false, http.SameSiteLaxMode, "session_token"},
+		{"invalid secure", "maybe", "", "", true, http.SameSiteLaxMode, "__Host-session_token"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("BASE_URL", "https://auth.example.com")
+			t.Setenv("COOKIE_SECURE", test.secure)
+			t.Setenv("COOKIE_SAMESITE", test.sameSite)
+			t.Setenv("COOKIE_DOMAIN", test.domain)
+
+			manager := newCookieManager()
+			if manager.Secure != test.wantSecure || manager.SameSite != test.wantSame || manager.Codec == nil {
+				t.Fatalf("newCookieManager = %+v, want Secure %t, SameSite %v and a codec", manager, test.wantSecure, test.wantSame)
+			}
+			if name := manager.Name(middleware.SessionCookie); name != test.wantName {
+				t.Errorf("session cookie name = %q, want %q", name, test.wantName)
+			}
+		})
+	}
+}
@@ -6,9 +6,11 @@ import (
 
 	"github.com/Bevs-n-Devs/WebAuthentication/db"
 	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/mailer"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
 )
 
-func CreateAccount(w http.ResponseWriter, r *http.Request) {
+func (s *Server) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to index page...", r.Method))
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -24,16 +26,94 @@ func CreateAccount(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get form data
-	username := r.FormValue("username")
 	password := r.FormValue("password")
+	page := AccountPage{Username: r.FormValue("username"), Email: r.FormValue("email")}
 
-	err = db.CreateUser(username, password)
+	// report every problem with the form at once
+	username, errs := validation.Username(page.Username)
+	page.Errors = append(page.Errors, errs...)
+	email, errs := validation.Email(page.Email)
+	page.Errors = append(page.Errors, errs...)
+	page.Errors = append(page.Errors, Passwords.Check(validation.CanonicalUsername(page.Username), password)...)
+	if page.Errors != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Signup form breaks %d rules: %s", len(page.Errors), ruleNames(page.Errors)))
+		w.WriteHeader(http.StatusBadRequest)
+		renderAccount(w, r, page)
+		return
+	}
+
+	err = s.store.CreateUser(username, email, password)
+	if err == db.ErrUserExists {
+		logs.Logs(logWarning, fmt.Sprintf("Signup with username %s that is already registered", username))
+		page.Errors = validation.Errors{{Field: "username", Rule: "taken", Message: "That username is already taken."}}
+		w.WriteHeader(http.StatusConflict)
+		renderAccount(w, r, page)
+		return
+	}
+	if err == db.ErrUsernameLookAlike {
+		logs.Logs(logWarning, fmt.Sprintf("Signup with username %s that looks like a registered one", username))
+		page.Errors = validation.Errors{{Field: "username", Rule: "lookalike", Message: "That username looks too much like one that is already taken."}}
+		w.WriteHeader(http.StatusConflict)
+		renderAccount(w, r, page)
+		return
+	}
+	if err == db.ErrEmailExists {
+		// answer as if the account was made, so the form does not tell which
+		// addresses are registered; their owner hears about it by email
+		logs.Logs(logWarning, "Signup with an email address that is already registered")
+		go sendAccountExists(email)
+		signedUp(w, r)
+		return
+	}
 	if err != nil {
 		logs.Logs(logErr, fmt.Sprintf("Failed to create user: %s", err.Error()))
 		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
 		return
 	}
 
+	// the email goes out after the answer, so a slow or failing mail server
+	// neither delays the signup nor fails it
+	go s.sendSignupVerification(username)
+
 	logs.Logs(logInfo, fmt.Sprintf("User %s created successfully. Redirected to login page...", username))
+	signedUp(w, r)
+}
+
+// signedUp sends the user to the login page with the message every
+// successful signup gets, including ones whose email address was taken.
+func signedUp(w http.ResponseWriter, r *http.Request) {
+	message := "Thanks for signing up! Check your email for a link to verify your address."
+	if EmailPolicy == verificationBlock {
+		message += " Open it before logging in."
+	}
+	setFlash(w, message)
 	http.Redirect(w, r, "/login", http.StatusSeeOther)
 }
+
+// sendSignupVerification emails a new user their first verification link.
+// It runs after the response is sent, so failures are only logged; the user
+// can ask for a new link later.
+func (s *Server) sendSignupVerification(username string) {
+	err := s.sendVerificationEmail(username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to send verification email: %s", err.Error()))
+	}
+}
+
+// sendAccountExists tells the owner of an email address that someone tried
+// to sign up with it. It runs after the response is sent, so failures are
+// only logged.
+func sendAccountExists(email string) {
+	err := Mail.Send(mailer.Message{
+		To:      email,
+		Subject: "You already have an account",
+		Body: fmt.Sprintf("Someone tried to create an account with this email address, which already belongs to one, "+
+			"so no new account was made.\n\n"+
+			"If it was you, log in with your username, or get a login link at:\n\n%s/magic-link\n\n"+
+			"If you forgot your password, you can reset it at:\n\n%s/forgot-password\n\n"+
+			"If it wasn't you, you can ignore this email.\n", siteURL(), siteURL()),
+	})
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to send account exists email: %s", err.Error()))
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/mailer"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// newMailServer returns handlers backed by a fresh in-memory store that send
+// email to the returned outbox, using the default password policy and the
+// given email verification policy for the duration of the test.
+func newMailServer(t *testing.T, emailPolicy string) (*Server, *mailer.Outbox) {
+	t.Helper()
+	mail, passwords, policy := Mail, Passwords, EmailPolicy
+	t.Cleanup(func() { Mail, Passwords, EmailPolicy = mail, passwords, policy })
+
+	outbox := &mailer.Outbox{}
+	Mail, Passwords, EmailPolicy = outbox, utils.DefaultPasswordPolicy(), emailPolicy
+	return NewServer(db.NewMemoryStore()), outbox
+}
+
+// waitForMail waits for the outbox to hold count messages, as handlers send
+// them in the background, and returns them.
+func waitForMail(t *testing.T, outbox *mailer.Outbox, count int) []mailer.Message {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if messages := outbox.Messages(); len(messages) >= count {
+			return messages
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("%d emails were sent, want %d", len(outbox.Messages()), count)
+	return nil
+}
+
+func TestSignupWithTakenEmail(t *testing.T) {
+	s, outbox := newMailServer(t, verificationRestrict)
+	signup := func(username string) *http.Response {
+		t.Helper()
+		var cookies []*http.Cookie
+		form := url.Values{"username": {username}, "email": {"alice@example.com"}, "password": {"correct horse battery staple"}}
+		return postForm(s.CreateAccount, "/create-account", form, &cookies).Result()
+	}
+
+	first := signup("alice")
+	if messages := waitForMail(t, outbox, 1); messages[0].Subject != "Verify your email address" {
+		t.Fatalf("signup sent %q, want a verification link", messages[0].Subject)
+	}
+	second := signup("bob")
+
+	// the second signup is answered just like the first
+	if first.StatusCode != http.StatusSeeOther || second.StatusCode != first.StatusCode ||
+		second.Header.Get("Location") != first.Header.Get("Location") || len(second.Cookies()) != len(first.Cookies()) {
+		t.Fatalf("signups answered %d to %q and %d to %q, want the same redirect",
+			first.StatusCode, first.Header.Get("Location"), second.StatusCode, second.Header.Get("Location"))
+	}
+	if _, err := s.store.GetUserID("bob"); err != db.ErrUserNotFound {
+		t.Fatalf("GetUserID(bob) = %v, want ErrUserNotFound", err)
+	}
+	// the owner of the address is told instead
+	messages := waitForMail(t, outbox, 2)
+	if messages[1].To != "alice@example.com" || messages[1].Subject != "You already have an account" {
+		t.Fatalf("second signup sent %q to %s, want the account exists email to alice@example.com", messages[1].Subject, messages[1].To)
+	}
+}
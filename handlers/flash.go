@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+const (
+	flashCookie   = "flash"         // holds a message for the next page the user sees
+	flashLifetime = 5 * time.Minute // how long a flash message waits to be shown
+)
+
+// flash is a one-time message carried across a redirect in a sealed cookie,
+// such as the confirmation shown on the login page after a password reset.
+type flash struct {
+	Message string `json:"m"`
+}
+
+// setFlash stores a message for the next page that takes it.
+func setFlash(w http.ResponseWriter, message string) {
+	err := middleware.Cookies.SetSealed(w, flashCookie, flash{Message: message}, time.Now().Add(flashLifetime))
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to set flash message: %s", err.Error()))
+	}
+}
+
+// takeFlash returns the stored message and clears it, or "" if there is
+// none. Messages that were tampered with or have expired are dropped.
+func takeFlash(w http.ResponseWriter, r *http.Request) string {
+	var f flash
+	err := middleware.Cookies.GetSealed(r, flashCookie, &f)
+	if errors.Is(err, http.ErrNoCookie) {
+		return ""
+	}
+	middleware.Cookies.Clear(w, flashCookie)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Ignoring flash message: %s", err.Error()))
+		return ""
+	}
+	return f.Message
+}
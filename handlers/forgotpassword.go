@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	renderForgotPassword(w, r, ForgotPasswordPage{})
+}
+
+func renderForgotPassword(w http.ResponseWriter, r *http.Request, page ForgotPasswordPage) {
+	renderTemplate(w, r, "forgot_password.html", page)
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+lockoutPolicy decides how long a username must wait before its next password
+login after failed attempts in a row. Past BackoffAfter failures each attempt
+doubles the wait, starting at BackoffBase and capped at BackoffMax. At
+LockAfter failures the account is locked for LockDuration, after which it
+unlocks by itself. Failures older than Window are forgotten.
+*/
+type lockoutPolicy struct {
+	BackoffAfter int
+	BackoffBase  time.Duration
+	BackoffMax   time.Duration
+	LockAfter    int
+	LockDuration time.Duration
+	Window       time.Duration
+}
+
+// newLockoutPolicy reads the lockout settings from the environment, using
+// the defaults for any that are unset or invalid.
+func newLockoutPolicy() lockoutPolicy {
+	return lockoutPolicy{
+		BackoffAfter: envInt("LOGIN_BACKOFF_AFTER", 3),
+		BackoffBase:  envDuration("LOGIN_BACKOFF_BASE", time.Second),
+		BackoffMax:   envDuration("LOGIN_BACKOFF_MAX", time.Minute),
+		LockAfter:    envInt("LOGIN_LOCKOUT_THRESHOLD", 10),
+		LockDuration: envDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		Window:       envDuration("LOGIN_FAILURE_WINDOW", time.Hour),
+	}
+}
+
+/*
+retryAfter works out how long to wait before the next login, given the
+failures in a row and when the last one happened.
+
+Returns:
+
+- time.Duration: The time left to wait, 0 if a login may be tried now.
+
+- bool: True if the wait is a lockout rather than a back-off delay.
+*/
+func (p lockoutPolicy) retryAfter(failures int, lastFailed, now time.Time) (time.Duration, bool) {
+	if failures == 0 || now.Sub(lastFailed) > p.Window {
+		return 0, false
+	}
+
+	if p.LockAfter > 0 && failures >= p.LockAfter {
+		if wait := lastFailed.Add(p.LockDuration).Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, false
+	}
+
+	if failures < p.BackoffAfter {
+		return 0, false
+	}
+	delay := p.BackoffBase
+	for i := p.BackoffAfter; i < failures && delay < p.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > p.BackoffMax {
+		delay = p.BackoffMax
+	}
+	if wait := lastFailed.Add(delay).Sub(now); wait > 0 {
+		return wait, false
+	}
+	return 0, false
+}
+
+// envInt reads a non-negative integer setting, falling back to def.
+func envInt(name string, def int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid %s %q. Defaulting to %d...", name, value, def))
+		return def
+	}
+	return n
+}
+
+// envDuration reads a duration setting such as "15m", falling back to def.
+func envDuration(name string, def time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid %s %q. Defaulting to %s...", name, value, def))
+		return def
+	}
+	return d
+}
+
+/*
+beginLoginAttempt checks whether the username may try to log in now and, if
+so, counts the attempt as failed before its credentials are checked. Counting
+first means concurrent attempts cannot all pass the check before any of them
+is recorded; a successful login forgets the count again.
+
+Returns:
+
+- time.Duration: The time left to wait, 0 if the attempt may go ahead.
+
+- bool: True if the wait is a lockout rather than a back-off delay.
+
+- error: An error if the failures cannot be read or counted.
+*/
+func (s *Server) beginLoginAttempt(username string) (time.Duration, bool, error) {
+	now := time.Now()
+	failures, lastFailed, err := s.store.GetLoginFailures(username)
+	if err != nil {
+		return 0, false, err
+	}
+	if wait, locked := Lockout.retryAfter(failures, lastFailed, now); wait > 0 {
+		return wait, locked, nil
+	}
+	if now.Sub(lastFailed) > Lockout.Window {
+		failures = 0
+	}
+
+	counted, err := s.store.RecordLoginFailure(username, now.Add(-Lockout.Window))
+	if err != nil {
+		return 0, false, err
+	}
+	// other attempts were counted since the check, so this one is judged as
+	// coming right after them
+	if counted > failures+1 {
+		wait, locked := Lockout.retryAfter(counted-1, now, now)
+		return wait, locked, nil
+	}
+	return 0, false, nil
+}
+
+// lockedOut returns how long the username stays locked out, 0 if it is not.
+// Back-off delays are ignored, as they only slow down password guessing.
+func (s *Server) lockedOut(username string) (time.Duration, error) {
+	failures, lastFailed, err := s.store.GetLoginFailures(username)
+	if err != nil {
+		return 0, err
+	}
+	if wait, locked := Lockout.retryAfter(failures, lastFailed, time.Now()); locked {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+// forgetLoginFailures clears the username's failed attempts once a login has
+// fully succeeded. Failing to clear them only leaves a stale count behind.
+func (s *Server) forgetLoginFailures(username string) {
+	err := s.store.ResetLoginFailures(username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to reset login failures: %s", err.Error()))
+	}
+}
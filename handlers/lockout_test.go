@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func TestRetryAfter(t *testing.T) {
+	policy := lockoutPolicy{
+		BackoffAfter: 3,
+		BackoffBase:  time.Second,
+		BackoffMax:   8 * time.Second,
+		LockAfter:    10,
+		LockDuration: 15 * time.Minute,
+		Window:       time.Hour,
+	}
+	noLock := policy
+	noLock.LockAfter = 0
+
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		policy    lockoutPolicy
+		failures  int
+		sinceLast time.Duration
+		wait      time.Duration
+		locked    bool
+	}{
+		{"no failures", policy, 0, 0, 0, false},
+		{"below back-off", policy, 2, 0, 0, false},
+		{"first delay", policy, 3, 0, time.Second, false},
+		{"delay partly waited", policy, 3, 400 * time.Millisecond, 600 * time.Millisecond, false},
+		{"delay waited out", policy, 3, 2 * time.Second, 0, false},
+		{"delay doubles", policy, 4, 0, 2 * time.Second, false},
+		{"delay doubles again", policy, 5, 0, 4 * time.Second, false},
+		{"delay capped", policy, 6, 0, 8 * time.Second, false},
+		{"delay stays capped", policy, 9, 0, 8 * time.Second, false},
+		{"locked", policy, 10, 0, 15 * time.Minute, true},
+		{"lock partly waited", policy, 12, 5 * time.Minute, 10 * time.Minute, true},
+		{"lock expired", policy, 10, 16 * time.Minute, 0, false},
+		{"failures outside window", policy, 10, 2 * time.Hour, 0, false},
+		{"lockout disabled", noLock, 25, 0, 8 * time.Second, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			wait, locked := test.policy.retryAfter(test.failures, now.Add(-test.sinceLast), now)
+			if wait != test.wait || locked != test.locked {
+				t.Fatalf("retryAfter(%d) = %s, %t, want %s, %t", test.failures, wait, locked, test.wait, test.locked)
+			}
+		})
+	}
+}
+
+// newLockoutServer returns handlers backed by a fresh in-memory store, using
+// the given policy for the duration of the test.
+func newLockoutServer(t *testing.T, policy lockoutPolicy) *Server {
+	t.Helper()
+	lockout := Lockout
+	Lockout = policy
+	t.Cleanup(func() { Lockout = lockout })
+	return NewServer(db.NewMemoryStore())
+}
+
+func TestBeginLoginAttemptConcurrent(t *testing.T) {
+	s := newLockoutServer(t, lockoutPolicy{BackoffAfter: 100, LockAfter: 5, LockDuration: time.Hour, Window: time.Hour})
+
+	// however the attempts interleave, only as many as the lockout
+	// threshold get to check their credentials
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed, locked := 0, 0
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wait, isLocked, err := s.beginLoginAttempt("alice")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if wait == 0 {
+				allowed++
+			} else if isLocked {
+				locked++
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 5 || locked != 35 {
+		t.Fatalf("%d attempts allowed and %d locked, want 5 and 35", allowed, locked)
+	}
+}
+
+func TestBeginLoginAttemptWindow(t *testing.T) {
+	s := newLockoutServer(t, lockoutPolicy{BackoffAfter: 100, LockAfter: 2, LockDuration: time.Hour, Window: 50 * time.Millisecond})
+
+	for i := 0; i < 2; i++ {
+		if wait, _, err := s.beginLoginAttempt("alice"); err != nil || wait != 0 {
+			t.Fatalf("attempt %d: wait %s, error %v", i+1, wait, err)
+		}
+	}
+	if wait, locked, _ := s.beginLoginAttempt("alice"); wait == 0 || !locked {
+		t.Fatalf("third attempt: wait %s, locked %t, want a lockout", wait, locked)
+	}
+
+	// once the failures leave the window the lock is gone and counting
+	// starts over
+	time.Sleep(100 * time.Millisecond)
+	if wait, _, err := s.beginLoginAttempt("alice"); err != nil || wait != 0 {
+		t.Fatalf("attempt after the window: wait %s, error %v", wait, err)
+	}
+	failures, _, err := s.store.GetLoginFailures("alice")
+	if err != nil || failures != 1 {
+		t.Fatalf("GetLoginFailures = %d, %v, want 1", failures, err)
+	}
+
+	// a successful login forgets the count
+	s.forgetLoginFailures("alice")
+	failures, _, err = s.store.GetLoginFailures("alice")
+	if err != nil || failures != 0 {
+		t.Fatalf("GetLoginFailures after forgetting = %d, %v, want 0", failures, err)
+	}
+}
+
+func TestMagicLoginLockedOut(t *testing.T) {
+	s := newLockoutServer(t, lockoutPolicy{BackoffAfter: 100, LockAfter: 3, LockDuration: time.Hour, Window: time.Hour})
+	if err := s.store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93"); err != nil {
+		t.Fatal(err)
+	}
+	login := func() *httptest.ResponseRecorder {
+		t.Helper()
+		link, err := s.store.CreateMagicLink("alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodGet, "/magic-login?token="+url.QueryEscape(link.Token), nil)
+		r.AddCookie(&http.Cookie{Name: middleware.Cookies.Name(magicLinkCookie), Value: link.BrowserToken})
+		w := httptest.NewRecorder()
+		s.MagicLogin(w, r)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := s.beginLoginAttempt("alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w := login(); w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("link while locked: got %d, want 429 with Retry-After", w.Code)
+	}
+
+	s.forgetLoginFailures("alice")
+	if w := login(); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("link once unlocked: got %d to %q, want a redirect to /dashboard", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -3,38 +3,33 @@ package handlers
 import (
 	"fmt"
 	"net/http"
-	"time"
 
-	"github.com/Bevs-n-Devs/WebAuthentication/db"
 	"github.com/Bevs-n-Devs/WebAuthentication/logs"
 	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
 )
 
-func LogoutUser(w http.ResponseWriter, r *http.Request) {
-	err := middleware.AuthorizeRequest(r)
-	if err != nil {
-		logs.Logs(logWarning, fmt.Sprintf("Failed to authorize request: %s. Redirecting back to login page...", err.Error()))
-		http.Redirect(w, r, "/login", http.StatusUnauthorized)
+func (s *Server) LogoutUser(w http.ResponseWriter, r *http.Request) {
+	// a link could log users out from another site, so only the form's POST
+	// with its CSRF token is accepted
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to dashboard page...", r.Method))
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		logs.Logs(logWarning, "Request has no logged in user. Redirecting back to login page...")
+		middleware.RedirectToLogin(w, r)
 		return
 	}
 
 	// clear cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: true,
-	})
-	http.SetCookie(w, &http.Cookie{
-		Name:     "csrf_token",
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		HttpOnly: false,
-	})
+	middleware.Cookies.Clear(w, middleware.SessionCookie)
+	middleware.Cookies.Clear(w, middleware.CSRFCookie)
 
-	// remove the session & CSRF tokens from the database
-	username := r.FormValue("username")
-	err = db.LogoutUser(username)
+	// end only this session, the user stays logged in on other devices
+	err := s.store.DeleteSession(user.SessionID)
 	if err != nil {
 		logs.Logs(logErr, fmt.Sprintf("Failed to logout user: %s", err.Error()))
 		logs.Logs(logWarning, "User session & CSRF tokens have not been removed from the database")
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+func MagicLink(w http.ResponseWriter, r *http.Request) {
+	renderMagicLink(w, r, MagicLinkPage{})
+}
+
+func renderMagicLink(w http.ResponseWriter, r *http.Request, page MagicLinkPage) {
+	// keep the token in the URL from leaking to other sites
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	page.Minutes = magicLinkMinutes()
+	renderTemplate(w, r, "magic_link.html", page)
+}
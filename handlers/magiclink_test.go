@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func TestRequestMagicLink(t *testing.T) {
+	s, outbox := newMailServer(t, verificationOff)
+	if err := s.store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93"); err != nil {
+		t.Fatal(err)
+	}
+	request := func(email string) *httptest.ResponseRecorder {
+		t.Helper()
+		var cookies []*http.Cookie
+		return postForm(s.RequestMagicLink, "/request-magic-link", url.Values{"email": {email}}, &cookies)
+	}
+	lifetime := fmt.Sprintf("%d minutes", int(db.MagicLinkLifetime/time.Minute))
+
+	// an unknown address is answered like a registered one, but nothing is sent
+	for _, email := range []string{"nobody@example.com", "alice@example.com"} {
+		w := request(email)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "within "+lifetime) {
+			t.Fatalf("%s: got %d, want 200 and the sent page saying %s", email, w.Code, lifetime)
+		}
+		var cookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == middleware.Cookies.Name(magicLinkCookie) {
+				cookie = c
+			}
+		}
+		if cookie == nil || cookie.Value == "" {
+			t.Fatalf("%s: no magic link cookie was set", email)
+		}
+		if left := time.Until(cookie.Expires); left <= db.MagicLinkLifetime-time.Minute || left > db.MagicLinkLifetime {
+			t.Fatalf("%s: cookie expires in %s, want %s", email, left, db.MagicLinkLifetime)
+		}
+	}
+
+	// the unknown address was answered without starting a send
+	messages := waitForMail(t, outbox, 1)
+	if len(messages) != 1 || messages[0].To != "alice@example.com" {
+		t.Fatalf("sent %d emails, want one to alice@example.com", len(messages))
+	}
+	if !strings.Contains(messages[0].Body, "within the next "+lifetime) {
+		t.Fatalf("email body %q does not say %s", messages[0].Body, lifetime)
+	}
+}
+
+func TestMagicLoginBrowserBinding(t *testing.T) {
+	s := NewServer(db.NewMemoryStore())
+	if err := s.store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93"); err != nil {
+		t.Fatal(err)
+	}
+	link, err := s.store.CreateMagicLink("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := func(browserToken string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/magic-login?token="+url.QueryEscape(link.Token), nil)
+		if browserToken != "" {
+			r.AddCookie(&http.Cookie{Name: middleware.Cookies.Name(magicLinkCookie), Value: browserToken})
+		}
+		w := httptest.NewRecorder()
+		s.MagicLogin(w, r)
+		return w
+	}
+
+	// a forwarded link opened elsewhere fails, and leaves the link usable
+	if w := login(""); w.Code != http.StatusBadRequest {
+		t.Fatalf("link without the cookie: got %d, want 400", w.Code)
+	}
+	if w := login("another-browser"); w.Code != http.StatusBadRequest {
+		t.Fatalf("link with another browser's cookie: got %d, want 400", w.Code)
+	}
+	if w := login(link.BrowserToken); w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("link in its own browser: got %d to %q, want a redirect to /dashboard", w.Code, w.Header().Get("Location"))
+	}
+	if w := login(link.BrowserToken); w.Code != http.StatusBadRequest {
+		t.Fatalf("reused link: got %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func (s *Server) MagicLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to magic link page...", r.Method))
+		http.Redirect(w, r, "/magic-link", http.StatusSeeOther)
+		return
+	}
+
+	// the link only works in the browser that asked for it
+	browserToken, _ := middleware.Cookies.Get(r, magicLinkCookie)
+
+	link, err := s.store.ConsumeMagicLink(r.URL.Query().Get("token"), browserToken)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Failed to use magic link: %s", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		renderMagicLink(w, r, MagicLinkPage{Error: "That login link is invalid or has expired. Links only work once, in the browser you requested them from."})
+		return
+	}
+	middleware.Cookies.Clear(w, magicLinkCookie)
+
+	// a locked account cannot log in with a link either
+	wait, err := s.lockedOut(link.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to get login failures: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if wait > 0 {
+		logs.Logs(logWarning, fmt.Sprintf("Magic link login for locked user %s refused", link.Username))
+		refuseLogin(w, r, wait, true)
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("User %s logged in with a magic link", link.Username))
+	s.completeLogin(w, r, link.Username, false)
+}
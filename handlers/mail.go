@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/mailer"
+)
+
+// siteURL returns the address links in emails point at: BASE_URL if set,
+// otherwise the WebAuthn origin, which defaults to the local server.
+func siteURL() string {
+	baseURL := os.Getenv("BASE_URL")
+	if baseURL == "" {
+		baseURL = WebAuthn.Origin
+	}
+	return baseURL
+}
+
+// newEmailPolicy reads the email verification policy from the environment,
+// defaulting to restrict.
+func newEmailPolicy() string {
+	policy := os.Getenv("EMAIL_VERIFICATION")
+	switch policy {
+	case verificationOff, verificationRestrict, verificationBlock:
+		return policy
+	case "":
+		return verificationRestrict
+	default:
+		logs.Logs(logWarning, fmt.Sprintf("Unknown EMAIL_VERIFICATION policy %q. Defaulting to %s...", policy, verificationRestrict))
+		return verificationRestrict
+	}
+}
+
+/*
+emailVerificationPending reports whether the verification policy holds the
+user back: the policy is not off and the user has an email address on file
+that they have not verified yet. Accounts created before email addresses were
+collected have nothing to verify and are let through.
+
+Returns:
+
+- bool: True if the user still has to verify their address.
+
+- error: An error if the user's address cannot be looked up.
+*/
+func (s *Server) emailVerificationPending(username string) (bool, error) {
+	if EmailPolicy == verificationOff {
+		return false, nil
+	}
+	email, verified, err := s.store.GetEmail(username)
+	if err != nil {
+		return false, err
+	}
+	return email != "" && !verified, nil
+}
+
+/*
+sendVerificationEmail issues a new verification token for the user and emails
+them a link to it.
+
+Returns:
+
+- error: db.ErrVerificationTooSoon if a link was sent under a minute ago, or
+an error if the token cannot be created or the email cannot be sent.
+*/
+func (s *Server) sendVerificationEmail(username string) error {
+	verification, err := s.store.CreateEmailVerification(username)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", siteURL(), url.QueryEscape(verification.Token))
+	return Mail.Send(mailer.Message{
+		To:      verification.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Welcome, %s!\n\n"+
+			"To confirm this is your email address, open this link within the next 24 hours:\n\n%s\n\n"+
+			"If you didn't create an account, you can ignore this email.\n", username, link),
+	})
+}
+
+func renderVerifyEmail(w http.ResponseWriter, r *http.Request, page VerifyEmailPage) {
+	// keep the token in the URL from leaking to other sites
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	renderTemplate(w, r, "verify_email.html", page)
+}
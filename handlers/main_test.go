@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func TestMain(m *testing.M) {
+	go logs.ProcessLogs()
+	// templates are read relative to the repository root
+	if err := os.Chdir(".."); err != nil {
+		panic(err)
+	}
+	InitTemplates()
+	// a random key seals flash messages
+	middleware.Cookies = newCookieManager()
+	// the lowest cost keeps creating users fast
+	if err := utils.SetPasswordHasher(utils.BcryptHasher{Cost: 4}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+
+	"rsc.io/qr"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// MFASetup shows the TOTP enrollment page. A GET keeps showing the pending
+// secret, so reloading or prefetching the page does not replace the one the
+// user is scanning; a POST starts over with a new secret.
+func (s *Server) MFASetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to dashboard page...", r.Method))
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		logs.Logs(logWarning, "Request has no logged in user. Redirecting back to login page...")
+		middleware.RedirectToLogin(w, r)
+		return
+	}
+
+	secret, enabled, err := s.store.GetTOTP(user.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to get TOTP status: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if enabled {
+		remaining, err := s.store.CountRecoveryCodes(user.Username)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to count recovery codes: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		renderMFASetup(w, r, MFASetupPage{Enabled: true, RemainingCodes: remaining})
+		return
+	}
+
+	// the secret is pending until the user confirms a code from it
+	if secret == "" || r.Method == http.MethodPost {
+		secret = utils.GenerateTOTPSecret()
+		err = s.store.SetTOTPSecret(user.Username, secret)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to store TOTP secret: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	page, err := newMFASetupPage(user.Username, secret)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create QR code: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	renderMFASetup(w, r, page)
+}
+
+// newMFASetupPage builds the enrollment page for the secret, including the
+// provisioning URI as a QR code for authenticator apps to scan.
+func newMFASetupPage(username, secret string) (MFASetupPage, error) {
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "WebAuthentication"
+	}
+	uri := utils.TOTPProvisioningURI(issuer, username, secret)
+
+	code, err := qr.Encode(uri, qr.M)
+	if err != nil {
+		return MFASetupPage{}, err
+	}
+	qrCode := template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG()))
+
+	return MFASetupPage{Secret: secret, URI: uri, QRCode: qrCode}, nil
+}
+
+func renderMFASetup(w http.ResponseWriter, r *http.Request, page MFASetupPage) {
+	renderTemplate(w, r, "mfa_setup.html", page)
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func TestMFASetupKeepsPendingSecret(t *testing.T) {
+	s := NewServer(db.NewMemoryStore())
+	if err := s.store.CreateUser("alice", "", "Plum-Kettle-93"); err != nil {
+		t.Fatal(err)
+	}
+
+	setup := func(method string) string {
+		t.Helper()
+		r := httptest.NewRequest(method, "/mfa-setup", nil)
+		r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{Username: "alice"}))
+		w := httptest.NewRecorder()
+		s.MFASetup(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s /mfa-setup: got %d", method, w.Code)
+		}
+		secret, _, err := s.store.GetTOTP("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return secret
+	}
+
+	first := setup(http.MethodGet)
+	if first == "" {
+		t.Fatal("no secret was stored")
+	}
+	// reloading or prefetching the page keeps the secret being scanned
+	if again := setup(http.MethodGet); again != first {
+		t.Fatal("a second GET replaced the pending secret")
+	}
+	// starting over replaces it
+	if renewed := setup(http.MethodPost); renewed == first {
+		t.Fatal("a POST kept the pending secret")
+	}
+}
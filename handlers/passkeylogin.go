@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/webauthn"
+)
+
+// BeginPasskeyLogin returns the options for navigator.credentials.get. No
+// credentials are listed, so the browser offers any discoverable passkey for
+// the site and nobody can learn an account's credential IDs by naming it.
+func (s *Server) BeginPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, jsonError{Error: "method not allowed"})
+		return
+	}
+
+	challenge := webauthn.NewChallenge()
+	ceremony, err := s.store.CreateWebAuthnCeremony(db.WebAuthnCeremony{
+		Kind:      "login",
+		Challenge: challenge,
+	})
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create WebAuthn ceremony: %s", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, jsonError{Error: "unable to start login"})
+		return
+	}
+	setCeremonyCookie(w, ceremony)
+
+	options := WebAuthn.RequestOptions(challenge, nil)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"publicKey": options})
+}
+
+// FinishPasskeyLogin verifies the assertion posted by the browser and starts
+// a session for the passkey's owner. Passkeys require user verification, so
+// no further second factor is asked for.
+func (s *Server) FinishPasskeyLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, jsonError{Error: "method not allowed"})
+		return
+	}
+
+	ceremony, err := s.consumeCeremony(w, r, "login")
+	if err != nil {
+		logs.Logs(logWarning, "Passkey login has no matching ceremony")
+		writeJSON(w, http.StatusBadRequest, jsonError{Error: "login expired, please try again"})
+		return
+	}
+
+	var response webauthn.AssertionResponse
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebAuthnBody)).Decode(&response)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonError{Error: "invalid login response"})
+		return
+	}
+
+	stored, err := s.store.GetWebAuthnCredential(response.RawID.String())
+	if err != nil {
+		logs.Logs(logWarning, "Passkey login with an unknown credential")
+		writeJSON(w, http.StatusUnauthorized, jsonError{Error: "passkey not recognised"})
+		return
+	}
+	cred, err := toWebAuthnCredential(stored)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Stored passkey of user %s is corrupt: %s", stored.Username, err.Error()))
+		writeJSON(w, http.StatusUnauthorized, jsonError{Error: "passkey not recognised"})
+		return
+	}
+
+	signCount, err := WebAuthn.VerifyAssertion(ceremony.Challenge, cred, response)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Passkey login rejected for user %s: %s", stored.Username, err.Error()))
+		writeJSON(w, http.StatusUnauthorized, jsonError{Error: "passkey could not be verified"})
+		return
+	}
+
+	err = s.store.UpdateWebAuthnSignCount(stored.ID, signCount)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to update passkey sign count: %s", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, jsonError{Error: "unable to log in"})
+		return
+	}
+
+	if EmailPolicy == verificationBlock {
+		pending, err := s.emailVerificationPending(stored.Username)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to get email status: %s", err.Error()))
+			writeJSON(w, http.StatusInternalServerError, jsonError{Error: "unable to log in"})
+			return
+		}
+		if pending {
+			logs.Logs(logWarning, fmt.Sprintf("User %s has not verified their email address. Passkey login refused...", stored.Username))
+			writeJSON(w, http.StatusForbidden, jsonError{Error: "verify your email address before logging in"})
+			return
+		}
+	}
+
+	err = s.startSession(w, r, stored.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create session: %s", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, jsonError{Error: "unable to log in"})
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("User %s logged in with a passkey", stored.Username))
+	writeJSON(w, http.StatusOK, map[string]string{"redirect": "/dashboard"})
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/webauthn"
+)
+
+// BeginPasskeyRegistration returns the options for navigator.credentials.create
+// so a logged in user can register a passkey.
+func (s *Server) BeginPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, jsonError{Error: "method not allowed"})
+		return
+	}
+
+	// RequireAuth has already checked the session
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, jsonError{Error: "not logged in"})
+		return
+	}
+
+	creds, err := s.store.ListWebAuthnCredentials(user.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to list passkeys: %s", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, jsonError{Error: "unable to start registration"})
+		return
+	}
+
+	// every passkey of a user shares one user handle so authenticators can
+	// tell they belong to the same account
+	userHandle := webauthn.NewChallenge()
+	if len(creds) > 0 {
+		userHandle = creds[0].UserHandle
+	}
+
+	challenge := webauthn.NewChallenge()
+	ceremony, err := s.store.CreateWebAuthnCeremony(db.WebAuthnCeremony{
+		Kind:       "register",
+		Username:   user.Username,
+		Challenge:  challenge,
+		UserHandle: userHandle,
+	})
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create WebAuthn ceremony: %s", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, jsonError{Error: "unable to start registration"})
+		return
+	}
+	setCeremonyCookie(w, ceremony)
+
+	options := WebAuthn.CreationOptions(challenge, userHandle, user.Username, toWebAuthnCredentials(creds))
+	writeJSON(w, http.StatusOK, map[string]interface{}{"publicKey": options})
+}
+
+// FinishPasskeyRegistration verifies the new credential posted by the browser
+// and stores it for the logged in user.
+func (s *Server) FinishPasskeyRegistration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, jsonError{Error: "method not allowed"})
+		return
+	}
+
+	// RequireAuth has already checked the session
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, jsonError{Error: "not logged in"})
+		return
+	}
+
+	ceremony, err := s.consumeCeremony(w, r, "register")
+	if err != nil || ceremony.Username != user.Username {
+		logs.Logs(logWarning, "Passkey registration has no matching ceremony")
+		writeJSON(w, http.StatusBadRequest, jsonError{Error: "registration expired, please try again"})
+		return
+	}
+
+	var response webauthn.RegistrationResponse
+	err = json.NewDecoder(http.MaxBytesReader(w, r.Body, maxWebAuthnBody)).Decode(&response)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonError{Error: "invalid registration response"})
+		return
+	}
+
+	cred, err := WebAuthn.VerifyRegistration(ceremony.Challenge, response)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Passkey registration rejected for user %s: %s", user.Username, err.Error()))
+		writeJSON(w, http.StatusBadRequest, jsonError{Error: "passkey could not be verified"})
+		return
+	}
+
+	err = s.store.AddWebAuthnCredential(db.WebAuthnCredential{
+		ID:         cred.ID.String(),
+		Username:   user.Username,
+		UserHandle: ceremony.UserHandle,
+		PublicKey:  cred.PublicKey,
+		SignCount:  cred.SignCount,
+		Transports: cred.Transports,
+	})
+	if err == db.ErrCredentialExists {
+		writeJSON(w, http.StatusConflict, jsonError{Error: "this passkey is already registered"})
+		return
+	}
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to store passkey: %s", err.Error()))
+		writeJSON(w, http.StatusInternalServerError, jsonError{Error: "unable to store passkey"})
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("Passkey registered for user %s", user.Username))
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// newPasswordPolicy reads the rules for new passwords from the environment,
+// using the defaults for any that are unset or invalid. PASSWORD_DENYLIST
+// names a file of extra passwords to refuse, one per line, and
+// PWNED_PASSWORDS_PATH a local copy of the Pwned Passwords corpus. It must be
+// called after the password hasher is loaded.
+func newPasswordPolicy() utils.PasswordPolicy {
+	policy := utils.DefaultPasswordPolicy()
+	policy.MinLength = envInt("PASSWORD_MIN_LENGTH", policy.MinLength)
+	policy.MaxLength = envInt("PASSWORD_MAX_LENGTH", policy.MaxLength)
+	// bcrypt refuses longer passwords, which would fail the request later
+	policy.MaxBytes = utils.MaxPasswordBytes()
+	policy.MinStrength = envInt("PASSWORD_MIN_STRENGTH", policy.MinStrength)
+	if policy.MinStrength > utils.StrengthVerySecure {
+		logs.Logs(logWarning, fmt.Sprintf("PASSWORD_MIN_STRENGTH must be between 0 and %d. Defaulting to %d...", utils.StrengthVerySecure, utils.StrengthVerySecure))
+		policy.MinStrength = utils.StrengthVerySecure
+	}
+
+	path := os.Getenv("PASSWORD_DENYLIST")
+	if path != "" {
+		err := policy.LoadDenyList(path)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to load PASSWORD_DENYLIST: %s", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	path = os.Getenv("PWNED_PASSWORDS_PATH")
+	if path != "" {
+		corpus, err := utils.OpenPwnedPasswords(path)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to open PWNED_PASSWORDS_PATH: %s", err.Error()))
+			os.Exit(1)
+		}
+		policy.Breached = corpus
+	}
+	return policy
+}
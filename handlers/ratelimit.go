@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+// NewLimiter creates the limiter behind each rate limited route. It keeps
+// counts in memory; replace it before StartHTTPServer to share counts
+// between instances.
+var NewLimiter = func(requests int, period time.Duration) middleware.Limiter {
+	return middleware.NewMemoryLimiter(requests, period)
+}
+
+// rateLimit builds the rate limiting middleware for one route, reading its
+// limit, such as "10/1m", from the setting environment variable.
+func rateLimit(setting, def string, keys ...middleware.KeyFunc) func(http.HandlerFunc) http.HandlerFunc {
+	limit := os.Getenv(setting)
+	if limit == "" {
+		limit = def
+	}
+	requests, period, err := middleware.ParseLimit(limit)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid %s: %s. Defaulting to %s...", setting, err.Error(), def))
+		requests, period, _ = middleware.ParseLimit(def)
+	}
+	return middleware.RateLimit(NewLimiter(requests, period), keys...)
+}
+
+// byUsername counts requests against the canonical form of the username
+// field, so "Alice" and "alice" share one allowance.
+func byUsername(r *http.Request) string {
+	username := validation.CanonicalUsername(r.FormValue("username"))
+	if username == "" {
+		return ""
+	}
+	return "username:" + username
+}
+
+// byEmail counts requests against the normalized email field, so one
+// address shares one allowance however it is capitalized.
+func byEmail(r *http.Request) string {
+	email, errs := validation.Email(r.FormValue("email"))
+	if errs != nil {
+		return ""
+	}
+	return "email:" + email
+}
+
+// byMFAUser counts second factor attempts against the user the MFA challenge
+// cookie belongs to, so starting new challenges does not reset the count.
+func (s *Server) byMFAUser(r *http.Request) string {
+	challengeToken, found := middleware.Cookies.Get(r, mfaChallengeCookie)
+	if !found {
+		return ""
+	}
+	challenge, err := s.store.GetMFAChallenge(challengeToken)
+	if err != nil {
+		return ""
+	}
+	return "username:" + challenge.Username
+}
+
+// byCurrentUser counts requests against the logged in user, so their
+// requests, such as guesses at their current password, share one allowance
+// across devices and addresses.
+func byCurrentUser(r *http.Request) string {
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		return ""
+	}
+	return "username:" + user.Username
+}
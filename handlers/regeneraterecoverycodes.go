@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func (s *Server) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to MFA setup page...", r.Method))
+		http.Redirect(w, r, "/mfa-setup", http.StatusSeeOther)
+		return
+	}
+
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		logs.Logs(logWarning, "Request has no logged in user. Redirecting back to login page...")
+		middleware.RedirectToLogin(w, r)
+		return
+	}
+
+	_, enabled, err := s.store.GetTOTP(user.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to get TOTP status: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if !enabled {
+		logs.Logs(logWarning, fmt.Sprintf("User %s has no second factor to recover. Redirecting back to MFA setup page...", user.Username))
+		http.Redirect(w, r, "/mfa-setup", http.StatusSeeOther)
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("Regenerating recovery codes for user %s", user.Username))
+	s.issueRecoveryCodes(w, r, user.Username)
+}
+
+// issueRecoveryCodes replaces the user's recovery codes with a new set and
+// shows them. Any codes issued before stop working.
+func (s *Server) issueRecoveryCodes(w http.ResponseWriter, r *http.Request, username string) {
+	codes := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	err := s.store.ReplaceRecoveryCodes(username, codes)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to store recovery codes: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	// the page holds secrets, keep it out of caches and history
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplate(w, r, "recovery_codes.html", RecoveryCodesPage{Codes: codes})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/mailer"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+func (s *Server) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to magic link page...", r.Method))
+		http.Redirect(w, r, "/magic-link", http.StatusSeeOther)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	email, errs := validation.Email(r.FormValue("email"))
+	if errs != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		renderMagicLink(w, r, MagicLinkPage{Error: errs[0].Message})
+		return
+	}
+
+	link, err := s.store.CreateMagicLink(email)
+	if err == db.ErrUserNotFound {
+		// answer exactly as for a registered address, cookie included, so
+		// the form cannot be used to find out which addresses have accounts
+		logs.Logs(logWarning, "Magic link requested for an unknown email address")
+		setMagicLinkCookie(w, utils.GenerateToken(32), time.Now().Add(db.MagicLinkLifetime))
+		renderMagicLink(w, r, MagicLinkPage{Sent: true})
+		return
+	}
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create magic link: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	setMagicLinkCookie(w, link.BrowserToken, link.ExpiresAt)
+
+	// send after answering, so the response takes as long as for an unknown
+	// address and its timing does not reveal the account either
+	go sendMagicLink(link)
+	renderMagicLink(w, r, MagicLinkPage{Sent: true})
+}
+
+// sendMagicLink emails the login link. It runs after the response is sent,
+// so failures are only logged; the user can ask again.
+func sendMagicLink(link db.MagicLink) {
+	loginURL := fmt.Sprintf("%s/magic-login?token=%s", siteURL(), url.QueryEscape(link.Token))
+	err := Mail.Send(mailer.Message{
+		To:      link.Email,
+		Subject: "Your login link",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Open this link within the next %d minutes to log in. It only works once, and only in the browser you requested it from:\n\n%s\n\n"+
+			"If you didn't ask to log in, you can ignore this email.\n", link.Username, magicLinkMinutes(), loginURL),
+	})
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to send magic link email: %s", err.Error()))
+		return
+	}
+	logs.Logs(logInfo, fmt.Sprintf("Magic link sent for user %s", link.Username))
+}
+
+// magicLinkMinutes is how long a magic link works, as told to users.
+func magicLinkMinutes() int {
+	return int(db.MagicLinkLifetime / time.Minute)
+}
+
+// setMagicLinkCookie hands the browser the token a magic link must be opened
+// with. It must still be sent when the link is followed from an email.
+func setMagicLinkCookie(w http.ResponseWriter, browserToken string, expires time.Time) {
+	middleware.Cookies.SetForLinks(w, magicLinkCookie, browserToken, expires)
+}
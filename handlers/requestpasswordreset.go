@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/mailer"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+func (s *Server) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to forgot password page...", r.Method))
+		http.Redirect(w, r, "/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	username := validation.CanonicalUsername(r.FormValue("username"))
+
+	// the response is the same whether or not the user exists, and is sent
+	// before the account is even looked up, so neither the page nor how long
+	// it takes tells which usernames are registered
+	go s.sendPasswordReset(username)
+	renderForgotPassword(w, r, ForgotPasswordPage{Sent: true})
+}
+
+// sendPasswordReset emails the user a password reset link if they have a
+// verified address. It runs after the response is sent, so failures are only
+// logged; the user can ask again.
+func (s *Server) sendPasswordReset(username string) {
+	email, verified, err := s.store.GetEmail(username)
+	if err == db.ErrUserNotFound {
+		logs.Logs(logWarning, fmt.Sprintf("Password reset requested for unknown user %s", username))
+		return
+	}
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to get email address: %s", err.Error()))
+		return
+	}
+
+	// links only go to verified addresses, a mistyped one could belong to
+	// someone else
+	if email == "" || !verified {
+		logs.Logs(logWarning, fmt.Sprintf("Password reset requested for user %s without a verified email address", username))
+		return
+	}
+
+	reset, err := s.store.CreatePasswordReset(username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create password reset: %s", err.Error()))
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", siteURL(), url.QueryEscape(reset.Token))
+	err = Mail.Send(mailer.Message{
+		To:      email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Someone asked to reset the password of your account %s.\n\n"+
+			"To choose a new password, open this link within the next hour:\n\n%s\n\n"+
+			"If it wasn't you, ignore this email and your password will stay the same.\n", username, link),
+	})
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to send password reset email: %s", err.Error()))
+		return
+	}
+	logs.Logs(logInfo, fmt.Sprintf("Password reset email sent for user %s", username))
+}
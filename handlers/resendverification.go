@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+func (s *Server) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to dashboard page...", r.Method))
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	// logged in users resend for themselves; under the block policy users
+	// cannot log in yet, so the form carries the username instead
+	username := validation.CanonicalUsername(r.FormValue("username"))
+	session, err := middleware.AuthorizeRequest(s.store, r)
+	if err == nil {
+		username = session.Username
+	}
+	page := VerifyEmailPage{Username: username}
+
+	// the answer is the same whether or not a link goes out, and is sent
+	// before the account is even looked up, so neither the page nor how long
+	// it takes tells which usernames are registered
+	go s.resendVerification(username)
+	page.Sent = true
+	renderVerifyEmail(w, r, page)
+}
+
+// resendVerification emails the user a new verification link. It runs after
+// the response is sent, so failures are only logged; the user can ask again.
+func (s *Server) resendVerification(username string) {
+	err := s.sendVerificationEmail(username)
+	switch err {
+	case nil:
+		logs.Logs(logInfo, fmt.Sprintf("Verification email resent for user %s", username))
+	case db.ErrUserNotFound, db.ErrNoEmail, db.ErrVerificationTooSoon:
+		// a link sent within the last minute is not sent again
+		logs.Logs(logWarning, fmt.Sprintf("Verification email requested for user %s: %s", username, err.Error()))
+	default:
+		logs.Logs(logErr, fmt.Sprintf("Failed to send verification email: %s", err.Error()))
+	}
+}
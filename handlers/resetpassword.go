@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+func ResetPassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to forgot password page...", r.Method))
+		http.Redirect(w, r, "/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		logs.Logs(logWarning, "Password reset token is missing. Redirecting back to forgot password page...")
+		http.Redirect(w, r, "/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	// the token is only checked, and used up, when the new password is submitted
+	renderResetPassword(w, r, ResetPasswordPage{Token: token})
+}
+
+func renderResetPassword(w http.ResponseWriter, r *http.Request, page ResetPasswordPage) {
+	// keep the token in the URL from leaking to other sites
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("Cache-Control", "no-store")
+	renderTemplate(w, r, "reset_password.html", page)
+}
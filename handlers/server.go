@@ -5,12 +5,34 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
 	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/mailer"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
 )
 
-func StartHTTPServer() {
+// StartHTTPServer registers the routes and serves them, using store for all
+// user, session and CSRF persistence.
+func StartHTTPServer(store db.Store) {
 	logs.Logs(logInfo, "Starting HTTP server...")
 
+	WebAuthn = newWebAuthnConfig()
+	Mail = mailer.FromEnv()
+	EmailPolicy = newEmailPolicy()
+	Lockout = newLockoutPolicy()
+	Passwords = newPasswordPolicy()
+	middleware.Cookies = newCookieManager()
+	s := NewServer(NewSessionStore(store))
+
+	// only believe X-Forwarded-For from our own reverse proxies
+	proxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse TRUSTED_PROXIES: %s", err.Error()))
+		os.Exit(1)
+	}
+	middleware.TrustedProxies = proxies
+	middleware.RateLimitFailClosed = os.Getenv("RATE_LIMIT_FAIL_CLOSED") == "true"
+
 	// initialize templates
 	InitTemplates()
 
@@ -19,14 +41,45 @@ func StartHTTPServer() {
 	var staticFiles = http.FileServer(http.Dir("./static"))
 	http.Handle("/static/", http.StripPrefix("/static/", staticFiles))
 
+	// routes wrapped in authenticated only run for logged in users, who they
+	// find with middleware.CurrentUser
+	authenticated := middleware.RequireAuth(s.store)
+
 	// define routes
 	http.HandleFunc("/", IndexRoute)
 	http.HandleFunc("/account", Account)
-	http.HandleFunc("/create-account", CreateAccount)
+	http.HandleFunc("/create-account", rateLimit("RATE_LIMIT_SIGNUP", "5/1h", middleware.ByIP, byUsername)(s.CreateAccount))
 	http.HandleFunc("/login", Login)
-	http.HandleFunc("/submit-login", SubmitLogin)
-	http.HandleFunc("/dashboard", Dashboard)
-	http.HandleFunc("/logout", LogoutUser)
+	http.HandleFunc("/submit-login", rateLimit("RATE_LIMIT_LOGIN", "10/1m", middleware.ByIP, byUsername)(s.SubmitLogin))
+	http.Handle("/dashboard", authenticated(http.HandlerFunc(s.Dashboard)))
+	http.HandleFunc("/logout", rateLimit("RATE_LIMIT_LOGOUT", "30/1m", middleware.ByIP, byCurrentUser)(authenticated(http.HandlerFunc(s.LogoutUser)).ServeHTTP))
+	http.HandleFunc("/magic-link", MagicLink)
+	http.HandleFunc("/request-magic-link", rateLimit("RATE_LIMIT_MAGIC_LINK", "5/15m", middleware.ByIP, byEmail)(s.RequestMagicLink))
+	http.HandleFunc("/magic-login", s.MagicLogin)
+	http.HandleFunc("/verify-email", s.VerifyEmail)
+	http.HandleFunc("/resend-verification", rateLimit("RATE_LIMIT_RESEND_VERIFICATION", "5/15m", middleware.ByIP, byUsername)(s.ResendVerification))
+	http.HandleFunc("/forgot-password", ForgotPassword)
+	http.HandleFunc("/request-password-reset", rateLimit("RATE_LIMIT_PASSWORD_RESET", "5/15m", middleware.ByIP, byUsername)(s.RequestPasswordReset))
+	http.HandleFunc("/reset-password", ResetPassword)
+	http.HandleFunc("/submit-password-reset", s.SubmitPasswordReset)
+	http.Handle("/change-password", authenticated(http.HandlerFunc(ChangePassword)))
+	http.HandleFunc("/submit-password-change", rateLimit("RATE_LIMIT_PASSWORD_CHANGE", "5/15m", middleware.ByIP, byCurrentUser)(authenticated(http.HandlerFunc(s.SubmitPasswordChange)).ServeHTTP))
+	http.Handle("/mfa-setup", authenticated(http.HandlerFunc(s.MFASetup)))
+	http.Handle("/confirm-mfa", authenticated(http.HandlerFunc(s.ConfirmMFA)))
+	http.Handle("/regenerate-recovery-codes", authenticated(http.HandlerFunc(s.RegenerateRecoveryCodes)))
+	http.HandleFunc("/verify-mfa", VerifyMFA)
+	http.HandleFunc("/submit-mfa", rateLimit("RATE_LIMIT_MFA", "10/5m", middleware.ByIP, s.byMFAUser)(s.SubmitMFA))
+	http.HandleFunc("/admin/users", middleware.RequirePermission(s.store, "admin:users")(s.AdminUsers))
+	http.HandleFunc("/admin/update-role", middleware.RequirePermission(s.store, "admin:users")(s.UpdateRole))
+	http.HandleFunc("/admin/unlock-user", middleware.RequirePermission(s.store, "admin:users")(s.UnlockUser))
+	http.Handle("/webauthn/register/begin", authenticated(http.HandlerFunc(s.BeginPasskeyRegistration)))
+	http.Handle("/webauthn/register/finish", authenticated(http.HandlerFunc(s.FinishPasskeyRegistration)))
+	http.HandleFunc("/webauthn/login/begin", rateLimit("RATE_LIMIT_PASSKEY_LOGIN", "30/1m", middleware.ByIP)(s.BeginPasskeyLogin))
+	http.HandleFunc("/webauthn/login/finish", s.FinishPasskeyLogin)
+
+	// every form post and script request must carry the CSRF token of the
+	// page it came from
+	handler := middleware.CSRFProtect(s.store)(http.DefaultServeMux)
 
 	// initialize port
 	httpPort := os.Getenv("PORT")
@@ -34,7 +87,7 @@ func StartHTTPServer() {
 	if httpPort == "" {
 		logs.Logs(logWarning, "Could not get PORT from hosting platform. Deafaulting to http://localhost:9003...")
 		httpPort = "9003"
-		err := http.ListenAndServe(fmt.Sprintf(":%s", httpPort), nil)
+		err := http.ListenAndServe(fmt.Sprintf(":%s", httpPort), handler)
 		if err != nil {
 			logs.Logs(logErr, fmt.Sprintf("Failed to start HTTP server: %s", err.Error()))
 		}
@@ -42,7 +95,7 @@ func StartHTTPServer() {
 
 	// start the server on hosting platform
 	logs.Logs(logInfo, fmt.Sprintf("HTTP server started on http://localhost:%s", httpPort))
-	err := http.ListenAndServe(fmt.Sprintf(":%s", httpPort), nil)
+	err = http.ListenAndServe(fmt.Sprintf(":%s", httpPort), handler)
 	if err != nil {
 		logs.Logs(logErr, fmt.Sprintf("Failed to start HTTP server: %s", err.Error()))
 	}
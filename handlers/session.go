@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+/*
+startSession creates a new session for the user and hands its session and
+CSRF tokens to the client as cookies. Every login path calls this once the
+user has fully authenticated.
+
+Returns:
+
+- error: An error if the session cannot be created.
+*/
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, username string) error {
+	// start a new session for this device, other sessions stay logged in
+	session, err := s.store.CreateSession(username, middleware.ClientIP(r), r.UserAgent())
+	if err != nil {
+		return err
+	}
+
+	// set session and CSRF cookies for client, both expire with the session
+	// in the database; pages embed the CSRF token, scripts never read it
+	middleware.Cookies.Set(w, middleware.SessionCookie, session.Token, session.ExpiresAt)
+	middleware.Cookies.Set(w, middleware.CSRFCookie, session.CSRFToken, session.ExpiresAt)
+	return nil
+}
+
+/*
+completeLogin takes over once the user proved who they are with a first
+factor, such as a password or a magic link: it enforces the email
+verification policy, sends users with TOTP enabled on to the second factor,
+and otherwise starts a session and redirects to the dashboard.
+
+attemptCounted tells whether the first factor already counted the login
+attempt with beginLoginAttempt, as password logins do. The MFA challenge
+carries that attempt, so its first code is not counted twice; first factors
+that count nothing have the attempt counted here.
+*/
+func (s *Server) completeLogin(w http.ResponseWriter, r *http.Request, username string, attemptCounted bool) {
+	_, totpEnabled, err := s.store.GetTOTP(username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to get TOTP status: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	// the first factor is proven; with TOTP enabled the attempt stays counted
+	// until a valid code is entered, so wrong codes count as failures too
+	if !totpEnabled {
+		s.forgetLoginFailures(username)
+	}
+
+	// under the block policy an unverified address stops the login here
+	if EmailPolicy == verificationBlock {
+		pending, err := s.emailVerificationPending(username)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to get email status: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		if pending {
+			logs.Logs(logWarning, fmt.Sprintf("User %s has not verified their email address. Login refused...", username))
+			w.WriteHeader(http.StatusForbidden)
+			renderVerifyEmail(w, r, VerifyEmailPage{Username: username})
+			return
+		}
+	}
+
+	// users with a second factor must enter a code before a session is issued
+	if totpEnabled {
+		if !attemptCounted {
+			wait, locked, err := s.beginLoginAttempt(username)
+			if err != nil {
+				logs.Logs(logErr, fmt.Sprintf("Failed to count login attempt: %s", err.Error()))
+				http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+			if wait > 0 {
+				logs.Logs(logWarning, fmt.Sprintf("Second factor for user %s refused after repeated failed attempts", username))
+				refuseLogin(w, r, wait, locked)
+				return
+			}
+		}
+
+		challenge, err := s.store.CreateMFAChallenge(username)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to create MFA challenge: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		middleware.Cookies.Set(w, mfaChallengeCookie, challenge.Token, challenge.ExpiresAt)
+		logs.Logs(logInfo, fmt.Sprintf("User %s needs a second factor. Redirecting to verification page...", username))
+		http.Redirect(w, r, "/verify-mfa", http.StatusSeeOther)
+		return
+	}
+
+	err = s.startSession(w, r, username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create session: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	// redirect to dashboard page if authentication is successful
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// Session modes, picked with the SESSION_MODE variable.
+const (
+	sessionStateful  = "stateful"  // sessions are rows in the database, looked up on every request
+	sessionStateless = "stateless" // sessions are sealed tokens, only revocations are stored
+)
+
+/*
+NewSessionStore returns the store to serve sessions from, as set by the
+SESSION_MODE variable: the store itself for stateful sessions (the default),
+or a db.StatelessStore wrapping it. Stateless sessions are sealed with the
+COOKIE_KEYS keys, which must be set so sessions survive restarts and work on
+every instance, and check a revocation list unless SESSION_REVOCATION is
+false, reloaded every SESSION_REVOCATION_REFRESH (default 30s).
+*/
+func NewSessionStore(store db.Store) db.Store {
+	mode := os.Getenv("SESSION_MODE")
+	switch mode {
+	case sessionStateful, "":
+		return store
+	case sessionStateless:
+	default:
+		logs.Logs(logWarning, fmt.Sprintf("Unknown SESSION_MODE %q. Defaulting to %s...", mode, sessionStateful))
+		return store
+	}
+
+	// a random key would end every session on restart and make them
+	// invalid on all other instances
+	if os.Getenv("COOKIE_KEYS") == "" {
+		logs.Logs(logErr, "SESSION_MODE=stateless needs COOKIE_KEYS, shared by every instance")
+		os.Exit(1)
+	}
+
+	// share the cookie manager's keys once the server has loaded them
+	codec := middleware.Cookies.Codec
+	if codec == nil {
+		var err error
+		codec, err = utils.LoadCookieCodec()
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to load cookie keys: %s", err.Error()))
+			os.Exit(1)
+		}
+	}
+	config := db.StatelessConfig{
+		Codec:        codec,
+		Revocations:  true,
+		RefreshEvery: envDuration("SESSION_REVOCATION_REFRESH", 30*time.Second),
+	}
+	if value := os.Getenv("SESSION_REVOCATION"); value != "" {
+		revocations, err := strconv.ParseBool(value)
+		if err != nil {
+			logs.Logs(logWarning, fmt.Sprintf("Invalid SESSION_REVOCATION %q. Defaulting to true...", value))
+		} else {
+			config.Revocations = revocations
+		}
+	}
+	if !config.Revocations {
+		logs.Logs(logWarning, "SESSION_REVOCATION is off. Logging out, resetting a password and revoking a role do not end existing sessions: a stolen session token stays valid for up to 24 hours.")
+	}
+
+	logs.Logs(logInfo, "Using stateless sessions")
+	return db.NewStatelessStore(store, config)
+}
@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/Bevs-n-Devs/WebAuthentication/db"
 	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
 )
 
-func SubmitLogin(w http.ResponseWriter, r *http.Request) {
+func (s *Server) SubmitLogin(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to index page...", r.Method))
 		http.Redirect(w, r, "/", http.StatusSeeOther)
@@ -24,47 +28,55 @@ func SubmitLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get form data
-	username := r.FormValue("username")
+	username := validation.CanonicalUsername(r.FormValue("username"))
 	password := r.FormValue("password")
 
-	// check if user exists in database
-	exists, err := db.AuthenticateUser(username, password)
+	// refuse the attempt without checking the password while the username
+	// is backing off or locked out
+	wait, locked, err := s.beginLoginAttempt(username)
 	if err != nil {
-		logs.Logs(logErr, fmt.Sprintf("Failed to authenticate user: %s", err.Error()))
+		logs.Logs(logErr, fmt.Sprintf("Failed to count login attempt: %s", err.Error()))
 		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
 		return
 	}
-
-	if !exists {
-		logs.Logs(logWarning, "User does not exist or invalid password. Redirecting back to login page...")
-		http.Redirect(w, r, "/login", http.StatusSeeOther)
+	if wait > 0 {
+		logs.Logs(logWarning, fmt.Sprintf("Login for user %s refused after repeated failed attempts", username))
+		refuseLogin(w, r, wait, locked)
 		return
 	}
 
-	// add tokens to user in database
-	sessionToken, csrfToken, expiry, err := db.UpdateSessionTokens(username)
-	if err != nil {
-		logs.Logs(logErr, fmt.Sprintf("Failed to update session tokens: %s", err.Error()))
+	// check if user exists in database
+	exists, err := s.store.AuthenticateUser(username, password)
+	if err != nil && err != db.ErrUserNotFound && err != db.ErrInvalidPassword {
+		logs.Logs(logErr, fmt.Sprintf("Failed to authenticate user: %s", err.Error()))
 		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
 		return
 	}
 
-	// set session cookie for client
-	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
-		Value:    sessionToken,
-		Expires:  expiry, // exires in 24hrs (same as database expiry)
-		HttpOnly: true,
-	})
+	if !exists {
+		// the attempt is already counted, unknown usernames too, so a
+		// lockout gives nothing away
+		logs.Logs(logWarning, "User does not exist or invalid password. Redirecting back to login page...")
+		w.WriteHeader(http.StatusUnauthorized)
+		renderLogin(w, r, LoginPage{Error: "Invalid username or password."})
+		return
+	}
 
-	// set CSRF token in a cookie for client
-	http.SetCookie(w, &http.Cookie{
-		Name:     "csrf_token",
-		Value:    csrfToken,
-		Expires:  expiry, // exires in 24hrs (same as database expiry)
-		HttpOnly: false,  // allows client to access CSRF token
-	})
+	s.completeLogin(w, r, username, true)
+}
+
+// refuseLogin answers a login attempt made during a back-off delay or
+// lockout. The message is the same for every username, existing or not.
+func refuseLogin(w http.ResponseWriter, r *http.Request, wait time.Duration, locked bool) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
 
-	// redirect to dashboard page if authentication is successful
-	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	page := LoginPage{Error: fmt.Sprintf("Too many failed login attempts. Please wait %d seconds before trying again.", seconds)}
+	if locked {
+		minutes := int(math.Ceil(wait.Minutes()))
+		page.Error = fmt.Sprintf("Too many failed login attempts. Logging in with a password or login link is locked for %d minutes. "+
+			"You can still reset your password or log in with a passkey.", minutes)
+	}
+	renderLogin(w, r, page)
 }
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func (s *Server) SubmitMFA(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to login page...", r.Method))
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	challengeToken, found := middleware.Cookies.Get(r, mfaChallengeCookie)
+	if !found {
+		logs.Logs(logWarning, "MFA challenge cookie is missing. Redirecting back to login page...")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	challenge, err := s.store.GetMFAChallenge(challengeToken)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Failed to get MFA challenge: %s. Redirecting back to login page...", err.Error()))
+		middleware.Cookies.Clear(w, mfaChallengeCookie)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// parse form data
+	err = r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	code := r.FormValue("code")
+
+	// codes are counted before they are checked, like passwords
+	attempts, err := s.store.CountMFAChallengeAttempt(challenge.ID)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to count MFA attempt: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if attempts > maxMFAAttempts {
+		logs.Logs(logWarning, fmt.Sprintf("Too many invalid codes for user %s. Redirecting back to login page...", challenge.Username))
+		s.store.DeleteMFAChallenge(challenge.ID)
+		middleware.Cookies.Clear(w, mfaChallengeCookie)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// second factor codes share the password login's limit. The first code
+	// goes in under the attempt counted when the challenge was issued, later
+	// ones are counted as attempts of their own
+	var wait time.Duration
+	var locked bool
+	if attempts > 1 {
+		wait, locked, err = s.beginLoginAttempt(challenge.Username)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to count login attempt: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+	}
+	if locked {
+		logs.Logs(logWarning, fmt.Sprintf("Second factor for user %s refused while locked out", challenge.Username))
+		s.store.DeleteMFAChallenge(challenge.ID)
+		middleware.Cookies.Clear(w, mfaChallengeCookie)
+		refuseLogin(w, r, wait, locked)
+		return
+	}
+	if wait > 0 {
+		logs.Logs(logWarning, fmt.Sprintf("Second factor for user %s refused after repeated failed attempts", challenge.Username))
+		seconds := int(math.Ceil(wait.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		w.WriteHeader(http.StatusTooManyRequests)
+		renderMFAVerify(w, r, MFAVerifyPage{Error: fmt.Sprintf("Too many failed attempts. Please wait %d seconds before trying again.", seconds)})
+		return
+	}
+
+	secret, enabled, err := s.store.GetTOTP(challenge.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to get TOTP secret: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	ok := false
+	if enabled && !isTOTPCode(code) {
+		// anything that is not a 6-digit code is tried as a recovery code
+		ok, err = s.store.UseRecoveryCode(challenge.Username, code)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to use recovery code: %s", err.Error()))
+			http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+			return
+		}
+		if ok {
+			logs.Logs(logWarning, fmt.Sprintf("User %s used a recovery code", challenge.Username))
+		}
+	} else if enabled {
+		var step int64
+		step, ok = utils.ValidateTOTP(secret, code, time.Now())
+		if ok {
+			// a code that was already used is treated as wrong
+			ok, err = s.store.UseTOTPStep(challenge.Username, step)
+			if err != nil {
+				logs.Logs(logErr, fmt.Sprintf("Failed to record TOTP code: %s", err.Error()))
+				http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if !ok {
+		if attempts >= maxMFAAttempts {
+			logs.Logs(logWarning, fmt.Sprintf("Too many invalid codes for user %s. Redirecting back to login page...", challenge.Username))
+			s.store.DeleteMFAChallenge(challenge.ID)
+			middleware.Cookies.Clear(w, mfaChallengeCookie)
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		logs.Logs(logWarning, fmt.Sprintf("Invalid second factor for user %s", challenge.Username))
+		w.WriteHeader(http.StatusUnauthorized)
+		renderMFAVerify(w, r, MFAVerifyPage{Error: "That code is not valid. Please try again."})
+		return
+	}
+
+	// the challenge is single use
+	err = s.store.DeleteMFAChallenge(challenge.ID)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to delete MFA challenge: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	middleware.Cookies.Clear(w, mfaChallengeCookie)
+	s.forgetLoginFailures(challenge.Username)
+
+	err = s.startSession(w, r, challenge.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create session: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("User %s passed the second factor. Redirecting to dashboard page...", challenge.Username))
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
+
+// isTOTPCode reports whether code looks like a 6-digit TOTP code rather than a
+// recovery code.
+func isTOTPCode(code string) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != 6 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// postForm sends the form to the handler with the cookies of earlier
+// responses, and adds the cookies it sets to them.
+func postForm(handler http.HandlerFunc, path string, form url.Values, cookies *[]*http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range *cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	*cookies = append(*cookies, w.Result().Cookies()...)
+	return w
+}
+
+func TestTOTPLoginCountsOneAttempt(t *testing.T) {
+	s := newLockoutServer(t, lockoutPolicy{BackoffAfter: 100, LockAfter: 10, LockDuration: time.Hour, Window: time.Hour})
+	secret := utils.GenerateTOTPSecret()
+	for _, err := range []error{
+		s.store.CreateUser("alice", "", "Plum-Kettle-93"),
+		s.store.SetTOTPSecret("alice", secret),
+		s.store.EnableTOTP("alice"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	failures := func() int {
+		t.Helper()
+		n, _, err := s.store.GetLoginFailures("alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	var cookies []*http.Cookie
+	w := postForm(s.SubmitLogin, "/submit-login", url.Values{"username": {"alice"}, "password": {"Plum-Kettle-93"}}, &cookies)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/verify-mfa" {
+		t.Fatalf("password step: got %d to %q, want a redirect to /verify-mfa", w.Code, w.Header().Get("Location"))
+	}
+	if n := failures(); n != 1 {
+		t.Fatalf("after the password step %d attempts are counted, want 1", n)
+	}
+
+	code, err := utils.TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrong := code[:5] + string('0'+(code[5]-'0'+5)%10)
+
+	// the first code goes in under the password step's attempt
+	w = postForm(s.SubmitMFA, "/submit-mfa", url.Values{"code": {wrong}}, &cookies)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("first wrong code: got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if n := failures(); n != 1 {
+		t.Fatalf("after the first code %d attempts are counted, want 1", n)
+	}
+	// later ones count as attempts of their own
+	w = postForm(s.SubmitMFA, "/submit-mfa", url.Values{"code": {wrong}}, &cookies)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("second wrong code: got %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if n := failures(); n != 2 {
+		t.Fatalf("after the second code %d attempts are counted, want 2", n)
+	}
+
+	w = postForm(s.SubmitMFA, "/submit-mfa", url.Values{"code": {code}}, &cookies)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/dashboard" {
+		t.Fatalf("right code: got %d to %q, want a redirect to /dashboard", w.Code, w.Header().Get("Location"))
+	}
+	if n := failures(); n != 0 {
+		t.Fatalf("after logging in %d attempts are counted, want none", n)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func (s *Server) SubmitPasswordChange(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to change password page...", r.Method))
+		http.Redirect(w, r, "/change-password", http.StatusSeeOther)
+		return
+	}
+
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		logs.Logs(logWarning, "Request has no logged in user. Redirecting back to login page...")
+		middleware.RedirectToLogin(w, r)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	currentPassword := r.FormValue("current_password")
+	password := r.FormValue("password")
+
+	if password == "" || password != r.FormValue("confirm_password") {
+		w.WriteHeader(http.StatusBadRequest)
+		renderChangePassword(w, r, ChangePasswordPage{Error: "The new passwords do not match."})
+		return
+	}
+
+	// a stolen session alone must not be enough to take over the account
+	valid, err := s.store.AuthenticateUser(user.Username, currentPassword)
+	if err != nil && err != db.ErrUserNotFound && err != db.ErrInvalidPassword {
+		logs.Logs(logErr, fmt.Sprintf("Failed to authenticate user: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if !valid {
+		logs.Logs(logWarning, fmt.Sprintf("Wrong current password to change the password of user %s", user.Username))
+		w.WriteHeader(http.StatusUnauthorized)
+		renderChangePassword(w, r, ChangePasswordPage{Error: "Your current password is wrong."})
+		return
+	}
+
+	errs := Passwords.Check(user.Username, password)
+	if errs != nil {
+		logs.Logs(logWarning, fmt.Sprintf("New password for user %s breaks %d rules: %s", user.Username, len(errs), ruleNames(errs)))
+		w.WriteHeader(http.StatusBadRequest)
+		renderChangePassword(w, r, ChangePasswordPage{Errors: errs})
+		return
+	}
+
+	err = s.store.UpdatePassword(user.Username, password)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to update password: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	// whoever knew the old password must not stay logged in elsewhere; this
+	// device gets a fresh session
+	err = s.store.DeleteUserSessions(user.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to delete sessions: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	err = s.startSession(w, r, user.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to create session: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("Password changed for user %s. Redirecting to dashboard page...", user.Username))
+	http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+}
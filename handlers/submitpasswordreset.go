@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func (s *Server) SubmitPasswordReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to forgot password page...", r.Method))
+		http.Redirect(w, r, "/forgot-password", http.StatusSeeOther)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	token := r.FormValue("token")
+	password := r.FormValue("password")
+
+	// check the form before spending the single-use token
+	if password == "" || password != r.FormValue("confirm_password") {
+		w.WriteHeader(http.StatusBadRequest)
+		renderResetPassword(w, r, ResetPasswordPage{Token: token, Error: "The passwords do not match."})
+		return
+	}
+
+	// look the reset up first, the password rules need the username
+	reset, err := s.store.GetPasswordReset(token)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Failed to get password reset: %s", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		renderForgotPassword(w, r, ForgotPasswordPage{Error: "That reset link is invalid or has expired. Please request a new one."})
+		return
+	}
+	errs := Passwords.Check(reset.Username, password)
+	if errs != nil {
+		logs.Logs(logWarning, fmt.Sprintf("New password for user %s breaks %d rules: %s", reset.Username, len(errs), ruleNames(errs)))
+		w.WriteHeader(http.StatusBadRequest)
+		renderResetPassword(w, r, ResetPasswordPage{Token: token, Errors: errs})
+		return
+	}
+
+	reset, err = s.store.ConsumePasswordReset(token)
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Failed to use password reset: %s", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		renderForgotPassword(w, r, ForgotPasswordPage{Error: "That reset link is invalid or has expired. Please request a new one."})
+		return
+	}
+
+	err = s.store.UpdatePassword(reset.Username, password)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to update password: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	// whoever knew the old password must not stay logged in
+	err = s.store.DeleteUserSessions(reset.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to delete sessions: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	// the new password works straight away, even if the old one was locked out
+	err = s.store.ResetLoginFailures(reset.Username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to reset login failures: %s", err.Error()))
+	}
+	middleware.Cookies.Clear(w, middleware.SessionCookie)
+	middleware.Cookies.Clear(w, middleware.CSRFCookie)
+
+	logs.Logs(logInfo, fmt.Sprintf("Password reset for user %s. Redirecting to login page...", reset.Username))
+	setFlash(w, "Your password has been changed. Log in with your new password.")
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+)
+
+func TestSubmitPasswordReset(t *testing.T) {
+	s, _ := newMailServer(t, verificationOff)
+	if err := s.store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93"); err != nil {
+		t.Fatal(err)
+	}
+	session, err := s.store.CreateSession("alice", "192.0.2.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reset, err := s.store.CreatePasswordReset("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit := func(password, confirm string) *http.Response {
+		t.Helper()
+		var cookies []*http.Cookie
+		form := url.Values{"token": {reset.Token}, "password": {password}, "confirm_password": {confirm}}
+		return postForm(s.SubmitPasswordReset, "/submit-password-reset", form, &cookies).Result()
+	}
+	usable := func() bool {
+		t.Helper()
+		_, err := s.store.GetPasswordReset(reset.Token)
+		return err == nil
+	}
+
+	// rejected passwords leave the token for another try
+	for _, test := range []struct{ name, password, confirm string }{
+		{"mismatch", "correct horse battery staple", "correct horse battery stapler"},
+		{"policy", "password", "password"},
+	} {
+		if response := submit(test.password, test.confirm); response.StatusCode != http.StatusBadRequest {
+			t.Fatalf("%s: got %d, want 400", test.name, response.StatusCode)
+		}
+		if !usable() {
+			t.Fatalf("%s: the rejected password used up the reset token", test.name)
+		}
+	}
+	if ok, _ := s.store.AuthenticateUser("alice", "Plum-Kettle-93"); !ok {
+		t.Fatal("a rejected reset changed the password")
+	}
+
+	response := submit("correct horse battery staple", "correct horse battery staple")
+	if response.StatusCode != http.StatusSeeOther || response.Header.Get("Location") != "/login" {
+		t.Fatalf("reset: got %d to %q, want a redirect to /login", response.StatusCode, response.Header.Get("Location"))
+	}
+	if ok, _ := s.store.AuthenticateUser("alice", "correct horse battery staple"); !ok {
+		t.Fatal("the new password does not work")
+	}
+	// the reset logs out every existing session
+	if _, err := s.store.GetSession(session.Token); err != db.ErrSessionNotFound {
+		t.Fatalf("GetSession after the reset = %v, want ErrSessionNotFound", err)
+	}
+	if usable() {
+		t.Fatal("the reset token works again")
+	}
+	if response := submit("another good passphrase 42", "another good passphrase 42"); response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("reused token: got %d, want 400", response.StatusCode)
+	}
+}
@@ -3,16 +3,50 @@ package handlers
 import (
 	"fmt"
 	"html/template"
+	"net/http"
 	"os"
 
 	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
 )
 
+// templateFuncs are the helpers every template can call. They depend on the
+// request, so these are placeholders replaced by renderTemplate.
+var templateFuncs = template.FuncMap{
+	"csrfField": func() template.HTML { return "" },
+	"csrfToken": func() string { return "" },
+}
+
 func InitTemplates() {
 	var err error
-	Templates, err = template.ParseGlob("templates/*.html")
+	Templates, err = template.New("").Funcs(templateFuncs).ParseGlob("templates/*.html")
 	if err != nil {
 		logs.Logs(logErr, fmt.Sprintf("Failed to parse templates: %s", err.Error()))
 		os.Exit(1)
 	}
 }
+
+/*
+renderTemplate executes the named template for the request. Forms put
+{{csrfField}} inside them to send the request's CSRF token back as a hidden
+field, and scripts read {{csrfToken}} from a meta tag for the X-CSRF-Token
+header.
+*/
+func renderTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	token := middleware.CSRFToken(r)
+	page, err := Templates.Clone()
+	if err == nil {
+		page.Funcs(template.FuncMap{
+			"csrfField": func() template.HTML {
+				return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+					middleware.CSRFField, template.HTMLEscapeString(token)))
+			},
+			"csrfToken": func() string { return token },
+		})
+		err = page.ExecuteTemplate(w, name, data)
+	}
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to execute template: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+	}
+}
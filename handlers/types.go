@@ -1,6 +1,15 @@
 package handlers
 
-import "html/template"
+import (
+	"html/template"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/mailer"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+	"github.com/Bevs-n-Devs/WebAuthentication/webauthn"
+)
 
 const (
 	logInfo    = 1
@@ -9,8 +18,119 @@ const (
 	logDb      = 4
 )
 
-var (
-	Templates *template.Template // global variable to hold HTML templates
+const (
+	mfaChallengeCookie = "mfa_challenge" // holds the token of a login waiting for a second factor
+	maxMFAAttempts     = 5               // wrong codes allowed before the login must start over
+	recoveryCodeCount  = 10              // recovery codes issued when TOTP is enabled or codes are regenerated
+	magicLinkCookie    = "magic_link"    // binds an emailed login link to the browser that asked for it
+)
+
+// Email verification policies, picked with the EMAIL_VERIFICATION variable.
+const (
+	verificationOff      = "off"      // unverified users have full access
+	verificationRestrict = "restrict" // unverified users can log in but only see the verify email page
+	verificationBlock    = "block"    // unverified users cannot log in
+)
 
-	htmlTemplate = template.Must(template.ParseFiles("./templates/index.html"))
+var (
+	Templates   *template.Template   // global variable to hold HTML templates
+	WebAuthn    webauthn.Config      // relying party settings for passkeys, set by StartHTTPServer
+	Mail        mailer.Mailer        // sends password reset and verification emails, set by StartHTTPServer
+	EmailPolicy string               // one of the verification policies, set by StartHTTPServer
+	Lockout     lockoutPolicy        // failed login back-off and lockout, set by StartHTTPServer
+	Passwords   utils.PasswordPolicy // rules for new passwords, set by StartHTTPServer
 )
+
+// Server holds the dependencies of the handlers that read or change stored
+// state. StartHTTPServer builds one and registers its methods as routes.
+type Server struct {
+	store db.Store // persistence layer for users, sessions and tokens
+}
+
+// NewServer returns the handlers backed by store.
+func NewServer(store db.Store) *Server {
+	return &Server{store: store}
+}
+
+// AccountPage is the data rendered into account.html. The entered username
+// and email address are kept when the form is shown again.
+type AccountPage struct {
+	Username string
+	Email    string
+	Errors   validation.Errors // every rule the form breaks
+}
+
+// DashboardPage is the data rendered into dashboard.html. User.Can lets the
+// template show content for some roles only.
+type DashboardPage struct {
+	User middleware.Principal
+}
+
+// AdminUsersPage is the data rendered into admin_users.html.
+type AdminUsersPage struct {
+	Users []db.UserSummary
+	Error string
+}
+
+// LoginPage is the data rendered into login.html.
+type LoginPage struct {
+	Notice string // flash message left by the page that redirected here
+	Error  string
+}
+
+// MFASetupPage is the data rendered into mfa_setup.html.
+type MFASetupPage struct {
+	Enabled bool
+	Secret  string
+	URI     string
+	QRCode  template.URL // PNG of the provisioning URI as a data: URL
+	Error   string
+
+	RemainingCodes int // unused recovery codes, shown once TOTP is enabled
+}
+
+// MFAVerifyPage is the data rendered into mfa_verify.html.
+type MFAVerifyPage struct {
+	Error string
+}
+
+// RecoveryCodesPage is the data rendered into recovery_codes.html. The codes
+// are only stored hashed, so this is the one time they are shown.
+type RecoveryCodesPage struct {
+	Codes []string
+}
+
+// ForgotPasswordPage is the data rendered into forgot_password.html.
+type ForgotPasswordPage struct {
+	Sent  bool // true once a reset was requested, whether or not the user exists
+	Error string
+}
+
+// ResetPasswordPage is the data rendered into reset_password.html.
+type ResetPasswordPage struct {
+	Token  string
+	Error  string
+	Errors validation.Errors // password rules the new password breaks
+}
+
+// ChangePasswordPage is the data rendered into change_password.html.
+type ChangePasswordPage struct {
+	Error  string
+	Errors validation.Errors // password rules the new password breaks
+}
+
+// VerifyEmailPage is the data rendered into verify_email.html.
+type VerifyEmailPage struct {
+	Username string // account to resend the link for when not logged in
+	Email    string
+	Sent     bool // a new link was just emailed
+	Verified bool // the address was just verified
+	Error    string
+}
+
+// MagicLinkPage is the data rendered into magic_link.html.
+type MagicLinkPage struct {
+	Sent    bool // true once a link was requested, whether or not the address is registered
+	Minutes int  // how long the link works
+	Error   string
+}
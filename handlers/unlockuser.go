@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+// UnlockUser clears the failed logins of a user from the admin users page,
+// lifting any back-off delay or lockout straight away. It is wrapped in
+// RequirePermission("admin:users") in StartHTTPServer.
+func (s *Server) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to admin users page...", r.Method))
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		logs.Logs(logWarning, "Request has no logged in user. Redirecting back to login page...")
+		middleware.RedirectToLogin(w, r)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	username := validation.CanonicalUsername(r.FormValue("username"))
+	if username == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		s.renderAdminUsers(w, r, AdminUsersPage{Error: "Enter the username to unlock."})
+		return
+	}
+
+	// failures are counted for any username, so there is nothing to look up
+	err = s.store.ResetLoginFailures(username)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to unlock user %s: %s", username, err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("User %s unlocked user %s", user.Username, username))
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+// UpdateRole grants or revokes a role from the admin users page. It is
+// wrapped in RequirePermission("admin:users") in StartHTTPServer.
+func (s *Server) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to admin users page...", r.Method))
+		http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+		return
+	}
+
+	user, ok := middleware.CurrentUser(r)
+	if !ok {
+		logs.Logs(logWarning, "Request has no logged in user. Redirecting back to login page...")
+		middleware.RedirectToLogin(w, r)
+		return
+	}
+
+	// parse form data
+	err := r.ParseForm()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to parse form data: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	username := validation.CanonicalUsername(r.FormValue("username"))
+	role := r.FormValue("role")
+
+	switch r.FormValue("action") {
+	case "grant":
+		err = s.store.AssignRole(username, role)
+	case "revoke":
+		// keep admins from locking everyone out by mistake
+		if username == user.Username && role == "admin" {
+			w.WriteHeader(http.StatusBadRequest)
+			s.renderAdminUsers(w, r, AdminUsersPage{Error: "You cannot revoke your own admin role."})
+			return
+		}
+		err = s.store.RevokeRole(username, role)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		s.renderAdminUsers(w, r, AdminUsersPage{Error: "Unknown action."})
+		return
+	}
+	if err == db.ErrUserNotFound || err == db.ErrRoleNotFound {
+		w.WriteHeader(http.StatusBadRequest)
+		s.renderAdminUsers(w, r, AdminUsersPage{Error: fmt.Sprintf("Could not update role %q of %q: %s.", role, username, err.Error())})
+		return
+	}
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to update role: %s", err.Error()))
+		http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("User %s did %s role %s for user %s", user.Username, r.FormValue("action"), role, username))
+	http.Redirect(w, r, "/admin/users", http.StatusSeeOther)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func TestUpdateRole(t *testing.T) {
+	s := NewServer(db.NewMemoryStore())
+	for _, err := range []error{
+		s.store.CreateUser("alice", "", "Plum-Kettle-93"),
+		s.store.CreateUser("bob", "", "Plum-Kettle-93"),
+		s.store.AssignRole("alice", "admin"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	session, err := s.store.CreateSession("alice", "192.0.2.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := middleware.RequirePermission(s.store, "admin:users")(s.UpdateRole)
+	update := func(action, username string) int {
+		t.Helper()
+		cookies := []*http.Cookie{{Name: middleware.Cookies.Name(middleware.SessionCookie), Value: session.Token}}
+		form := url.Values{"action": {action}, "username": {username}, "role": {"admin"}}
+		return postForm(handler, "/admin/update-role", form, &cookies).Code
+	}
+	isAdmin := func(username string) bool {
+		t.Helper()
+		roles, err := s.store.GetRoles(username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return slices.Contains(roles, "admin")
+	}
+
+	// an admin cannot lock themselves out
+	if code := update("revoke", "alice"); code != http.StatusBadRequest {
+		t.Fatalf("revoking your own admin role: got %d, want 400", code)
+	}
+	if !isAdmin("alice") {
+		t.Fatal("alice lost the admin role")
+	}
+
+	if code := update("grant", "bob"); code != http.StatusSeeOther || !isAdmin("bob") {
+		t.Fatalf("granting admin to bob: got %d, admin %t, want 303 and admin", code, isAdmin("bob"))
+	}
+	if code := update("revoke", "bob"); code != http.StatusSeeOther || isAdmin("bob") {
+		t.Fatalf("revoking admin from bob: got %d, admin %t, want 303 and not admin", code, isAdmin("bob"))
+	}
+	if code := update("promote", "bob"); code != http.StatusBadRequest {
+		t.Fatalf("unknown action: got %d, want 400", code)
+	}
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+func (s *Server) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		logs.Logs(logWarning, fmt.Sprintf("Invalid request method: %s. Redirecting back to index page...", r.Method))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	verification, err := s.store.ConsumeEmailVerification(r.URL.Query().Get("token"))
+	if err != nil {
+		logs.Logs(logWarning, fmt.Sprintf("Failed to verify email: %s", err.Error()))
+		w.WriteHeader(http.StatusBadRequest)
+		renderVerifyEmail(w, r, VerifyEmailPage{Error: "That verification link is invalid or has expired. Log in to request a new one."})
+		return
+	}
+
+	logs.Logs(logInfo, fmt.Sprintf("Email address of user %s verified", verification.Username))
+	renderVerifyEmail(w, r, VerifyEmailPage{Email: verification.Email, Verified: true})
+}
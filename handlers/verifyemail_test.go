@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func TestUnverifiedLoginPolicy(t *testing.T) {
+	tests := []struct {
+		policy    string
+		loginCode int  // answer to a login before verifying
+		restrict  bool // the dashboard only offers to verify
+	}{
+		{verificationOff, http.StatusSeeOther, false},
+		{verificationRestrict, http.StatusSeeOther, true},
+		{verificationBlock, http.StatusForbidden, true}, // for sessions from before the policy
+	}
+	for _, test := range tests {
+		t.Run(test.policy, func(t *testing.T) {
+			s, _ := newMailServer(t, test.policy)
+			if err := s.store.CreateUser("alice", "alice@example.com", "Plum-Kettle-93"); err != nil {
+				t.Fatal(err)
+			}
+			login := func() int {
+				t.Helper()
+				var cookies []*http.Cookie
+				return postForm(s.SubmitLogin, "/submit-login", url.Values{"username": {"alice"}, "password": {"Plum-Kettle-93"}}, &cookies).Code
+			}
+			dashboard := func() string {
+				t.Helper()
+				r := httptest.NewRequest(http.MethodGet, "/dashboard", nil)
+				r = r.WithContext(middleware.WithPrincipal(r.Context(), middleware.Principal{Username: "alice"}))
+				w := httptest.NewRecorder()
+				s.Dashboard(w, r)
+				return w.Body.String()
+			}
+
+			if code := login(); code != test.loginCode {
+				t.Fatalf("login before verifying: got %d, want %d", code, test.loginCode)
+			}
+			if restricted := strings.Contains(dashboard(), "Verify Email Address"); restricted != test.restrict {
+				t.Fatalf("dashboard before verifying shows the verify page: %t, want %t", restricted, test.restrict)
+			}
+
+			// following the emailed link lifts every restriction
+			verification, err := s.store.CreateEmailVerification("alice")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/verify-email?token="+url.QueryEscape(verification.Token), nil)
+			w := httptest.NewRecorder()
+			s.VerifyEmail(w, r)
+			if w.Code != http.StatusOK {
+				t.Fatalf("verify link: got %d", w.Code)
+			}
+			if code := login(); code != http.StatusSeeOther {
+				t.Fatalf("login after verifying: got %d, want %d", code, http.StatusSeeOther)
+			}
+			if !strings.Contains(dashboard(), "User Dashboard") {
+				t.Fatal("dashboard after verifying is not shown")
+			}
+
+			// the link works once
+			w = httptest.NewRecorder()
+			s.VerifyEmail(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("reused verify link: got %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
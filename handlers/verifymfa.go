@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+)
+
+func VerifyMFA(w http.ResponseWriter, r *http.Request) {
+	// only logins that passed the password check reach this page
+	if _, ok := middleware.Cookies.Get(r, mfaChallengeCookie); !ok {
+		logs.Logs(logWarning, "MFA challenge cookie is missing. Redirecting back to login page...")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	renderMFAVerify(w, r, MFAVerifyPage{})
+}
+
+func renderMFAVerify(w http.ResponseWriter, r *http.Request, page MFAVerifyPage) {
+	renderTemplate(w, r, "mfa_verify.html", page)
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/middleware"
+	"github.com/Bevs-n-Devs/WebAuthentication/webauthn"
+)
+
+const (
+	webauthnCeremonyCookie = "webauthn_ceremony" // holds the token of the passkey ceremony in progress
+	maxWebAuthnBody        = 64 << 10            // largest JSON body accepted from the browser
+)
+
+// jsonError is the body of a failed JSON response.
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// newWebAuthnConfig reads the relying party settings from the environment,
+// defaulting to the local development server.
+func newWebAuthnConfig() webauthn.Config {
+	config := webauthn.Config{
+		RPID:   os.Getenv("WEBAUTHN_RP_ID"),
+		RPName: os.Getenv("WEBAUTHN_RP_NAME"),
+		Origin: os.Getenv("WEBAUTHN_ORIGIN"),
+	}
+	if config.RPID == "" {
+		config.RPID = "localhost"
+	}
+	if config.RPName == "" {
+		config.RPName = "WebAuthentication"
+	}
+	if config.Origin == "" {
+		httpPort := os.Getenv("PORT")
+		if httpPort == "" {
+			httpPort = "9003"
+		}
+		config.Origin = fmt.Sprintf("http://localhost:%s", httpPort)
+	}
+	return config
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(body)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to write JSON response: %s", err.Error()))
+	}
+}
+
+func setCeremonyCookie(w http.ResponseWriter, ceremony db.WebAuthnCeremony) {
+	middleware.Cookies.Set(w, webauthnCeremonyCookie, ceremony.Token, ceremony.ExpiresAt)
+}
+
+// consumeCeremony takes the ceremony named by the request's cookie, which
+// can only be used once, and checks it is of the expected kind.
+func (s *Server) consumeCeremony(w http.ResponseWriter, r *http.Request, kind string) (db.WebAuthnCeremony, error) {
+	token, ok := middleware.Cookies.Get(r, webauthnCeremonyCookie)
+	if !ok {
+		return db.WebAuthnCeremony{}, db.ErrCeremonyNotFound
+	}
+	middleware.Cookies.Clear(w, webauthnCeremonyCookie)
+
+	ceremony, err := s.store.ConsumeWebAuthnCeremony(token)
+	if err != nil {
+		return db.WebAuthnCeremony{}, err
+	}
+	if ceremony.Kind != kind {
+		return db.WebAuthnCeremony{}, db.ErrCeremonyNotFound
+	}
+	return ceremony, nil
+}
+
+// toWebAuthnCredentials converts stored credentials to the form the
+// webauthn package verifies against.
+func toWebAuthnCredentials(stored []db.WebAuthnCredential) []webauthn.Credential {
+	creds := make([]webauthn.Credential, 0, len(stored))
+	for _, cred := range stored {
+		converted, err := toWebAuthnCredential(cred)
+		if err != nil {
+			continue
+		}
+		creds = append(creds, converted)
+	}
+	return creds
+}
+
+// toWebAuthnCredential converts a stored credential for verification,
+// failing if its ID is not valid base64url.
+func toWebAuthnCredential(stored db.WebAuthnCredential) (webauthn.Credential, error) {
+	id, err := base64.RawURLEncoding.DecodeString(stored.ID)
+	if err != nil {
+		return webauthn.Credential{}, err
+	}
+	return webauthn.Credential{
+		ID:         id,
+		PublicKey:  stored.PublicKey,
+		SignCount:  stored.SignCount,
+		Transports: stored.Transports,
+		UserHandle: stored.UserHandle,
+	}, nil
+}
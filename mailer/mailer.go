@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+const (
+	logInfo    = 1
+	logWarning = 2
+	logErr     = 3
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+/*
+Mailer sends emails to users. SMTPMailer delivers them for real; Outbox and
+FileOutbox keep them locally so flows such as password resets can be tried
+without a mail server.
+*/
+type Mailer interface {
+	Send(msg Message) error
+}
+
+/*
+FromEnv picks a Mailer from the environment:
+
+- SMTP_HOST set: an SMTPMailer using SMTP_PORT (default 587), SMTP_USERNAME,
+SMTP_PASSWORD and MAIL_FROM.
+
+- MAIL_OUTBOX_DIR set: a FileOutbox writing one file per message there.
+
+- otherwise: an in-memory Outbox that also logs each message.
+
+Returns:
+
+- Mailer: The configured mailer.
+*/
+func FromEnv() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		logs.Logs(logInfo, fmt.Sprintf("Sending email through SMTP server %s:%s", host, port))
+		return &SMTPMailer{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	}
+
+	if dir := os.Getenv("MAIL_OUTBOX_DIR"); dir != "" {
+		logs.Logs(logInfo, fmt.Sprintf("Writing email to outbox directory %s", dir))
+		return &FileOutbox{Dir: dir, From: from}
+	}
+
+	logs.Logs(logWarning, "SMTP_HOST and MAIL_OUTBOX_DIR are not set. Email will only be logged...")
+	return &Outbox{LogMessages: true}
+}
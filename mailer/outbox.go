@@ -0,0 +1,81 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+Outbox keeps sent messages in memory instead of delivering them, for local
+development and tests. With LogMessages set, each message is also written to
+the log so links in it can be followed.
+*/
+type Outbox struct {
+	LogMessages bool
+
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send stores msg in the outbox.
+func (o *Outbox) Send(msg Message) error {
+	o.mu.Lock()
+	o.messages = append(o.messages, msg)
+	o.mu.Unlock()
+
+	if o.LogMessages {
+		logs.Logs(logInfo, fmt.Sprintf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body))
+	}
+	return nil
+}
+
+// Messages returns a copy of every message sent so far, oldest first.
+func (o *Outbox) Messages() []Message {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return append([]Message(nil), o.messages...)
+}
+
+/*
+FileOutbox writes each message to its own .eml file in Dir instead of
+delivering it. The files can be opened with any mail client.
+*/
+type FileOutbox struct {
+	Dir  string
+	From string
+}
+
+/*
+Send writes msg to a new file in the outbox directory, creating the directory
+if needed.
+
+Returns:
+
+- error: An error if the file cannot be written.
+*/
+func (o *FileOutbox) Send(msg Message) error {
+	data, err := formatMessage(o.From, msg)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(o.Dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	// messages may contain login links, so keep them private to the server user
+	name := fmt.Sprintf("%s.eml", time.Now().UTC().Format("20060102T150405.000000000Z"))
+	err = os.WriteFile(filepath.Join(o.Dir, name), data, 0o600)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Failed to write email to outbox: %s", err.Error()))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+var ErrInvalidHeader = errors.New("mailer: header contains a line break")
+
+/*
+SMTPMailer delivers messages through an SMTP server. The connection is
+upgraded with STARTTLS when the server offers it, and credentials are only
+sent over TLS (or to localhost), as enforced by net/smtp.
+*/
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+/*
+Send delivers msg to msg.To.
+
+Returns:
+
+- error: ErrInvalidHeader if a header would break the message, or an error if
+the server rejects it.
+*/
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := formatMessage(m.From, msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+	return smtp.SendMail(net.JoinHostPort(m.Host, m.Port), auth, m.From, []string{msg.To}, data)
+}
+
+// formatMessage renders msg as an RFC 5322 message with CRLF line endings.
+func formatMessage(from string, msg Message) ([]byte, error) {
+	// a line break in a header would let the caller inject extra headers
+	for _, value := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(value, "\r\n") {
+			return nil, ErrInvalidHeader
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String()), nil
+}
@@ -1,28 +1,121 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 	"strings"
 
 	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/env"
 	"github.com/Bevs-n-Devs/WebAuthentication/handlers"
 	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
 )
 
 const (
-	logInfo  = 1
-	logDbErr = 5
+	logInfo    = 1
+	logWarning = 2
+	logErr     = 3
+	logDbErr   = 5
 )
 
 func main() {
+	migrateCmd := flag.String("migrate", "", "run schema migrations and exit: up, down or version")
+	migrateSteps := flag.Int("steps", 1, "number of migrations to revert with -migrate=down")
+	unlockUser := flag.String("unlock", "", "clear the failed logins of a locked out user and exit")
+	grantRole := flag.String("grant-role", "", "grant a role to a user and exit, given as username:role")
+	revokeRole := flag.String("revoke-role", "", "revoke a role from a user and exit, given as username:role")
+	flag.Parse()
+
 	go logs.ProcessLogs()
-	err := db.ConnectDB()
+
+	// every setting below may come from the .env file, so load it first
+	err := loadEnvironment("env/.env")
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// refuse to start with password hashing settings that would fail later
+	err = utils.LoadPasswordHasher()
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Invalid password hashing settings: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	store, err := db.ConnectDB()
 	if err != nil {
 		logs.Logs(logDbErr, fmt.Sprintf("Failed to initialize database: %s", err.Error()))
+		os.Exit(1)
+	}
+
+	// tokens stored in a database must hash the same way after a restart
+	if _, persistent := store.(db.Migrator); persistent {
+		err = utils.CheckTokenSecret()
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Cannot store tokens in the database: %s", err.Error()))
+			store.Close()
+			os.Exit(1)
+		}
+	}
+
+	if *migrateCmd != "" {
+		err = runMigrations(store, *migrateCmd, *migrateSteps)
+		store.Close()
+		if err != nil {
+			logs.Logs(logDbErr, fmt.Sprintf("Migration failed: %s", err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
+
+	// bring the schema up to date before serving unless disabled
+	migrator, ok := store.(db.Migrator)
+	if ok && os.Getenv("MIGRATE_ON_START") != "false" {
+		err = migrator.MigrateUp()
+		if err != nil {
+			logs.Logs(logDbErr, fmt.Sprintf("Failed to migrate database: %s", err.Error()))
+			os.Exit(1)
+		}
+	}
+
+	if *unlockUser != "" {
+		*unlockUser = validation.CanonicalUsername(*unlockUser)
+		err = store.ResetLoginFailures(*unlockUser)
+		store.Close()
+		if err != nil {
+			logs.Logs(logDbErr, fmt.Sprintf("Failed to unlock user %s: %s", *unlockUser, err.Error()))
+			os.Exit(1)
+		}
+		logs.Logs(logInfo, fmt.Sprintf("User %s unlocked", *unlockUser))
+		return
+	}
+
+	if *grantRole != "" || *revokeRole != "" {
+		// in stateless mode revoking a role also ends the user's sessions
+		err = updateRole(handlers.NewSessionStore(store), *grantRole, *revokeRole)
+		store.Close()
+		if err != nil {
+			logs.Logs(logDbErr, fmt.Sprintf("Failed to update role: %s", err.Error()))
+			os.Exit(1)
+		}
+		return
+	}
+
+	// a hash whose pepper is not configured can never be verified again
+	keyIDs, err := store.ListPepperKeyIDs()
+	if err == nil {
+		err = utils.CheckPepperKeyIDs(keyIDs)
+	}
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Cannot verify stored passwords: %s", err.Error()))
+		store.Close()
+		os.Exit(1)
 	}
 
 	go func() {
-		handlers.StartHTTPServer()
+		handlers.StartHTTPServer(store)
 
 		var templateNames []string
 		for _, tmpl := range handlers.Templates.Templates() {
@@ -34,3 +127,72 @@ func main() {
 
 	select {}
 }
+
+// loadEnvironment loads the environment variables from the .env file at
+// filename when the hosting platform does not provide DATABASE_URL.
+func loadEnvironment(filename string) error {
+	if os.Getenv("DATABASE_URL") != "" {
+		return nil
+	}
+	logs.Logs(logWarning, "Could not get database URL from hosting platform. Loading from .env file...")
+	err := env.LoadEnv(filename)
+	if err != nil {
+		logs.Logs(logErr, fmt.Sprintf("Could not load environment variables from .env file: %s", err.Error()))
+		return err
+	}
+	return nil
+}
+
+// runMigrations handles the -migrate command line flag.
+func runMigrations(store db.Store, command string, steps int) error {
+	migrator, ok := store.(db.Migrator)
+	if !ok {
+		logs.Logs(logErr, "The configured store has no schema to migrate")
+		return nil
+	}
+
+	switch command {
+	case "up":
+		return migrator.MigrateUp()
+	case "down":
+		return migrator.MigrateDown(steps)
+	case "version":
+		version, err := migrator.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		logs.Logs(logInfo, fmt.Sprintf("Database schema version: %d", version))
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or version", command)
+	}
+}
+
+// updateRole handles the -grant-role and -revoke-role command line flags, so
+// the first admin can be created before anyone can use the admin pages.
+func updateRole(store db.Store, grant, revoke string) error {
+	assignment, revoking := grant, false
+	if revoke != "" {
+		assignment, revoking = revoke, true
+	}
+	username, role, ok := strings.Cut(assignment, ":")
+	if !ok || username == "" || role == "" {
+		return fmt.Errorf("invalid role assignment %q, expected username:role", assignment)
+	}
+	username = validation.CanonicalUsername(username)
+
+	if revoking {
+		err := store.RevokeRole(username, role)
+		if err != nil {
+			return err
+		}
+		logs.Logs(logInfo, fmt.Sprintf("Role %s revoked from user %s", role, username))
+		return nil
+	}
+	err := store.AssignRole(username, role)
+	if err != nil {
+		return err
+	}
+	logs.Logs(logInfo, fmt.Sprintf("Role %s granted to user %s", role, username))
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func TestMain(m *testing.M) {
+	go logs.ProcessLogs()
+	os.Exit(m.Run())
+}
+
+func TestEnvFileConfiguresHasher(t *testing.T) {
+	// registering every setting restores it once the test ends
+	for _, name := range []string{"DATABASE_URL", "PASSWORD_HASH_ALGORITHM", "BCRYPT_COST", "PASSWORD_PEPPERS", "PASSWORD_PEPPER_ID"} {
+		t.Setenv(name, "")
+	}
+	filename := filepath.Join(t.TempDir(), ".env")
+	err := os.WriteFile(filename, []byte("# test settings\nDATABASE_URL=memory://\nPASSWORD_HASH_ALGORITHM=bcrypt\nBCRYPT_COST=5\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loadEnvironment(filename); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := utils.HashedPassword("Plum-Kettle-93")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(hash, "$2a$05$") {
+		t.Fatalf("hash %s is not bcrypt with cost 5 as set in the .env file", hash)
+	}
+}
+
+func TestEnvFileSkippedWithDatabaseURL(t *testing.T) {
+	t.Setenv("DATABASE_URL", "memory://")
+	if err := loadEnvironment(filepath.Join(t.TempDir(), "missing.env")); err != nil {
+		t.Fatalf("got error %v, want the .env file to be skipped", err)
+	}
+}
@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// TrustedProxies lists the reverse proxies whose X-Forwarded-For headers are
+// believed. It is empty by default, so the header is ignored.
+var TrustedProxies []*net.IPNet
+
+/*
+ParseTrustedProxies parses a comma separated list of IP addresses and CIDR
+ranges, such as "10.0.0.0/8, 192.168.1.10", for use as TrustedProxies.
+
+Returns:
+
+- []*net.IPNet: The parsed ranges; single addresses become /32 or /128.
+
+- error: An error naming the first entry that is not an address or range.
+*/
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(list, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+/*
+ClientIP returns the IP address of the client that sent the request, without
+the port. When the request comes from one of the TrustedProxies, the
+X-Forwarded-For chain is walked from the right, skipping trusted proxies, and
+the first address they did not add themselves is returned. Entries left of
+that are ignored, as the client can write anything there.
+*/
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(host) {
+		return host
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// a malformed entry ends the chain we can trust
+			break
+		}
+		host = hop
+		if !isTrustedProxy(hop) {
+			break
+		}
+	}
+	return host
+}
+
+// isTrustedProxy reports whether address is in one of the TrustedProxies.
+func isTrustedProxy(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range TrustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10, 2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := TrustedProxies
+	TrustedProxies = proxies
+	t.Cleanup(func() { TrustedProxies = saved })
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct client", "203.0.113.7:4000", nil, "203.0.113.7"},
+		{"untrusted peer with forged header", "203.0.113.7:4000", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"trusted proxy", "10.0.0.1:4000", []string{"203.0.113.7"}, "203.0.113.7"},
+		{"trusted proxy without header", "10.0.0.1:4000", nil, "10.0.0.1"},
+		{"several trusted hops", "10.0.0.1:4000", []string{"203.0.113.7, 192.168.1.10, 10.0.0.2"}, "203.0.113.7"},
+		{"hops split over headers", "10.0.0.1:4000", []string{"203.0.113.7", "10.0.0.2"}, "203.0.113.7"},
+		// the client may prepend anything, only what proxies appended counts
+		{"forged entries left of the client", "10.0.0.1:4000", []string{"198.51.100.1, 203.0.113.7"}, "203.0.113.7"},
+		{"forged trusted address left of the client", "10.0.0.1:4000", []string{"10.0.0.9, 203.0.113.7"}, "203.0.113.7"},
+		{"malformed entry ends the chain", "10.0.0.1:4000", []string{"203.0.113.7, garbage, 10.0.0.2"}, "10.0.0.2"},
+		{"only trusted hops", "10.0.0.1:4000", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"IPv6 proxy", "[2001:db8::1]:4000", []string{"2001:db9::7"}, "2001:db9::7"},
+		{"address without port", "203.0.113.7", nil, "203.0.113.7"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = test.remote
+			for _, value := range test.xff {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if got := ClientIP(r); got != test.want {
+				t.Fatalf("ClientIP = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	for _, list := range []string{"10.0.0.300", "10.0.0.0/33", "proxy.local"} {
+		if _, err := ParseTrustedProxies(list); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded", list)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+// Names of the cookies shared by the middleware and the handlers, before
+// CookieManager adds its prefix.
+const (
+	SessionCookie = "session_token" // the session of a logged in user
+	CSRFCookie    = "csrf_token"    // the CSRF token issued with the session, for pages to embed
+)
+
+/*
+CookieManager sets, reads and clears every cookie the server uses, so they
+all get the same attributes. Cookies are always HttpOnly and scoped to the
+whole site with Path=/.
+
+With Secure set, cookies are only sent over HTTPS and their names get a
+prefix browsers enforce: __Host- for cookies kept to this host, so a
+subdomain cannot set or overwrite them, or __Secure- when Domain shares them
+with subdomains.
+*/
+type CookieManager struct {
+	Secure   bool
+	SameSite http.SameSite      // http.SameSiteLaxMode or http.SameSiteStrictMode
+	Domain   string             // empty keeps cookies to the host that set them
+	Codec    *utils.CookieCodec // seals the values of SetSealed
+}
+
+// Cookies is the cookie manager used by the server, set by StartHTTPServer.
+// Until then it suits plain HTTP development servers but has no Codec.
+var Cookies = CookieManager{SameSite: http.SameSiteLaxMode}
+
+// Name returns the name the cookie is stored under, with the prefix its
+// attributes allow.
+func (m CookieManager) Name(name string) string {
+	switch {
+	case !m.Secure:
+		return name
+	case m.Domain == "":
+		return "__Host-" + name
+	default:
+		return "__Secure-" + name
+	}
+}
+
+/*
+Set hands the browser a cookie with the manager's attributes. A zero expires
+makes it a browser session cookie.
+*/
+func (m CookieManager) Set(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, m.cookie(name, value, expires, m.SameSite))
+}
+
+/*
+SetForLinks is Set for cookies that must be sent when the user follows a
+link from an email or another site, such as the one binding a magic link to
+the browser that asked for it. These are always SameSite=Lax, since Strict
+cookies are left out of such requests.
+*/
+func (m CookieManager) SetForLinks(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, m.cookie(name, value, expires, http.SameSiteLaxMode))
+}
+
+// Get returns the value of the cookie, and false if the request has none or
+// it is empty.
+func (m CookieManager) Get(r *http.Request, name string) (string, bool) {
+	cookie, err := r.Cookie(m.Name(name))
+	if err != nil || cookie.Value == "" {
+		return "", false
+	}
+	return cookie.Value, true
+}
+
+/*
+SetSealed is Set for a small structured value, such as a flash message,
+sealed with the manager's Codec so the browser can neither read nor change
+it. The expiry is sealed in as well and enforced by GetSealed.
+
+Returns:
+
+- error: An error if the value cannot be sealed.
+*/
+func (m CookieManager) SetSealed(w http.ResponseWriter, name string, value interface{}, expires time.Time) error {
+	encoded, err := m.Codec.Encode(m.Name(name), value, expires)
+	if err != nil {
+		return err
+	}
+	m.Set(w, name, encoded, expires)
+	return nil
+}
+
+/*
+GetSealed opens a value stored with SetSealed into value.
+
+Returns:
+
+- error: http.ErrNoCookie if the request has no such cookie, one of the
+utils.ErrCookie errors if it was tampered with or has expired, or an error if
+the value does not fit.
+*/
+func (m CookieManager) GetSealed(r *http.Request, name string, value interface{}) error {
+	encoded, ok := m.Get(r, name)
+	if !ok {
+		return http.ErrNoCookie
+	}
+	return m.Codec.Decode(m.Name(name), encoded, value)
+}
+
+// Clear expires the cookie on the client.
+func (m CookieManager) Clear(w http.ResponseWriter, name string) {
+	cookie := m.cookie(name, "", time.Unix(0, 0), m.SameSite)
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+}
+
+// cookie builds a cookie with the manager's attributes.
+func (m CookieManager) cookie(name, value string, expires time.Time, sameSite http.SameSite) *http.Cookie {
+	return &http.Cookie{
+		Name:     m.Name(name),
+		Value:    value,
+		Path:     "/",
+		Domain:   strings.TrimPrefix(m.Domain, "."),
+		Expires:  expires,
+		Secure:   m.Secure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}
+}
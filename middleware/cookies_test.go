@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCookieManagerName(t *testing.T) {
+	tests := []struct {
+		manager CookieManager
+		want    string
+	}{
+		{CookieManager{}, "session_token"},
+		{CookieManager{Secure: true}, "__Host-session_token"},
+		{CookieManager{Secure: true, Domain: "example.com"}, "__Secure-session_token"},
+		// browsers refuse prefixed cookies without Secure
+		{CookieManager{Domain: "example.com"}, "session_token"},
+	}
+	for _, test := range tests {
+		if got := test.manager.Name(SessionCookie); got != test.want {
+			t.Errorf("Name with %+v = %q, want %q", test.manager, got, test.want)
+		}
+	}
+}
+
+func TestCookieManagerSet(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	tests := []struct {
+		name       string
+		manager    CookieManager
+		wantDomain string
+	}{
+		{"host", CookieManager{Secure: true, SameSite: http.SameSiteStrictMode}, ""},
+		{"domain", CookieManager{Secure: true, SameSite: http.SameSiteStrictMode, Domain: ".example.com"}, "example.com"},
+		{"plain HTTP", CookieManager{SameSite: http.SameSiteLaxMode}, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			test.manager.Set(w, SessionCookie, "value", expires)
+			test.manager.SetForLinks(w, "magic_link", "value", expires)
+			cookies := w.Result().Cookies()
+			if len(cookies) != 2 {
+				t.Fatalf("set %d cookies, want 2", len(cookies))
+			}
+
+			for i, cookie := range cookies {
+				if cookie.Path != "/" || !cookie.HttpOnly || cookie.Secure != test.manager.Secure ||
+					cookie.Domain != test.wantDomain || !cookie.Expires.Equal(expires) {
+					t.Errorf("cookie %d = %+v, want HttpOnly, Path=/, Secure %t, Domain %q", i, cookie, test.manager.Secure, test.wantDomain)
+				}
+			}
+			if cookies[0].Name != test.manager.Name(SessionCookie) || cookies[0].SameSite != test.manager.SameSite {
+				t.Errorf("Set made %s with SameSite %v, want %s with %v", cookies[0].Name, cookies[0].SameSite,
+					test.manager.Name(SessionCookie), test.manager.SameSite)
+			}
+			// cookies for links stay Lax, so following a link sends them
+			if cookies[1].Name != test.manager.Name("magic_link") || cookies[1].SameSite != http.SameSiteLaxMode {
+				t.Errorf("SetForLinks made %s with SameSite %v, want %s with Lax", cookies[1].Name, cookies[1].SameSite,
+					test.manager.Name("magic_link"))
+			}
+		})
+	}
+}
+
+func TestCookieManagerClear(t *testing.T) {
+	manager := CookieManager{Secure: true, SameSite: http.SameSiteStrictMode}
+	w := httptest.NewRecorder()
+	manager.Clear(w, SessionCookie)
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("Clear set %d cookies, want 1", len(cookies))
+	}
+	cookie := cookies[0]
+	// only a cookie with the same name, path and prefix attributes replaces it
+	if cookie.Name != "__Host-session_token" || cookie.Value != "" || cookie.MaxAge >= 0 ||
+		cookie.Path != "/" || !cookie.Secure || !cookie.HttpOnly {
+		t.Fatalf("Clear set %+v, want an expired __Host-session_token", cookie)
+	}
+}
+
+func TestCookieManagerGet(t *testing.T) {
+	manager := CookieManager{Secure: true}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session_token", Value: "unprefixed"})
+	r.AddCookie(&http.Cookie{Name: "__Host-csrf_token", Value: ""})
+	r.AddCookie(&http.Cookie{Name: "__Host-magic_link", Value: "value"})
+
+	// a cookie without the prefix could have been set by a subdomain
+	if value, ok := manager.Get(r, SessionCookie); ok {
+		t.Errorf("Get read the unprefixed cookie %q", value)
+	}
+	if _, ok := manager.Get(r, CSRFCookie); ok {
+		t.Error("Get of an empty cookie reported it present")
+	}
+	if value, ok := manager.Get(r, "magic_link"); !ok || value != "value" {
+		t.Errorf("Get = %q, %t, want value, true", value, ok)
+	}
+}
@@ -0,0 +1,180 @@
+package middleware
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+const (
+	CSRFField  = "csrf_token"   // form field carrying the token
+	CSRFHeader = "X-CSRF-Token" // header carrying the token on script requests
+
+	// preSessionCookie holds a random value visitors without a session get, so
+	// the login and signup forms can be protected before a session exists
+	preSessionCookie = "pre_session"
+)
+
+var ErrCSRF = errors.New("forbidden - invalid CSRF token")
+
+// csrfKey is the context key of the token pages embed, unexported so only
+// this package can set it.
+type csrfKey struct{}
+
+/*
+CSRFProtect wraps the whole server with synchronizer token CSRF protection.
+Every POST, PUT, PATCH or DELETE must send the token in the csrf_token form
+field or the X-CSRF-Token header; the cookies a cross-site request carries
+along are never enough.
+
+For logged in users the token is the CSRF token issued with their session and
+is checked against the store. Visitors without a session get a random
+pre_session cookie and a token derived from it with the server secret, so
+login and signup forms cannot be submitted from another site either.
+
+Pages find the token to embed with CSRFToken. As the session is looked up
+here anyway, the Principal of a logged in user is put in the context too, so
+RequireAuth does not look it up again. Static assets skip all of this.
+*/
+func CSRFProtect(store db.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasPrefix(r.URL.Path, "/static/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			session, hasSession := requestSession(store, r)
+
+			var token string
+			if hasSession {
+				principal, err := newPrincipal(store, session)
+				if err != nil {
+					// RequireAuth tries again and reports the error on pages that need it
+					logs.Logs(logErr, fmt.Sprintf("Failed to get roles: %s", err.Error()))
+				} else {
+					ctx = WithPrincipal(ctx, principal)
+				}
+				token, err = sessionCSRFToken(w, r, store, session)
+				if err != nil {
+					logs.Logs(logErr, fmt.Sprintf("Failed to issue CSRF token: %s", err.Error()))
+					http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+					return
+				}
+			} else {
+				token = preSessionCSRFToken(w, r)
+			}
+
+			if !isSafeMethod(r.Method) {
+				submitted := r.Header.Get(CSRFHeader)
+				if submitted == "" {
+					submitted = r.PostFormValue(CSRFField)
+				}
+
+				var ok bool
+				var err error
+				switch {
+				case submitted == "":
+				case hasSession:
+					ok, err = store.ValidateCSRFToken(session.ID, submitted)
+				default:
+					ok = token != "" && subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) == 1
+				}
+				if err != nil {
+					logs.Logs(logErr, fmt.Sprintf("Failed to validate CSRF token: %s", err.Error()))
+					http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+					return
+				}
+				if !ok {
+					// the CSRF cookie itself is stale, so every form would fail
+					// until the user logs in again: issue a fresh token for the
+					// next page. Only the real cookie value can trigger this,
+					// which a cross-site request never knows.
+					if hasSession && submitted == token {
+						reissueCSRFToken(w, store, session)
+					}
+					logs.Logs(logWarning, fmt.Sprintf("%s! %s %s refused", ErrCSRF, r.Method, r.URL.Path))
+					http.Error(w, "Forbidden - invalid CSRF token", http.StatusForbidden)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, csrfKey{}, token)))
+		})
+	}
+}
+
+// CSRFToken returns the token pages must send back with their forms, or an
+// empty string for requests that did not go through CSRFProtect.
+func CSRFToken(r *http.Request) string {
+	token, _ := r.Context().Value(csrfKey{}).(string)
+	return token
+}
+
+// isSafeMethod reports whether the method only reads, so needs no token.
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// requestSession returns the session of the session_token cookie, and false
+// if there is no cookie or the session has ended.
+func requestSession(store db.Store, r *http.Request) (db.Session, bool) {
+	token, ok := Cookies.Get(r, SessionCookie)
+	if !ok {
+		return db.Session{}, false
+	}
+	session, err := store.GetSession(token)
+	if err != nil {
+		return db.Session{}, false
+	}
+	return session, true
+}
+
+// sessionCSRFToken returns the CSRF token issued with the session. Only its
+// hash is stored, so it is read back from the CSRF cookie; it is checked
+// against the store when a form sends it back, not on every page. Clients
+// that lost the cookie are issued a new token.
+func sessionCSRFToken(w http.ResponseWriter, r *http.Request, store db.Store, session db.Session) (string, error) {
+	token, ok := Cookies.Get(r, CSRFCookie)
+	if ok {
+		return token, nil
+	}
+	return reissueCSRFToken(w, store, session)
+}
+
+// reissueCSRFToken rotates the session's CSRF token and hands it to the
+// client in the CSRF cookie.
+func reissueCSRFToken(w http.ResponseWriter, store db.Store, session db.Session) (string, error) {
+	token, err := store.RotateCSRFToken(session.ID)
+	if err != nil {
+		return "", err
+	}
+	Cookies.Set(w, CSRFCookie, token, session.ExpiresAt)
+	logs.Logs(logInfo, fmt.Sprintf("CSRF token reissued for session %s", session.ID))
+	return token, nil
+}
+
+// preSessionCSRFToken returns the token of a visitor without a session,
+// derived from their pre_session cookie. Visitors without the cookie are
+// given one.
+func preSessionCSRFToken(w http.ResponseWriter, r *http.Request) string {
+	value, ok := Cookies.Get(r, preSessionCookie)
+	if !ok {
+		value = utils.GenerateToken(32)
+		Cookies.Set(w, preSessionCookie, value, time.Time{})
+	}
+	return utils.HashToken("pre_session:" + value)
+}
@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+)
+
+// csrfTest serves requests through CSRFProtect, recording whether the
+// wrapped handler ran and the token it was given for its page.
+type csrfTest struct {
+	t       *testing.T
+	handler http.Handler
+	served  bool
+	token   string
+}
+
+func newCSRFTest(t *testing.T, store db.Store) *csrfTest {
+	test := &csrfTest{t: t}
+	test.handler = CSRFProtect(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		test.served = true
+		test.token = CSRFToken(r)
+	}))
+	return test
+}
+
+// do sends a request with the cookies, the token in the form field if not
+// empty and the extra headers.
+func (c *csrfTest) do(method, path string, cookies []*http.Cookie, formToken string, header http.Header) *httptest.ResponseRecorder {
+	c.served, c.token = false, ""
+	body := ""
+	if formToken != "" {
+		body = url.Values{CSRFField: {formToken}}.Encode()
+	}
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if formToken != "" {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for name, values := range header {
+		for _, value := range values {
+			r.Header.Add(name, value)
+		}
+	}
+	for _, cookie := range cookies {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	c.handler.ServeHTTP(w, r)
+	return w
+}
+
+// responseCookie returns the value the response set for the cookie.
+func responseCookie(w *httptest.ResponseRecorder, name string) (string, bool) {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == Cookies.Name(name) && cookie.MaxAge >= 0 {
+			return cookie.Value, true
+		}
+	}
+	return "", false
+}
+
+func newSessionStore(t *testing.T) (*db.MemoryStore, db.Session) {
+	t.Helper()
+	store := db.NewMemoryStore()
+	if err := store.CreateUser("alice", "", "Plum-Kettle-93"); err != nil {
+		t.Fatal(err)
+	}
+	session, err := store.CreateSession("alice", "203.0.113.7", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, session
+}
+
+func sessionCookies(session db.Session, csrfToken string) []*http.Cookie {
+	cookies := []*http.Cookie{{Name: Cookies.Name(SessionCookie), Value: session.Token}}
+	if csrfToken != "" {
+		cookies = append(cookies, &http.Cookie{Name: Cookies.Name(CSRFCookie), Value: csrfToken})
+	}
+	return cookies
+}
+
+func TestCSRFProtectSession(t *testing.T) {
+	store, session := newSessionStore(t)
+	c := newCSRFTest(t, store)
+	cookies := sessionCookies(session, session.CSRFToken)
+
+	tests := []struct {
+		name      string
+		method    string
+		formToken string
+		header    http.Header
+		want      int
+	}{
+		{"GET needs no token", http.MethodGet, "", nil, http.StatusOK},
+		{"HEAD needs no token", http.MethodHead, "", nil, http.StatusOK},
+		{"POST without token", http.MethodPost, "", nil, http.StatusForbidden},
+		{"POST with wrong token", http.MethodPost, "wrong", nil, http.StatusForbidden},
+		{"POST with token in form", http.MethodPost, session.CSRFToken, nil, http.StatusOK},
+		{"POST with token in header", http.MethodPost, "", http.Header{CSRFHeader: {session.CSRFToken}}, http.StatusOK},
+		{"DELETE with wrong header", http.MethodDelete, "", http.Header{CSRFHeader: {"wrong"}}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := c.do(test.method, "/dashboard", cookies, test.formToken, test.header)
+			if w.Code != test.want || c.served != (test.want == http.StatusOK) {
+				t.Fatalf("got %d, served %t, want %d", w.Code, c.served, test.want)
+			}
+			if c.served && c.token != session.CSRFToken {
+				t.Fatalf("page was given token %q, want the session's", c.token)
+			}
+		})
+	}
+}
+
+func TestCSRFProtectPreSession(t *testing.T) {
+	store, _ := newSessionStore(t)
+	c := newCSRFTest(t, store)
+
+	// the login page hands out a pre_session cookie and a token derived from it
+	w := c.do(http.MethodGet, "/login", nil, "", nil)
+	preSession, ok := responseCookie(w, preSessionCookie)
+	if !ok || c.token == "" {
+		t.Fatal("the login page got no pre-session cookie or token")
+	}
+	token := c.token
+	cookies := []*http.Cookie{{Name: Cookies.Name(preSessionCookie), Value: preSession}}
+
+	if w := c.do(http.MethodPost, "/submit-login", cookies, token, nil); w.Code != http.StatusOK || !c.served {
+		t.Fatalf("login with the pre-session token: got %d", w.Code)
+	}
+	if w := c.do(http.MethodPost, "/submit-login", cookies, "", nil); w.Code != http.StatusForbidden || c.served {
+		t.Fatalf("login without a token: got %d, want 403", w.Code)
+	}
+	// a token is only good with the cookie it was derived from
+	other := []*http.Cookie{{Name: Cookies.Name(preSessionCookie), Value: "another-visitor"}}
+	if w := c.do(http.MethodPost, "/submit-login", other, token, nil); w.Code != http.StatusForbidden || c.served {
+		t.Fatalf("login with another visitor's token: got %d, want 403", w.Code)
+	}
+	if w := c.do(http.MethodPost, "/submit-login", nil, token, nil); w.Code != http.StatusForbidden || c.served {
+		t.Fatalf("login without the pre-session cookie: got %d, want 403", w.Code)
+	}
+}
+
+func TestCSRFProtectReissue(t *testing.T) {
+	store, session := newSessionStore(t)
+	c := newCSRFTest(t, store)
+
+	// a session whose CSRF cookie is missing gets a new token
+	w := c.do(http.MethodGet, "/dashboard", sessionCookies(session, ""), "", nil)
+	issued, ok := responseCookie(w, CSRFCookie)
+	if !ok || c.token != issued {
+		t.Fatalf("missing cookie: issued %q, page token %q", issued, c.token)
+	}
+	if ok, err := store.ValidateCSRFToken(session.ID, issued); !ok || err != nil {
+		t.Fatalf("the reissued token does not validate: %t, %v", ok, err)
+	}
+
+	// the first token is stale now; sending it back from its own cookie
+	// fails but hands out a fresh one for the next page
+	stale := session.CSRFToken
+	w = c.do(http.MethodPost, "/dashboard", sessionCookies(session, stale), stale, nil)
+	if w.Code != http.StatusForbidden || c.served {
+		t.Fatalf("stale token: got %d, want 403", w.Code)
+	}
+	fresh, ok := responseCookie(w, CSRFCookie)
+	if !ok || fresh == stale {
+		t.Fatal("a stale CSRF cookie was not replaced")
+	}
+	if w := c.do(http.MethodPost, "/dashboard", sessionCookies(session, fresh), fresh, nil); w.Code != http.StatusOK {
+		t.Fatalf("fresh token: got %d", w.Code)
+	}
+
+	// a wrong token that is not the cookie's value changes nothing
+	w = c.do(http.MethodPost, "/dashboard", sessionCookies(session, fresh), "forged", nil)
+	if _, ok := responseCookie(w, CSRFCookie); w.Code != http.StatusForbidden || ok {
+		t.Fatalf("forged token: got %d, reissued %t", w.Code, ok)
+	}
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/utils"
+)
+
+func TestMain(m *testing.M) {
+	go logs.ProcessLogs()
+	// the lowest cost keeps creating users fast
+	if err := utils.SetPasswordHasher(utils.BcryptHasher{Cost: 4}); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
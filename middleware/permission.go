@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+var ErrForbidden = errors.New("forbidden - user lacks permission")
+
+/*
+RequirePermission wraps a handler so it only runs for logged in users holding
+the permission, such as "admin:users". It authenticates like RequireAuth, so
+the handler finds the Principal with CurrentUser. Users without the
+permission get 403 Forbidden.
+*/
+func RequirePermission(store db.Store, permission string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		check := func(w http.ResponseWriter, r *http.Request) {
+			principal, _ := CurrentUser(r)
+			if !principal.Can(permission) {
+				logs.Logs(logWarning, fmt.Sprintf("%s! User %s needs %s for %s", ErrForbidden, principal.Username, permission, r.URL.Path))
+				http.Error(w, "Forbidden", http.StatusForbidden)
+				return
+			}
+			next(w, r)
+		}
+		return RequireAuth(store)(http.HandlerFunc(check)).ServeHTTP
+	}
+}
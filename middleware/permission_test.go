@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequirePermission(t *testing.T) {
+	store, session := newSessionStore(t)
+	var served bool
+	handler := RequirePermission(store, "admin:users")(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	})
+	request := func() *httptest.ResponseRecorder {
+		t.Helper()
+		served = false
+		r := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+		r.Header.Set("Accept", "text/html")
+		r.AddCookie(&http.Cookie{Name: Cookies.Name(SessionCookie), Value: session.Token})
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if w := request(); w.Code != http.StatusForbidden || served {
+		t.Fatalf("without the permission: got %d, served %t, want 403", w.Code, served)
+	}
+
+	if err := store.AssignRole("alice", "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if w := request(); w.Code != http.StatusOK || !served {
+		t.Fatalf("with the permission: got %d, served %t, want 200", w.Code, served)
+	}
+
+	// logged out requests are sent to log in, not told they are forbidden
+	r := httptest.NewRequest(http.MethodGet, "/admin/users", nil)
+	r.Header.Set("Accept", "text/html")
+	w := httptest.NewRecorder()
+	served = false
+	handler(w, r)
+	if w.Code != http.StatusSeeOther || served {
+		t.Fatalf("logged out: got %d, served %t, want 303", w.Code, served)
+	}
+}
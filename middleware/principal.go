@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/db"
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+/*
+Principal is the logged in user a request is made by, put in the request
+context by RequireAuth so handlers do not have to look the session up again.
+*/
+type Principal struct {
+	UserID      int64
+	Username    string
+	SessionID   string
+	Roles       []string
+	Permissions []string
+}
+
+// Can reports whether the principal holds the permission through any of
+// their roles.
+func (p Principal) Can(permission string) bool {
+	return slices.Contains(p.Permissions, permission)
+}
+
+// principalKey is the context key of the Principal, unexported so only
+// this package can set it.
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored in ctx, and false if
+// there is none.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
+
+// CurrentUser returns the principal of a logged in request that went through
+// CSRFProtect or RequireAuth, and false for any other request.
+func CurrentUser(r *http.Request) (Principal, bool) {
+	return PrincipalFromContext(r.Context())
+}
+
+// newPrincipal builds the principal of a validated session, loading the
+// user's roles and permissions unless the session carries them.
+func newPrincipal(store db.Store, session db.Session) (Principal, error) {
+	var err error
+	principal := Principal{UserID: session.UserID, Username: session.Username, SessionID: session.ID}
+	if session.HasRoles {
+		principal.Roles, principal.Permissions = session.Roles, session.Permissions
+		return principal, nil
+	}
+	principal.Roles, err = store.GetRoles(session.Username)
+	if err != nil {
+		return Principal{}, err
+	}
+	principal.Permissions, err = store.GetPermissions(session.Username)
+	if err != nil {
+		return Principal{}, err
+	}
+	return principal, nil
+}
+
+/*
+RequireAuth wraps a handler so it only runs for logged in users, who the
+handler finds with CurrentUser. CSRFProtect has usually put the Principal in
+the request context already, so it is reused; otherwise the session is
+checked here and the Principal stored. Requests without a valid session are
+sent to the login page.
+*/
+func RequireAuth(store db.Store) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := CurrentUser(r); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			session, err := AuthorizeRequest(store, r)
+			if err != nil {
+				logs.Logs(logWarning, fmt.Sprintf("Failed to authorize request: %s. Redirecting back to login page...", err.Error()))
+				RedirectToLogin(w, r)
+				return
+			}
+			principal, err := newPrincipal(store, session)
+			if err != nil {
+				logs.Logs(logErr, fmt.Sprintf("Failed to get roles: %s", err.Error()))
+				http.Error(w, fmt.Sprintf("Unable to load page: %s", err.Error()), http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireAuth(t *testing.T) {
+	store, session := newSessionStore(t)
+	var served bool
+	handler := RequireAuth(store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ := CurrentUser(r)
+		served = user.Username == "alice"
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		accept   string
+		loggedIn bool
+		want     int
+	}{
+		{"page", http.MethodGet, "text/html,application/xhtml+xml,*/*;q=0.8", false, http.StatusSeeOther},
+		{"form post", http.MethodPost, "text/html,*/*;q=0.8", false, http.StatusSeeOther},
+		{"no accept header", http.MethodGet, "", false, http.StatusSeeOther},
+		{"script", http.MethodPost, "application/json", false, http.StatusUnauthorized},
+		{"logged in", http.MethodGet, "text/html", true, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			served = false
+			r := httptest.NewRequest(test.method, "/dashboard", nil)
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			if test.loggedIn {
+				r.AddCookie(&http.Cookie{Name: Cookies.Name(SessionCookie), Value: session.Token})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.want || served != test.loggedIn {
+				t.Fatalf("got %d, served %t, want %d, served %t", w.Code, served, test.want, test.loggedIn)
+			}
+			if test.want == http.StatusSeeOther && w.Header().Get("Location") != "/login" {
+				t.Errorf("redirected to %q, want /login", w.Header().Get("Location"))
+			}
+		})
+	}
+}
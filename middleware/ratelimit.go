@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+const logWarning = 2
+
+/*
+Limiter decides whether a request counted against keys may go ahead. The
+in-memory MemoryLimiter suits a single instance; deployments with several
+instances can plug in a Limiter backed by shared state, such as a database or
+cache, so clients cannot spread their requests across instances.
+*/
+type Limiter interface {
+	// Allow takes one request from the allowance of every key, but only if
+	// each of them has one left, so a refused request uses up none of them.
+	// Otherwise it returns false and how long to wait before trying again.
+	Allow(keys ...string) (bool, time.Duration, error)
+}
+
+/*
+MemoryLimiter is a token bucket Limiter kept in process memory. Each key may
+make a burst of up to requests requests, and its allowance refills at
+requests per period. Idle keys are forgotten once their bucket is full again.
+*/
+type MemoryLimiter struct {
+	capacity float64
+	rate     float64 // tokens added per second
+	period   time.Duration
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time // the clock, replaced in tests
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryLimiter returns a MemoryLimiter allowing requests requests per
+// period for each key.
+func NewMemoryLimiter(requests int, period time.Duration) *MemoryLimiter {
+	return &MemoryLimiter{
+		capacity:  float64(requests),
+		rate:      float64(requests) / period.Seconds(),
+		period:    period,
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+/*
+Allow takes a token from the bucket of every key, once all of them are
+checked to hold one.
+
+Returns:
+
+- bool: True if the request may go ahead.
+
+- time.Duration: How long until every key has a token, when it may not.
+
+- error: Always nil for the in-memory limiter.
+*/
+func (l *MemoryLimiter) Allow(keys ...string) (bool, time.Duration, error) {
+	now := l.now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// buckets idle for a whole period are full again and can be dropped
+	if now.Sub(l.lastSweep) >= l.period {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) >= l.period {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	var wait time.Duration
+	buckets := make([]*bucket, 0, len(keys))
+	for _, key := range keys {
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{tokens: l.capacity, last: now}
+			l.buckets[key] = b
+		}
+		b.tokens = math.Min(l.capacity, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+		if b.tokens < 1 {
+			wait = max(wait, time.Duration((1-b.tokens)/l.rate*float64(time.Second)))
+		}
+		buckets = append(buckets, b)
+	}
+	if wait > 0 {
+		return false, wait, nil
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0, nil
+}
+
+/*
+ParseLimit parses a rate limit written as "requests/period", such as "10/1m"
+for ten requests a minute.
+
+Returns:
+
+- int: The number of requests.
+
+- time.Duration: The period they are allowed in.
+
+- error: An error if the limit is malformed.
+*/
+func ParseLimit(limit string) (int, time.Duration, error) {
+	count, period, ok := strings.Cut(limit, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid rate limit %q, expected requests/period", limit)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || requests < 1 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q, expected requests/period", limit)
+	}
+	duration, err := time.ParseDuration(strings.TrimSpace(period))
+	if err != nil || duration <= 0 {
+		return 0, 0, fmt.Errorf("invalid rate limit %q, expected requests/period", limit)
+	}
+	return requests, duration, nil
+}
+
+// KeyFunc picks what a request is counted against. It returns "" for
+// requests it does not apply to.
+type KeyFunc func(r *http.Request) string
+
+// ByIP counts requests against the client's IP address.
+func ByIP(r *http.Request) string {
+	return "ip:" + ClientIP(r)
+}
+
+// ByFormValue counts requests against the value of a form field, such as
+// the username a login is for.
+func ByFormValue(field string) KeyFunc {
+	return func(r *http.Request) string {
+		value := strings.TrimSpace(r.FormValue(field))
+		if value == "" {
+			return ""
+		}
+		return field + ":" + value
+	}
+}
+
+// RateLimitFailClosed makes RateLimit refuse requests with 503 Service
+// Unavailable when the limiter fails, instead of letting them through.
+var RateLimitFailClosed bool
+
+/*
+RateLimit wraps a handler so each request is counted against every key the
+key functions return. If any key is out of allowance the handler is skipped,
+no key's allowance is used up, and the client gets 429 Too Many Requests with
+a Retry-After header.
+
+If the limiter itself fails, which only a shared backend can, the request is
+let through by default so an outage of that backend does not take logins
+down with it. Password guessing is still slowed by the failed login lockout,
+which is kept in the main store. Set RateLimitFailClosed to refuse such
+requests instead.
+*/
+func RateLimit(limiter Limiter, keys ...KeyFunc) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var requestKeys []string
+			for _, keyFunc := range keys {
+				if key := keyFunc(r); key != "" {
+					requestKeys = append(requestKeys, key)
+				}
+			}
+			if len(requestKeys) == 0 {
+				next(w, r)
+				return
+			}
+
+			ok, wait, err := limiter.Allow(requestKeys...)
+			if err != nil && RateLimitFailClosed {
+				logs.Logs(logErr, fmt.Sprintf("Rate limiter failed, refusing request: %s", err.Error()))
+				http.Error(w, "Service unavailable. Please try again later.", http.StatusServiceUnavailable)
+				return
+			}
+			if err != nil {
+				logs.Logs(logErr, fmt.Sprintf("Rate limiter failed, letting request through: %s", err.Error()))
+				next(w, r)
+				return
+			}
+			if !ok {
+				logs.Logs(logWarning, fmt.Sprintf("Rate limit exceeded for %s on %s", strings.Join(requestKeys, ", "), r.URL.Path))
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "Too many requests. Please try again later.", http.StatusTooManyRequests)
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock is a clock tests move forward by hand.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestLimiter(requests int, period time.Duration) (*MemoryLimiter, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := NewMemoryLimiter(requests, period)
+	limiter.now = clock.Now
+	limiter.lastSweep = clock.now
+	return limiter, clock
+}
+
+func TestMemoryLimiterRefill(t *testing.T) {
+	// 3 requests a minute: a token every 20 seconds
+	limiter, clock := newTestLimiter(3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := limiter.Allow("ip:a"); !ok {
+			t.Fatalf("request %d of the burst was refused", i+1)
+		}
+	}
+	ok, wait, _ := limiter.Allow("ip:a")
+	if ok || wait != 20*time.Second {
+		t.Fatalf("request over the burst: got %t, wait %s, want refused with 20s", ok, wait)
+	}
+	// other keys have their own allowance
+	if ok, _, _ := limiter.Allow("ip:b"); !ok {
+		t.Fatal("another key was refused")
+	}
+
+	clock.now = clock.now.Add(15 * time.Second)
+	ok, wait, _ = limiter.Allow("ip:a")
+	if ok || wait != 5*time.Second {
+		t.Fatalf("after 15s: got %t, wait %s, want refused with 5s", ok, wait)
+	}
+	clock.now = clock.now.Add(5 * time.Second)
+	if ok, _, _ := limiter.Allow("ip:a"); !ok {
+		t.Fatal("after a token refilled the request was refused")
+	}
+	if ok, _, _ := limiter.Allow("ip:a"); ok {
+		t.Fatal("a single refilled token allowed two requests")
+	}
+
+	// the bucket never holds more than the burst
+	clock.now = clock.now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if ok, _, _ := limiter.Allow("ip:a"); !ok {
+			t.Fatalf("request %d after an idle hour was refused", i+1)
+		}
+	}
+	if ok, _, _ := limiter.Allow("ip:a"); ok {
+		t.Fatal("an idle hour allowed more than the burst")
+	}
+}
+
+func TestMemoryLimiterSeveralKeys(t *testing.T) {
+	limiter, clock := newTestLimiter(1, time.Minute)
+
+	if ok, _, _ := limiter.Allow("ip:a", "username:alice"); !ok {
+		t.Fatal("the first request was refused")
+	}
+	clock.now = clock.now.Add(30 * time.Second)
+	ok, wait, _ := limiter.Allow("ip:b", "username:alice")
+	if ok || wait != 30*time.Second {
+		t.Fatalf("request for an exhausted key: got %t, wait %s, want refused with 30s", ok, wait)
+	}
+	// the refused request took nothing from the key that had allowance left
+	if ok, _, _ := limiter.Allow("ip:b", "username:bob"); !ok {
+		t.Fatal("a refused request used up the allowance of its other key")
+	}
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	limiter, clock := newTestLimiter(3, time.Minute)
+	limiter.Allow("ip:a")
+	clock.now = clock.now.Add(2 * time.Minute)
+	limiter.Allow("ip:b")
+	if _, ok := limiter.buckets["ip:a"]; ok {
+		t.Fatal("an idle bucket was kept")
+	}
+}
+
+// failingLimiter is a Limiter whose backend is down.
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(...string) (bool, time.Duration, error) {
+	return false, 0, errors.New("backend unavailable")
+}
+
+func TestRateLimit(t *testing.T) {
+	served := 0
+	next := func(w http.ResponseWriter, r *http.Request) { served++ }
+	limiter, clock := newTestLimiter(1, 90*time.Second)
+	handler := RateLimit(limiter, ByIP, ByFormValue("username"))(next)
+
+	request := func(username string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/submit-login?username="+username, nil)
+		r.RemoteAddr = "203.0.113.7:4000"
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if w := request("alice"); w.Code != http.StatusOK || served != 1 {
+		t.Fatalf("first request: got %d, served %d", w.Code, served)
+	}
+	clock.now = clock.now.Add(500 * time.Millisecond)
+	w := request("bob")
+	if w.Code != http.StatusTooManyRequests || served != 1 {
+		t.Fatalf("second request from the IP: got %d, served %d, want 429", w.Code, served)
+	}
+	// the wait is rounded up to whole seconds
+	if got := w.Header().Get("Retry-After"); got != "90" {
+		t.Fatalf("Retry-After = %q, want 90", got)
+	}
+}
+
+func TestRateLimitRefusalTakesNothing(t *testing.T) {
+	served := 0
+	next := func(w http.ResponseWriter, r *http.Request) { served++ }
+	limiter, _ := newTestLimiter(1, time.Minute)
+	handler := RateLimit(limiter, ByIP, ByFormValue("username"))(next)
+
+	request := func(ip, username string) int {
+		r := httptest.NewRequest(http.MethodPost, "/logout?username="+username, nil)
+		r.RemoteAddr = ip + ":4000"
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	if code := request("203.0.113.7", "alice"); code != http.StatusOK {
+		t.Fatalf("first request: got %d", code)
+	}
+	if code := request("203.0.113.8", "alice"); code != http.StatusTooManyRequests {
+		t.Fatalf("request for alice from another IP: got %d, want 429", code)
+	}
+	// the refused request for alice did not spend the second IP's allowance
+	if code := request("203.0.113.8", "bob"); code != http.StatusOK || served != 2 {
+		t.Fatalf("request for bob from the second IP: got %d, served %d, want 200", code, served)
+	}
+}
+
+func TestRateLimitBackendFailure(t *testing.T) {
+	served := 0
+	next := func(w http.ResponseWriter, r *http.Request) { served++ }
+	handler := RateLimit(failingLimiter{}, ByIP)(next)
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/submit-login", nil))
+	if w.Code != http.StatusOK || served != 1 {
+		t.Fatalf("failing open: got %d, served %d", w.Code, served)
+	}
+
+	RateLimitFailClosed = true
+	t.Cleanup(func() { RateLimitFailClosed = false })
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/submit-login", nil))
+	if w.Code != http.StatusServiceUnavailable || served != 1 {
+		t.Fatalf("failing closed: got %d, served %d, want 503", w.Code, served)
+	}
+}
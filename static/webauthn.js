@@ -0,0 +1,91 @@
+// Browser side of the passkey ceremonies. The server sends and expects binary
+// fields as unpadded base64url strings; the WebAuthn API uses ArrayBuffers.
+
+function base64urlToBuffer(value) {
+    const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+    const padded = base64 + "=".repeat((4 - base64.length % 4) % 4);
+    return Uint8Array.from(atob(padded), c => c.charCodeAt(0)).buffer;
+}
+
+function bufferToBase64url(buffer) {
+    const bytes = new Uint8Array(buffer);
+    let binary = "";
+    bytes.forEach(b => binary += String.fromCharCode(b));
+    return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+// csrfToken returns the token the server put in the page, which every POST
+// must send back.
+function csrfToken() {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    return meta ? meta.content : "";
+}
+
+async function postJSON(url, body) {
+    const response = await fetch(url, {
+        method: "POST",
+        headers: {"Content-Type": "application/json", "X-CSRF-Token": csrfToken()},
+        credentials: "same-origin",
+        body: JSON.stringify(body || {}),
+    });
+    const data = await response.json();
+    if (!response.ok) {
+        throw new Error(data.error || response.statusText);
+    }
+    return data;
+}
+
+function showPasskeyMessage(message) {
+    const element = document.getElementById("passkey-message");
+    if (element) {
+        element.textContent = message;
+    }
+}
+
+async function registerPasskey() {
+    try {
+        const options = (await postJSON("/webauthn/register/begin")).publicKey;
+        options.challenge = base64urlToBuffer(options.challenge);
+        options.user.id = base64urlToBuffer(options.user.id);
+        options.excludeCredentials.forEach(c => c.id = base64urlToBuffer(c.id));
+
+        const credential = await navigator.credentials.create({publicKey: options});
+        await postJSON("/webauthn/register/finish", {
+            id: credential.id,
+            rawId: bufferToBase64url(credential.rawId),
+            type: credential.type,
+            response: {
+                clientDataJSON: bufferToBase64url(credential.response.clientDataJSON),
+                attestationObject: bufferToBase64url(credential.response.attestationObject),
+                transports: credential.response.getTransports ? credential.response.getTransports() : [],
+            },
+        });
+        showPasskeyMessage("Passkey registered.");
+    } catch (err) {
+        showPasskeyMessage("Passkey registration failed: " + err.message);
+    }
+}
+
+async function loginWithPasskey() {
+    try {
+        const options = (await postJSON("/webauthn/login/begin")).publicKey;
+        options.challenge = base64urlToBuffer(options.challenge);
+        options.allowCredentials.forEach(c => c.id = base64urlToBuffer(c.id));
+
+        const assertion = await navigator.credentials.get({publicKey: options});
+        const result = await postJSON("/webauthn/login/finish", {
+            id: assertion.id,
+            rawId: bufferToBase64url(assertion.rawId),
+            type: assertion.type,
+            response: {
+                clientDataJSON: bufferToBase64url(assertion.response.clientDataJSON),
+                authenticatorData: bufferToBase64url(assertion.response.authenticatorData),
+                signature: bufferToBase64url(assertion.response.signature),
+                userHandle: assertion.response.userHandle ? bufferToBase64url(assertion.response.userHandle) : "",
+            },
+        });
+        window.location = result.redirect;
+    } catch (err) {
+        showPasskeyMessage("Passkey login failed: " + err.message);
+    }
+}
@@ -0,0 +1,267 @@
+package utils
+
+import (
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+const maxCookieSize = 4096 // bytes browsers keep for a cookie's name and value
+
+// Errors returned by CookieCodec.Decode. Each means the cookie must be
+// ignored; only ErrCookieExpired is expected from well-behaved browsers.
+var (
+	ErrCookieMalformed  = errors.New("cookie is not a sealed value")
+	ErrCookieUnknownKey = errors.New("cookie was sealed with an unknown key")
+	ErrCookieTampered   = errors.New("cookie failed authentication")
+	ErrCookieExpired    = errors.New("cookie has expired")
+	ErrCookieTooLarge   = errors.New("sealed value is too large for a cookie")
+)
+
+/*
+CookieCodec seals small values into cookies with XChaCha20-Poly1305, so the
+browser can hold them without being able to read or change them. The cookie
+name is authenticated along with the value, so a value cannot be moved to
+another cookie, and the expiry is sealed inside, so an old cookie is refused
+even if the browser kept it.
+
+Values are sealed with the current key and opened with any key listed, named
+by the key ID at the start of each cookie. To rotate, add a new key and make
+it current: cookies sealed with the old key keep working until they expire.
+The same keys sign values with Sign, for tokens every instance must derive
+alike.
+*/
+type CookieCodec struct {
+	keys    map[string]cipher.AEAD
+	macKeys map[string][]byte // signing key derived from each key, by key ID
+	current string
+}
+
+// sealedCookie is the JSON sealed into a cookie.
+type sealedCookie struct {
+	Expires int64           `json:"e,omitempty"` // Unix time, 0 for no expiry
+	Value   json.RawMessage `json:"v"`
+}
+
+/*
+NewCookieCodec creates a codec from 32 byte keys by key ID, sealing with the
+key named current.
+
+Returns:
+
+- *CookieCodec: The codec.
+
+- error: An error if a key ID or key is invalid or current is not listed.
+*/
+func NewCookieCodec(keys map[string][]byte, current string) (*CookieCodec, error) {
+	codec := &CookieCodec{
+		keys:    make(map[string]cipher.AEAD, len(keys)),
+		macKeys: make(map[string][]byte, len(keys)),
+		current: current,
+	}
+	for id, key := range keys {
+		if !keyIDPattern.MatchString(id) {
+			return nil, fmt.Errorf("invalid cookie key id %q, expected up to 16 letters and digits", id)
+		}
+		aead, err := chacha20poly1305.NewX(key)
+		if err != nil {
+			return nil, fmt.Errorf("cookie key %q: %w", id, err)
+		}
+		codec.keys[id] = aead
+		// never sign with the encryption key itself
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("cookie codec signing key"))
+		codec.macKeys[id] = mac.Sum(nil)
+	}
+	if _, ok := codec.keys[current]; !ok {
+		return nil, fmt.Errorf("current cookie key %q is not listed", current)
+	}
+	return codec, nil
+}
+
+/*
+LoadCookieCodec creates a codec from the COOKIE_KEYS environment variable, a
+comma separated list of id:key pairs where each key is 32 random bytes in
+base64, such as the output of openssl rand -base64 32. Values are sealed with
+the key named by COOKIE_KEY_ID, or the first one listed. Without any keys a
+random one is used, so sealed cookies stop working whenever the server
+restarts.
+
+Returns:
+
+- *CookieCodec: The codec.
+
+- error: An error if COOKIE_KEYS or COOKIE_KEY_ID is invalid.
+*/
+func LoadCookieCodec() (*CookieCodec, error) {
+	keys := make(map[string][]byte)
+	var first string
+	for _, entry := range strings.Split(os.Getenv("COOKIE_KEYS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid COOKIE_KEYS entry, expected id:key")
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("duplicate cookie key id %q in COOKIE_KEYS", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("cookie key %q is not valid base64", id)
+		}
+		keys[id] = key
+		if first == "" {
+			first = id
+		}
+	}
+
+	if len(keys) == 0 {
+		logs.Logs(logWarning, "COOKIE_KEYS is not set. Using a random key, sealed cookies will not survive a restart.")
+		key := make([]byte, chacha20poly1305.KeySize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		keys["random"], first = key, "random"
+	}
+
+	current := os.Getenv("COOKIE_KEY_ID")
+	if current == "" {
+		current = first
+	}
+	return NewCookieCodec(keys, current)
+}
+
+/*
+Encode seals the value, as JSON, for the named cookie. A zero expires means
+the value never expires.
+
+Returns:
+
+- string: The cookie value, the key ID and the sealed value in base64url.
+
+- error: An error if the value cannot be marshalled or is too large.
+*/
+func (c *CookieCodec) Encode(name string, value interface{}, expires time.Time) (string, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	sealed := sealedCookie{Value: raw}
+	if !expires.IsZero() {
+		sealed.Expires = expires.Unix()
+	}
+	plaintext, err := json.Marshal(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	aead := c.keys[c.current]
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	ciphertext := aead.Seal(nonce, nonce, plaintext, cookieAdditionalData(name, c.current))
+
+	encoded := c.current + "." + base64.RawURLEncoding.EncodeToString(ciphertext)
+	if len(name)+len(encoded) > maxCookieSize {
+		return "", ErrCookieTooLarge
+	}
+	return encoded, nil
+}
+
+/*
+Decode opens a value sealed by Encode for the named cookie into value.
+
+Returns:
+
+- error: ErrCookieMalformed, ErrCookieUnknownKey, ErrCookieTampered or
+ErrCookieExpired if the cookie must be ignored, or an error if the sealed
+JSON does not fit value.
+*/
+func (c *CookieCodec) Decode(name, encoded string, value interface{}) error {
+	id, data, ok := strings.Cut(encoded, ".")
+	if !ok {
+		return ErrCookieMalformed
+	}
+	aead, ok := c.keys[id]
+	if !ok {
+		return ErrCookieUnknownKey
+	}
+	ciphertext, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil || len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+		return ErrCookieMalformed
+	}
+
+	nonce, ciphertext := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, cookieAdditionalData(name, id))
+	if err != nil {
+		return ErrCookieTampered
+	}
+
+	var sealed sealedCookie
+	if err := json.Unmarshal(plaintext, &sealed); err != nil {
+		return ErrCookieMalformed
+	}
+	if sealed.Expires != 0 && time.Now().Unix() >= sealed.Expires {
+		return ErrCookieExpired
+	}
+	return json.Unmarshal(sealed.Value, value)
+}
+
+/*
+Sign derives a token from data with the current key, bound to name like a
+sealed cookie. Every instance sharing the keys derives the same token, so it
+can stand in for a stored one, such as the CSRF token of a session.
+
+Returns:
+
+- string: The key ID and the HMAC-SHA256 of name and data in base64url.
+*/
+func (c *CookieCodec) Sign(name, data string) string {
+	return c.current + "." + c.signature(c.current, name, data)
+}
+
+/*
+Verify checks a token made by Sign for the same name and data, with any key
+listed.
+
+Returns:
+
+- bool: true if the token matches.
+*/
+func (c *CookieCodec) Verify(name, data, token string) bool {
+	id, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	if _, ok := c.macKeys[id]; !ok {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(c.signature(id, name, data)))
+}
+
+// signature returns the HMAC of name and data under the key ID's signing key.
+func (c *CookieCodec) signature(id, name, data string) string {
+	mac := hmac.New(sha256.New, c.macKeys[id])
+	mac.Write(cookieAdditionalData(name, data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// cookieAdditionalData binds a sealed value to its cookie name and key ID.
+func cookieAdditionalData(name, keyID string) []byte {
+	return []byte(name + "\x00" + keyID)
+}
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// cookieKey returns a fixed 32 byte key made of one repeated byte.
+func cookieKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func newTestCodec(t *testing.T, keys map[string][]byte, current string) *CookieCodec {
+	codec, err := NewCookieCodec(keys, current)
+	if err != nil {
+		t.Fatalf("NewCookieCodec: %v", err)
+	}
+	return codec
+}
+
+type testSession struct {
+	ID    string   `json:"id"`
+	Roles []string `json:"roles"`
+}
+
+func TestCookieCodecRoundTrip(t *testing.T) {
+	codec := newTestCodec(t, map[string][]byte{"k1": cookieKey(1)}, "k1")
+	want := testSession{ID: "abc", Roles: []string{"admin"}}
+
+	for _, expires := range []time.Time{{}, time.Now().Add(time.Hour)} {
+		encoded, err := codec.Encode("session", want, expires)
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if !strings.HasPrefix(encoded, "k1.") || strings.Contains(encoded, "admin") {
+			t.Errorf("Encode = %q, want the key ID and an unreadable value", encoded)
+		}
+		var got testSession
+		err = codec.Decode("session", encoded, &got)
+		if err != nil || got.ID != want.ID || len(got.Roles) != 1 || got.Roles[0] != "admin" {
+			t.Errorf("Decode = %+v, %v, want %+v", got, err, want)
+		}
+	}
+
+	// sealing the same value twice gives different cookies
+	first, _ := codec.Encode("session", want, time.Time{})
+	second, _ := codec.Encode("session", want, time.Time{})
+	if first == second {
+		t.Error("Encode reused a nonce")
+	}
+}
+
+func TestCookieCodecRefused(t *testing.T) {
+	codec := newTestCodec(t, map[string][]byte{"k1": cookieKey(1), "k2": cookieKey(2)}, "k1")
+	encoded, err := codec.Encode("session", "value", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	expired, err := codec.Encode("session", "value", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	// flip one bit of the sealed value
+	id, data, _ := strings.Cut(encoded, ".")
+	raw, _ := base64.RawURLEncoding.DecodeString(data)
+	raw[len(raw)-1] ^= 1
+	tampered := id + "." + base64.RawURLEncoding.EncodeToString(raw)
+
+	tests := []struct {
+		name    string
+		cookie  string
+		encoded string
+		want    error
+	}{
+		{"tampered ciphertext", "session", tampered, ErrCookieTampered},
+		{"sealed for another cookie", "csrf_token", encoded, ErrCookieTampered},
+		{"key ID swapped", "session", "k2." + data, ErrCookieTampered},
+		{"expired", "session", expired, ErrCookieExpired},
+		{"unknown key ID", "session", "k9." + data, ErrCookieUnknownKey},
+		{"no key ID", "session", data, ErrCookieMalformed},
+		{"not base64", "session", "k1.!!!", ErrCookieMalformed},
+		{"truncated", "session", "k1." + data[:20], ErrCookieMalformed},
+		{"empty", "session", "", ErrCookieMalformed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var value string
+			err := codec.Decode(test.cookie, test.encoded, &value)
+			if !errors.Is(err, test.want) {
+				t.Errorf("Decode = %v, want %v", err, test.want)
+			}
+			if value != "" {
+				t.Errorf("Decode set the value to %q", value)
+			}
+		})
+	}
+}
+
+func TestCookieCodecRotation(t *testing.T) {
+	old := newTestCodec(t, map[string][]byte{"k1": cookieKey(1)}, "k1")
+	sealedOld, err := old.Encode("session", "old", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	// k2 is added and made current: old cookies still open, new ones use k2
+	rotated := newTestCodec(t, map[string][]byte{"k1": cookieKey(1), "k2": cookieKey(2)}, "k2")
+	var value string
+	err = rotated.Decode("session", sealedOld, &value)
+	if err != nil || value != "old" {
+		t.Errorf("Decode of a k1 cookie after rotation = %q, %v, want old", value, err)
+	}
+	sealedNew, err := rotated.Encode("session", "new", time.Now().Add(time.Hour))
+	if err != nil || !strings.HasPrefix(sealedNew, "k2.") {
+		t.Fatalf("Encode after rotation = %q, %v, want a k2 cookie", sealedNew, err)
+	}
+
+	// k1 is dropped: its cookies are refused, k2 cookies still open
+	retired := newTestCodec(t, map[string][]byte{"k2": cookieKey(2)}, "k2")
+	err = retired.Decode("session", sealedOld, &value)
+	if !errors.Is(err, ErrCookieUnknownKey) {
+		t.Errorf("Decode of a k1 cookie after k1 was dropped = %v, want %v", err, ErrCookieUnknownKey)
+	}
+	err = retired.Decode("session", sealedNew, &value)
+	if err != nil || value != "new" {
+		t.Errorf("Decode of a k2 cookie = %q, %v, want new", value, err)
+	}
+
+	// the same ID with a different key does not open the cookie
+	replaced := newTestCodec(t, map[string][]byte{"k1": cookieKey(3)}, "k1")
+	err = replaced.Decode("session", sealedOld, &value)
+	if !errors.Is(err, ErrCookieTampered) {
+		t.Errorf("Decode under a replaced k1 = %v, want %v", err, ErrCookieTampered)
+	}
+}
+
+func TestCookieCodecTooLarge(t *testing.T) {
+	codec := newTestCodec(t, map[string][]byte{"k1": cookieKey(1)}, "k1")
+	_, err := codec.Encode("session", strings.Repeat("x", maxCookieSize), time.Time{})
+	if !errors.Is(err, ErrCookieTooLarge) {
+		t.Errorf("Encode of a large value = %v, want %v", err, ErrCookieTooLarge)
+	}
+}
+
+func TestNewCookieCodecInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[string][]byte
+		current string
+	}{
+		{"short key", map[string][]byte{"k1": make([]byte, 16)}, "k1"},
+		{"invalid key ID", map[string][]byte{"k-1": cookieKey(1)}, "k-1"},
+		{"current not listed", map[string][]byte{"k1": cookieKey(1)}, "k2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewCookieCodec(test.keys, test.current)
+			if err == nil {
+				t.Error("NewCookieCodec succeeded")
+			}
+		})
+	}
+}
+
+func TestLoadCookieCodec(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(cookieKey(1))
+	k2 := base64.StdEncoding.EncodeToString(cookieKey(2))
+
+	t.Setenv("COOKIE_KEYS", "k1:"+k1+", k2:"+k2)
+	t.Setenv("COOKIE_KEY_ID", "")
+	codec, err := LoadCookieCodec()
+	if err != nil || codec.current != "k1" || len(codec.keys) != 2 {
+		t.Fatalf("LoadCookieCodec = %+v, %v, want keys k1 and k2 sealing with k1", codec, err)
+	}
+	t.Setenv("COOKIE_KEY_ID", "k2")
+	codec, err = LoadCookieCodec()
+	if err != nil || codec.current != "k2" {
+		t.Errorf("LoadCookieCodec with COOKIE_KEY_ID=k2 = %+v, %v, want k2 current", codec, err)
+	}
+
+	invalid := map[string]string{
+		"no key ID":       k1,
+		"duplicate ID":    "k1:" + k1 + ",k1:" + k2,
+		"not base64":      "k1:not-base64!",
+		"short key":       "k1:" + base64.StdEncoding.EncodeToString(make([]byte, 16)),
+		"unknown current": "k1:" + k1,
+	}
+	for name, keys := range invalid {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv("COOKIE_KEYS", keys)
+			t.Setenv("COOKIE_KEY_ID", "")
+			if name == "unknown current" {
+				t.Setenv("COOKIE_KEY_ID", "k9")
+			}
+			_, err := LoadCookieCodec()
+			if err == nil {
+				t.Error("LoadCookieCodec succeeded")
+			}
+		})
+	}
+}
+
+func TestCookieCodecSign(t *testing.T) {
+	old := newTestCodec(t, map[string][]byte{"k1": cookieKey(1)}, "k1")
+	token := old.Sign("session_csrf", "session1")
+
+	// another instance with the same keys derives the same token
+	other := newTestCodec(t, map[string][]byte{"k1": cookieKey(1)}, "k1")
+	if other.Sign("session_csrf", "session1") != token || !other.Verify("session_csrf", "session1", token) {
+		t.Error("a codec with the same keys does not accept the token")
+	}
+
+	rotated := newTestCodec(t, map[string][]byte{"k1": cookieKey(1), "k2": cookieKey(2)}, "k2")
+	tests := []struct {
+		name  string
+		codec *CookieCodec
+		data  string
+		token string
+		want  bool
+	}{
+		{"signed before rotation", rotated, "session1", token, true},
+		{"signed after rotation", rotated, "session1", rotated.Sign("session_csrf", "session1"), true},
+		{"other data", rotated, "session2", token, false},
+		{"signed for another name", rotated, "session1", rotated.Sign("session_token", "session1"), false},
+		{"key ID swapped", rotated, "session1", "k2" + strings.TrimPrefix(token, "k1"), false},
+		{"unknown key ID", rotated, "session1", "k9" + strings.TrimPrefix(token, "k1"), false},
+		{"no key ID", rotated, "session1", strings.TrimPrefix(token, "k1."), false},
+		{"empty", rotated, "session1", "", false},
+		{"key dropped", newTestCodec(t, map[string][]byte{"k2": cookieKey(2)}, "k2"), "session1", token, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.codec.Verify("session_csrf", test.data, test.token); got != test.want {
+				t.Errorf("Verify = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
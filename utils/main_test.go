@@ -0,0 +1,13 @@
+package utils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+func TestMain(m *testing.M) {
+	go logs.ProcessLogs()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,379 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+var ErrMalformedHash = errors.New("malformed password hash")
+
+/*
+PasswordHasher hashes passwords for storage in one algorithm. New passwords
+are hashed with the configured hasher (see SetPasswordHasher); hashes made by
+any known hasher can still be verified, and are flagged for a rehash when
+they were made with another algorithm or other parameters.
+*/
+type PasswordHasher interface {
+	// Hash encodes the password with the hasher's current parameters.
+	Hash(password string) (string, error)
+	// Recognizes reports whether encoded was made by this algorithm.
+	Recognizes(encoded string) bool
+	// Verify reports whether password matches the encoded hash.
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was made with parameters other
+	// than the hasher's current ones.
+	NeedsRehash(encoded string) bool
+}
+
+// ErrNoPasswordHasher is returned when a password is hashed before
+// LoadPasswordHasher or SetPasswordHasher was called.
+var ErrNoPasswordHasher = errors.New("password hasher is not configured")
+
+var (
+	passwordHasher PasswordHasher // hasher for new passwords, set at startup
+	dummyHash      string         // hash of a random password, see VerifyDummyPassword
+)
+
+/*
+LoadPasswordHasher picks the hasher for new passwords from the
+PASSWORD_HASH_ALGORITHM environment variable, defaulting to Argon2id, and
+checks its parameters. It must be called once at startup, before any password
+is hashed, so a bad setting stops the server instead of failing requests.
+
+Returns:
+
+- error: An error if the algorithm is unknown or a parameter is out of range.
+*/
+func LoadPasswordHasher() error {
+	var hasher PasswordHasher
+	switch algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm {
+	case "bcrypt":
+		cost, err := envUint("BCRYPT_COST", uint64(bcrypt.DefaultCost), uint64(bcrypt.MinCost), uint64(bcrypt.MaxCost))
+		if err != nil {
+			return err
+		}
+		hasher = BcryptHasher{Cost: int(cost)}
+	case "", "argon2id":
+		memory, err := envUint("ARGON2_MEMORY", 19*1024, 8, math.MaxUint32)
+		if err != nil {
+			return err
+		}
+		iterations, err := envUint("ARGON2_ITERATIONS", 2, 1, math.MaxUint32)
+		if err != nil {
+			return err
+		}
+		parallelism, err := envUint("ARGON2_PARALLELISM", 1, 1, math.MaxUint8)
+		if err != nil {
+			return err
+		}
+		// Argon2 needs at least 8 KiB of memory per lane
+		if memory < 8*parallelism {
+			return fmt.Errorf("ARGON2_MEMORY must be at least %d KiB for ARGON2_PARALLELISM %d", 8*parallelism, parallelism)
+		}
+		hasher = Argon2idHasher{
+			Memory:      uint32(memory),
+			Iterations:  uint32(iterations),
+			Parallelism: uint8(parallelism),
+			SaltLength:  16,
+			KeyLength:   32,
+		}
+	default:
+		return fmt.Errorf("unknown PASSWORD_HASH_ALGORITHM %q, expected argon2id or bcrypt", algorithm)
+	}
+
+	err := loadPeppers()
+	if err != nil {
+		return err
+	}
+	if _, ok := hasher.(Argon2idHasher); !ok && currentPepper != nil {
+		return errors.New("PASSWORD_PEPPERS needs PASSWORD_HASH_ALGORITHM=argon2id")
+	}
+	passwordHasher = hasher
+	return makeDummyHash()
+}
+
+// SetPasswordHasher replaces the hasher used for new passwords, overriding
+// the environment. It must be called before any password is hashed.
+func SetPasswordHasher(hasher PasswordHasher) error {
+	passwordHasher = hasher
+	return makeDummyHash()
+}
+
+// makeDummyHash hashes a random password with the current hasher and pepper,
+// for VerifyDummyPassword to check against.
+func makeDummyHash() error {
+	hash, err := HashedPassword(GenerateToken(16))
+	if err != nil {
+		return err
+	}
+	dummyHash = hash
+	return nil
+}
+
+// MaxPasswordBytes returns the longest password, in bytes, the configured
+// hasher accepts, or 0 if it takes any length. bcrypt refuses passwords over
+// 72 bytes.
+func MaxPasswordBytes() int {
+	if _, ok := passwordHasher.(BcryptHasher); ok {
+		return bcryptMaxBytes
+	}
+	return 0
+}
+
+// knownHashers lists every algorithm stored hashes may use, the configured
+// one first.
+func knownHashers() []PasswordHasher {
+	return []PasswordHasher{passwordHasher, Argon2idHasher{}, BcryptHasher{}}
+}
+
+// HashedPassword hashes the password with the configured hasher, Argon2id by
+// default. The result is self-describing, so parameters can change later
+// without breaking existing hashes. When a pepper is configured it is applied
+// first and its key ID recorded in the hash.
+func HashedPassword(password string) (string, error) {
+	hasher := passwordHasher
+	if hasher == nil {
+		return "", ErrNoPasswordHasher
+	}
+	if currentPepper == nil {
+		return hasher.Hash(password)
+	}
+
+	// the key ID goes in the PHC parameters, which bcrypt hashes do not have
+	if _, ok := hasher.(Argon2idHasher); !ok {
+		return "", errors.New("password peppers need the argon2id hasher")
+	}
+	encoded, err := hasher.Hash(currentPepper.apply(password))
+	if err != nil {
+		return "", err
+	}
+	return withPepperKeyID(encoded, currentPepper.id), nil
+}
+
+/*
+VerifyPassword checks the password against a stored hash made by any known
+hasher, applying the pepper named by the hash's key ID if it has one.
+
+Returns:
+
+- bool: True if the password matches.
+
+- bool: True if the password matched but the hash was made with another
+algorithm, other parameters or another pepper than the current ones, so it
+should be replaced with a fresh HashedPassword.
+*/
+func VerifyPassword(password, hash string) (bool, bool) {
+	current := passwordHasher
+	if current == nil {
+		logs.Logs(logErr, "Cannot verify password: "+ErrNoPasswordHasher.Error())
+		return false, false
+	}
+
+	keyID := PepperKeyID(hash)
+	if keyID != "" {
+		p, ok := peppers[keyID]
+		if !ok {
+			logs.Logs(logErr, fmt.Sprintf("Password hash uses unknown pepper %q. Is it missing from PASSWORD_PEPPERS?", keyID))
+			return false, false
+		}
+		password = p.apply(password)
+	}
+	// a peppered hash is never rehashed without one
+	stalePepper := currentPepper != nil && keyID != currentPepper.id
+
+	for i, hasher := range knownHashers() {
+		if !hasher.Recognizes(hash) {
+			continue
+		}
+		ok, err := hasher.Verify(password, hash)
+		if err != nil {
+			logs.Logs(logWarning, fmt.Sprintf("Failed to verify password hash: %s", err.Error()))
+			return false, false
+		}
+		if !ok {
+			return false, false
+		}
+		if i == 0 {
+			return true, stalePepper || current.NeedsRehash(hash)
+		}
+		return true, true
+	}
+	return false, false
+}
+
+// VerifyDummyPassword checks the password against a hash no password
+// matches, made with the current hasher and pepper. Logins for users that do
+// not exist call it, so they take as long as those that do and the response
+// time does not tell which usernames are registered.
+func VerifyDummyPassword(password string) {
+	if dummyHash != "" {
+		VerifyPassword(password, dummyHash)
+	}
+}
+
+// CheckPasswordHash takes a password and hash string and checks if the hash
+// matches the password. The function returns true if the hash matches the
+// password and false otherwise.
+func CheckPasswordHash(password, hash string) bool {
+	ok, _ := VerifyPassword(password, hash)
+	return ok
+}
+
+/*
+Argon2idHasher hashes passwords with Argon2id (RFC 9106) and encodes them in
+the PHC string format, for example
+
+	$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+
+with the salt and hash in unpadded base64. Memory is in KiB. Unlike bcrypt,
+every byte of the password is used however long it is.
+*/
+type Argon2idHasher struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// argon2idParams are the parameters read back from an encoded hash.
+type argon2idParams struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+// Hash encodes the password with a fresh random salt.
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Recognizes reports whether encoded is an Argon2id PHC string.
+func (h Argon2idHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+// Verify recomputes the hash with the parameters and salt stored in encoded.
+func (h Argon2idHasher) Verify(password, encoded string) (bool, error) {
+	params, err := parseArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+// NeedsRehash reports whether encoded was made with other parameters.
+func (h Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, err := parseArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != h.Memory || params.iterations != h.Iterations || params.parallelism != h.Parallelism ||
+		uint32(len(params.salt)) != h.SaltLength || uint32(len(params.key)) != h.KeyLength
+}
+
+// parseArgon2id decodes an Argon2id PHC string.
+func parseArgon2id(encoded string) (argon2idParams, error) {
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return argon2idParams{}, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2idParams{}, fmt.Errorf("%w: unsupported Argon2 version", ErrMalformedHash)
+	}
+
+	var params argon2idParams
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return argon2idParams{}, ErrMalformedHash
+	}
+	if params.memory == 0 || params.iterations == 0 || params.parallelism == 0 {
+		return argon2idParams{}, ErrMalformedHash
+	}
+
+	var err error
+	params.salt, err = base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return argon2idParams{}, ErrMalformedHash
+	}
+	params.key, err = base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(params.key) == 0 {
+		return argon2idParams{}, ErrMalformedHash
+	}
+	return params, nil
+}
+
+// bcryptMaxBytes is the longest password bcrypt hashes.
+const bcryptMaxBytes = 72
+
+/*
+BcryptHasher hashes passwords with bcrypt at the given cost. bcrypt only uses
+the first 72 bytes of a password; it is kept so hashes from before Argon2id
+was the default keep working until they are rehashed.
+*/
+type BcryptHasher struct {
+	Cost int
+}
+
+// Hash encodes the password with bcrypt.
+func (h BcryptHasher) Hash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	return string(bytes), err
+}
+
+// Recognizes reports whether encoded is a bcrypt hash.
+func (h BcryptHasher) Recognizes(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// Verify compares the password with the bcrypt hash.
+func (h BcryptHasher) Verify(password, encoded string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// NeedsRehash reports whether encoded was made with another cost.
+func (h BcryptHasher) NeedsRehash(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// envUint reads an integer setting between min and max, falling back to def
+// when it is unset.
+func envUint(name string, def, min, max uint64) (uint64, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("invalid %s %q, expected a number from %d to %d", name, value, min, max)
+	}
+	return n, nil
+}
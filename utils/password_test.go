@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// setHasherEnv selects the algorithm with cheap parameters, so tests run fast.
+func setHasherEnv(t *testing.T, algorithm string) {
+	t.Helper()
+	t.Setenv("PASSWORD_HASH_ALGORITHM", algorithm)
+	t.Setenv("ARGON2_MEMORY", "64")
+	t.Setenv("ARGON2_ITERATIONS", "1")
+	t.Setenv("ARGON2_PARALLELISM", "")
+	t.Setenv("BCRYPT_COST", "4")
+}
+
+func TestVerifyPasswordRehash(t *testing.T) {
+	setHasherEnv(t, "bcrypt")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	bcryptHash, err := HashedPassword("Plum-Kettle-93")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setHasherEnv(t, "argon2id")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := VerifyPassword("Plum-Kettle-93", bcryptHash); !ok || !rehash {
+		t.Fatalf("bcrypt hash: got ok=%t rehash=%t, want both true", ok, rehash)
+	}
+	argonHash, err := HashedPassword("Plum-Kettle-93")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := VerifyPassword("Plum-Kettle-93", argonHash); !ok || rehash {
+		t.Fatalf("argon2id hash: got ok=%t rehash=%t, want ok and no rehash", ok, rehash)
+	}
+	if ok, _ := VerifyPassword("Plum-Kettle-94", argonHash); ok {
+		t.Fatal("a wrong password was accepted")
+	}
+
+	t.Setenv("ARGON2_ITERATIONS", "2")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, rehash := VerifyPassword("Plum-Kettle-93", argonHash); !ok || !rehash {
+		t.Fatalf("after changing parameters: got ok=%t rehash=%t, want both true", ok, rehash)
+	}
+}
+
+func TestLoadPasswordHasherInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm string
+		setting   string
+		value     string
+		err       string
+	}{
+		{"unknown algorithm", "scrypt", "", "", "unknown PASSWORD_HASH_ALGORITHM"},
+		{"bcrypt cost too low", "bcrypt", "BCRYPT_COST", "3", "invalid BCRYPT_COST"},
+		{"bcrypt cost too high", "bcrypt", "BCRYPT_COST", "32", "invalid BCRYPT_COST"},
+		{"no iterations", "argon2id", "ARGON2_ITERATIONS", "0", "invalid ARGON2_ITERATIONS"},
+		{"parallelism wraps around", "argon2id", "ARGON2_PARALLELISM", "256", "invalid ARGON2_PARALLELISM"},
+		{"memory too low for lanes", "argon2id", "ARGON2_PARALLELISM", "16", "must be at least 128 KiB"},
+		{"not a number", "argon2id", "ARGON2_MEMORY", "lots", "invalid ARGON2_MEMORY"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setHasherEnv(t, test.algorithm)
+			if test.setting != "" {
+				t.Setenv(test.setting, test.value)
+			}
+			err := LoadPasswordHasher()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestVerifyDummyPassword(t *testing.T) {
+	setHasherEnv(t, "argon2id")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if !passwordHasher.Recognizes(dummyHash) || passwordHasher.NeedsRehash(dummyHash) {
+		t.Fatalf("dummy hash %s is not made with the current hasher", dummyHash)
+	}
+	if ok, _ := VerifyPassword("", dummyHash); ok {
+		t.Fatal("the dummy hash accepted a password")
+	}
+}
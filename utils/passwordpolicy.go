@@ -0,0 +1,154 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+	"github.com/Bevs-n-Devs/WebAuthentication/validation"
+)
+
+// Rules a password can break, reported in validation.Error.Rule.
+const (
+	RuleMinLength   = "min_length"
+	RuleMaxLength   = "max_length"
+	RuleHasUsername = "username"
+	RuleDenyList    = "deny_list"
+	RuleStrength    = "strength"
+	RuleBreached    = "breached"
+)
+
+/*
+PasswordPolicy decides which new passwords are accepted. Lengths are counted
+in characters, not bytes, except MaxBytes, which caps the encoded length for
+hashers that refuse longer input (see MaxPasswordBytes). MinStrength is a PasswordStrength score, 0 to turn
+the strength check off. Breach lookups that fail are logged and the password
+is let through, so a missing corpus file does not stop signups.
+*/
+type PasswordPolicy struct {
+	MinLength   int
+	MaxLength   int
+	MaxBytes    int // 0 for no limit
+	MinStrength int
+	DenyList    map[string]bool // lowercased passwords that are always refused
+	Breached    *PwnedPasswords // passwords seen in breaches, nil to skip the check
+}
+
+// DefaultPasswordPolicy returns a policy following the NIST SP 800-63B
+// guidance: at least 8 characters, long passphrases allowed, no composition
+// rules, and common passwords refused.
+func DefaultPasswordPolicy() PasswordPolicy {
+	denyList := make(map[string]bool, len(commonPasswords))
+	for word := range commonPasswords {
+		denyList[word] = true
+	}
+	return PasswordPolicy{
+		MinLength:   8,
+		MaxLength:   128,
+		MinStrength: StrengthSomewhatSecure,
+		DenyList:    denyList,
+	}
+}
+
+/*
+LoadDenyList adds the passwords in a file, one per line, to the policy's
+deny-list. Blank lines and lines starting with # are skipped.
+
+Returns:
+
+- error: An error if the file cannot be read.
+*/
+func (p *PasswordPolicy) LoadDenyList(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if p.DenyList == nil {
+		p.DenyList = make(map[string]bool)
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.DenyList[strings.ToLower(line)] = true
+	}
+	return scanner.Err()
+}
+
+/*
+Check tests a new password for the user against every rule of the policy.
+
+Returns:
+
+- validation.Errors: The rules the password breaks, nil if it is accepted.
+*/
+func (p PasswordPolicy) Check(username, password string) validation.Errors {
+	var violations validation.Errors
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		violations = append(violations, validation.Error{
+			Field:   "password",
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Use at least %d characters.", p.MinLength),
+		})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		// later checks are skipped, long inputs are costly to score
+		return append(violations, validation.Error{
+			Field:   "password",
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Use at most %d characters.", p.MaxLength),
+		})
+	}
+
+	if p.MaxBytes > 0 && len(password) > p.MaxBytes {
+		return append(violations, validation.Error{
+			Field:   "password",
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Use at most %d bytes. Accented letters and symbols take up to 4 bytes each.", p.MaxBytes),
+		})
+	}
+
+	if len(username) >= 3 && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		violations = append(violations, validation.Error{
+			Field:   "password",
+			Rule:    RuleHasUsername,
+			Message: "Do not include your username.",
+		})
+	}
+
+	if p.DenyList[strings.ToLower(password)] {
+		violations = append(violations, validation.Error{
+			Field:   "password",
+			Rule:    RuleDenyList,
+			Message: "This password is too common. Choose one that others are unlikely to use.",
+		})
+	} else if p.MinStrength > 0 && length >= p.MinLength && PasswordStrength(password, username) < p.MinStrength {
+		violations = append(violations, validation.Error{
+			Field:   "password",
+			Rule:    RuleStrength,
+			Message: "This password is too easy to guess. Avoid common words, names, dates and keyboard patterns, or add more words.",
+		})
+	}
+
+	if p.Breached != nil && !p.DenyList[strings.ToLower(password)] {
+		count, err := p.Breached.Count(password)
+		if err != nil {
+			logs.Logs(logErr, fmt.Sprintf("Failed to search breached passwords: %s", err.Error()))
+		} else if count > 0 {
+			violations = append(violations, validation.Error{
+				Field:   "password",
+				Rule:    RuleBreached,
+				Message: fmt.Sprintf("This password has appeared in data breaches %d times, so attackers will try it. Choose another one.", count),
+			})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,147 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	tests := []struct {
+		password string
+		want     int
+	}{
+		{"password", StrengthTooGuessable},
+		{"p@ssw0rd", StrengthTooGuessable},     // leet spelling
+		{"drowssap", StrengthTooGuessable},     // reversed
+		{"aaaaaaaaaaaa", StrengthTooGuessable}, // repeat
+		{"abcdefghij", StrengthTooGuessable},   // sequence
+		{"123456789", StrengthTooGuessable},
+		{"qwertyuiop", StrengthTooGuessable},  // keyboard walk
+		{"alicealice", StrengthTooGuessable},  // username
+		{"summer1987", StrengthVeryGuessable}, // word and year
+		{"kd93ma0s", StrengthVerySecure},
+		{"xK9#mQ2$vL7!", StrengthVerySecure},
+		{"correct horse battery staple", StrengthVerySecure},
+	}
+	for _, test := range tests {
+		got := PasswordStrength(test.password, "alice")
+		if got != test.want {
+			t.Errorf("PasswordStrength(%q) = %d, want %d", test.password, got, test.want)
+		}
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"accepted", "correct horse battery staple", nil},
+		{"too short", "kd93ma0", []string{RuleMinLength}},
+		{"too long", strings.Repeat("a", 129), []string{RuleMaxLength}},
+		{"contains username", "xK9#ALICEmQ2$", []string{RuleHasUsername}},
+		{"deny-listed", "Password", []string{RuleDenyList}},
+		{"too guessable", "summer1987", []string{RuleStrength}},
+		{"short and common", "123456", []string{RuleMinLength, RuleDenyList}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, violation := range policy.Check("alice", test.password) {
+				if violation.Field != "password" || violation.Message == "" {
+					t.Errorf("violation %+v lacks a field or message", violation)
+				}
+				got = append(got, violation.Rule)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("Check(%q) broke %v, want %v", test.password, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyBcryptLength(t *testing.T) {
+	previous := passwordHasher
+	t.Cleanup(func() { passwordHasher = previous })
+	passwordHasher = BcryptHasher{Cost: 4}
+	policy := DefaultPasswordPolicy()
+	policy.MaxBytes = MaxPasswordBytes()
+	if policy.MaxBytes != 72 {
+		t.Fatalf("MaxPasswordBytes with bcrypt = %d, want 72", policy.MaxBytes)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		accepted bool
+	}{
+		{"72 bytes", "correct horse battery staple " + strings.Repeat("x", 43), true},
+		{"73 bytes", "correct horse battery staple " + strings.Repeat("x", 44), false},
+		// 40 characters, well within MaxLength, but 80 bytes
+		{"multi-byte", strings.Repeat("é", 40), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			errs := policy.Check("alice", test.password)
+			if test.accepted != (errs == nil) {
+				t.Fatalf("Check(%d bytes) = %v, want accepted %t", len(test.password), errs, test.accepted)
+			}
+			if !test.accepted {
+				if errs[0].Rule != RuleMaxLength {
+					t.Errorf("Check(%d bytes) broke %s, want %s", len(test.password), errs[0].Rule, RuleMaxLength)
+				}
+				return
+			}
+			// whatever the policy accepts can be hashed
+			if _, err := HashedPassword(test.password); err != nil {
+				t.Errorf("HashedPassword(%d bytes): %v", len(test.password), err)
+			}
+		})
+	}
+
+	passwordHasher = Argon2idHasher{}
+	if n := MaxPasswordBytes(); n != 0 {
+		t.Errorf("MaxPasswordBytes with Argon2id = %d, want 0", n)
+	}
+}
+
+func TestPasswordPolicyMinStrengthOff(t *testing.T) {
+	policy := DefaultPasswordPolicy()
+	policy.MinStrength = 0
+	if errs := policy.Check("alice", "summer1987"); errs != nil {
+		t.Errorf("Check with the strength check off = %v, want nil", errs)
+	}
+}
+
+func TestLoadDenyList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "denylist.txt")
+	err := os.WriteFile(path, []byte("# company words\n\n  Bevs-n-Devs-2024  \nkd93ma0s\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := DefaultPasswordPolicy()
+	err = policy.LoadDenyList(path)
+	if err != nil {
+		t.Fatalf("LoadDenyList: %v", err)
+	}
+
+	for _, password := range []string{"bevs-n-devs-2024", "KD93MA0S", "password"} {
+		errs := policy.Check("alice", password)
+		if len(errs) != 1 || errs[0].Rule != RuleDenyList {
+			t.Errorf("Check(%q) = %v, want only %s", password, errs, RuleDenyList)
+		}
+	}
+	if policy.DenyList["# company words"] || policy.DenyList[""] {
+		t.Error("comments and blank lines were added to the deny-list")
+	}
+
+	err = policy.LoadDenyList(filepath.Join(t.TempDir(), "missing.txt"))
+	if err == nil {
+		t.Error("LoadDenyList of a missing file succeeded")
+	}
+}
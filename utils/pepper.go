@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// pepper is a server-side secret mixed into passwords before they are
+// hashed. It is never stored in the database, so leaked hashes cannot be
+// cracked without it.
+type pepper struct {
+	id     string
+	secret []byte
+}
+
+var (
+	peppers       map[string]pepper // key ID -> pepper, old ones are kept to verify existing hashes
+	currentPepper *pepper           // pepper for new hashes, nil if peppering is off
+
+	keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9]{1,16}$`) // IDs of peppers and cookie keys
+)
+
+/*
+loadPeppers reads the peppers from the PASSWORD_PEPPERS environment variable,
+a comma separated list of id:secret pairs. New passwords use the pepper named
+by PASSWORD_PEPPER_ID, or the first one listed. Without any, passwords are
+hashed without a pepper. It is called by LoadPasswordHasher.
+
+To rotate, add a new pepper and make it current while keeping the old ones
+listed: hashes made with an old pepper still verify and are rehashed with the
+current one at the next login.
+
+Returns:
+
+- error: An error if an entry is malformed or PASSWORD_PEPPER_ID is not listed.
+*/
+func loadPeppers() error {
+	loaded := make(map[string]pepper)
+	var first string
+	for _, entry := range strings.Split(os.Getenv("PASSWORD_PEPPERS"), ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, secret, ok := strings.Cut(entry, ":")
+		if !ok || secret == "" || !keyIDPattern.MatchString(id) {
+			return errors.New("invalid PASSWORD_PEPPERS entry, expected id:secret with an alphanumeric id of up to 16 characters")
+		}
+		if _, ok := loaded[id]; ok {
+			return fmt.Errorf("duplicate pepper id %q in PASSWORD_PEPPERS", id)
+		}
+		loaded[id] = pepper{id: id, secret: []byte(secret)}
+		if first == "" {
+			first = id
+		}
+	}
+
+	id := os.Getenv("PASSWORD_PEPPER_ID")
+	if id == "" {
+		id = first
+	}
+	if id == "" {
+		if os.Getenv("PASSWORD_PEPPERS") != "" {
+			return errors.New("PASSWORD_PEPPERS is set but lists no peppers")
+		}
+		peppers, currentPepper = loaded, nil
+		return nil
+	}
+	p, ok := loaded[id]
+	if !ok {
+		return fmt.Errorf("PASSWORD_PEPPER_ID %q is not listed in PASSWORD_PEPPERS", id)
+	}
+	peppers, currentPepper = loaded, &p
+	return nil
+}
+
+// apply returns the password keyed with the pepper. The HMAC is base64
+// encoded so it holds no NUL bytes and stays well under bcrypt's 72 bytes.
+func (p pepper) apply(password string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(password))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// pepperKeyID returns the key ID recorded in a PHC string's parameters, or
+// "" if the hash was made without a pepper.
+func PepperKeyID(encoded string) string {
+	parts := strings.Split(encoded, "$")
+	if len(parts) < 4 {
+		return ""
+	}
+	for _, param := range strings.Split(parts[3], ",") {
+		if id, ok := strings.CutPrefix(param, "keyid="); ok {
+			return id
+		}
+	}
+	return ""
+}
+
+/*
+CheckPepperKeyIDs makes sure every pepper that stored hashes were made with
+is configured. It is called at startup with the key IDs found in the store,
+since a hash whose pepper is missing can never be verified again and its user
+would be locked out.
+
+Returns:
+
+- error: An error naming the key IDs missing from PASSWORD_PEPPERS.
+*/
+func CheckPepperKeyIDs(keyIDs []string) error {
+	var missing []string
+	for _, id := range keyIDs {
+		if _, ok := peppers[id]; !ok {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("stored password hashes use peppers %s missing from PASSWORD_PEPPERS", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// withPepperKeyID records the key ID in a PHC string's parameters.
+func withPepperKeyID(encoded, id string) string {
+	parts := strings.Split(encoded, "$")
+	parts[3] += ",keyid=" + id
+	return strings.Join(parts, "$")
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+// setPepperEnv configures a cheap Argon2id hasher with the given peppers.
+func setPepperEnv(t *testing.T, peppers, current string) {
+	t.Helper()
+	setHasherEnv(t, "argon2id")
+	t.Setenv("PASSWORD_PEPPERS", peppers)
+	t.Setenv("PASSWORD_PEPPER_ID", current)
+}
+
+func TestPepperRotation(t *testing.T) {
+	setPepperEnv(t, "k1:first-secret", "")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	oldHash, err := HashedPassword("Plum-Kettle-93")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PepperKeyID(oldHash) != "k1" {
+		t.Fatalf("hash %s does not record key ID k1", oldHash)
+	}
+
+	// add a new pepper and make it current, keeping the old one listed
+	setPepperEnv(t, "k1:first-secret,k2:second-secret", "k2")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	ok, rehash := VerifyPassword("Plum-Kettle-93", oldHash)
+	if !ok || !rehash {
+		t.Fatalf("old hash: got ok=%t rehash=%t, want both true", ok, rehash)
+	}
+	if ok, _ := VerifyPassword("Plum-Kettle-94", oldHash); ok {
+		t.Fatal("old hash accepted a wrong password")
+	}
+
+	newHash, err := HashedPassword("Plum-Kettle-93")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PepperKeyID(newHash) != "k2" {
+		t.Fatalf("hash %s does not record key ID k2", newHash)
+	}
+	ok, rehash = VerifyPassword("Plum-Kettle-93", newHash)
+	if !ok || rehash {
+		t.Fatalf("new hash: got ok=%t rehash=%t, want ok and no rehash", ok, rehash)
+	}
+
+	// once the old pepper is dropped its hashes no longer verify
+	setPepperEnv(t, "k2:second-secret", "")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := VerifyPassword("Plum-Kettle-93", oldHash); ok {
+		t.Fatal("hash verified without its pepper")
+	}
+}
+
+func TestPepperTurnedOn(t *testing.T) {
+	setPepperEnv(t, "", "")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	plainHash, err := HashedPassword("Plum-Kettle-93")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// turning peppers on flags hashes made without one for a rehash
+	setPepperEnv(t, "k1:first-secret", "")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	ok, rehash := VerifyPassword("Plum-Kettle-93", plainHash)
+	if !ok || !rehash {
+		t.Fatalf("got ok=%t rehash=%t, want both true", ok, rehash)
+	}
+}
+
+func TestLoadPeppersInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		peppers string
+		current string
+		err     string
+	}{
+		{"missing secret", "k1", "", "invalid PASSWORD_PEPPERS entry"},
+		{"empty secret", "k1:", "", "invalid PASSWORD_PEPPERS entry"},
+		{"invalid key ID", "key-1:secret", "", "invalid PASSWORD_PEPPERS entry"},
+		{"key ID too long", "k12345678901234567:secret", "", "invalid PASSWORD_PEPPERS entry"},
+		{"duplicate key ID", "k1:a,k1:b", "", "duplicate pepper id"},
+		{"no peppers listed", " , ", "", "lists no peppers"},
+		{"unknown current key ID", "k1:secret", "k2", "is not listed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setPepperEnv(t, test.peppers, test.current)
+			err := LoadPasswordHasher()
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("got error %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestDummyHashPeppered(t *testing.T) {
+	setPepperEnv(t, "k1:first-secret", "")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if PepperKeyID(dummyHash) != "k1" {
+		t.Fatalf("dummy hash %s is not made with the current pepper", dummyHash)
+	}
+}
+
+func TestCheckPepperKeyIDs(t *testing.T) {
+	setPepperEnv(t, "k1:first-secret,k2:second-secret", "k2")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPepperKeyIDs([]string{"k1", "k2"}); err != nil {
+		t.Fatalf("got error %v with every pepper configured", err)
+	}
+
+	// hashes peppered with k1 cannot be verified once it is dropped
+	setPepperEnv(t, "k2:second-secret", "")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckPepperKeyIDs([]string{"k1", "k2"})
+	if err == nil || !strings.Contains(err.Error(), "k1") {
+		t.Fatalf("got error %v, want one naming k1", err)
+	}
+
+	// nor without any peppers at all
+	setPepperEnv(t, "", "")
+	if err := LoadPasswordHasher(); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckPepperKeyIDs([]string{"k2"}); err == nil {
+		t.Fatal("got no error with peppering turned off")
+	}
+}
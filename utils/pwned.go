@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	pwnedPrefixLength = 5   // hex characters of the SHA-1 naming a bucket
+	pwnedMaxLine      = 128 // longest line read from the sorted file
+)
+
+/*
+PwnedPasswords looks passwords up in a local copy of the Pwned Passwords
+corpus, so breached passwords can be refused without sending anything over
+the network. Two layouts of the SHA-1 download are supported:
+
+- a directory of bucket files named after the first five hex characters of
+the hash, such as 21BD1.txt, each holding SUFFIX:COUNT lines like the range
+API returns. Only the one bucket is read.
+
+- a single file of HASH:COUNT lines sorted by hash, which is binary searched
+without being read into memory.
+*/
+type PwnedPasswords struct {
+	dir  string   // bucket directory, if the corpus is split
+	file *os.File // sorted file, if it is not
+	size int64
+}
+
+/*
+OpenPwnedPasswords opens the corpus at path, a bucket directory or a sorted
+hash file.
+
+Returns:
+
+- *PwnedPasswords: The corpus, to be closed when no longer needed.
+
+- error: An error if path cannot be opened.
+*/
+func OpenPwnedPasswords(path string) (*PwnedPasswords, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return &PwnedPasswords{dir: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &PwnedPasswords{file: file, size: info.Size()}, nil
+}
+
+// Close releases the sorted file, if one is open.
+func (p *PwnedPasswords) Close() error {
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Close()
+}
+
+/*
+Count reports how many times the password was seen in breaches.
+
+Returns:
+
+- int: The breach count, 0 if the password is not in the corpus.
+
+- error: An error if the corpus cannot be read.
+*/
+func (p *PwnedPasswords) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	if p.dir != "" {
+		return p.countInBucket(hash)
+	}
+	return p.countInSortedFile(hash)
+}
+
+// countInBucket scans the bucket file of the hash's prefix.
+func (p *PwnedPasswords) countInBucket(hash string) (int, error) {
+	prefix, suffix := hash[:pwnedPrefixLength], hash[pwnedPrefixLength:]
+	file, err := os.Open(filepath.Join(p.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		// the downloader skips empty buckets
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineHash, count, err := parsePwnedLine(scanner.Bytes())
+		if err != nil {
+			return 0, fmt.Errorf("bucket %s: %w", prefix, err)
+		}
+		if strings.EqualFold(lineHash, suffix) {
+			return count, nil
+		}
+	}
+	return 0, scanner.Err()
+}
+
+// countInSortedFile binary searches the sorted file by byte offset. Each
+// step reads the first line starting at or after the middle offset, so only
+// a few hundred bytes are read per lookup.
+func (p *PwnedPasswords) countInSortedFile(hash string) (int, error) {
+	low, high := int64(0), p.size
+	for low < high {
+		mid := low + (high-low)/2
+		line, start, end, err := p.lineAt(mid)
+		if err != nil {
+			return 0, err
+		}
+		if line == nil {
+			// no line starts between mid and the end of the file
+			high = mid
+			continue
+		}
+
+		lineHash, count, err := parsePwnedLine(line)
+		if err != nil {
+			return 0, fmt.Errorf("offset %d: %w", start, err)
+		}
+		switch cmp := strings.Compare(strings.ToUpper(lineHash), hash); {
+		case cmp == 0:
+			return count, nil
+		case cmp < 0:
+			low = end
+		default:
+			high = mid
+		}
+	}
+	return 0, nil
+}
+
+// lineAt returns the first line starting at or after offset, with the
+// offsets where it starts and where the next line starts. The line is nil if
+// there is none.
+func (p *PwnedPasswords) lineAt(offset int64) ([]byte, int64, int64, error) {
+	// read from the byte before offset, so a line starting exactly at offset
+	// is preceded by its newline
+	readFrom := max(offset-1, 0)
+	buf := make([]byte, 2*pwnedMaxLine)
+	n, err := p.file.ReadAt(buf, readFrom)
+	if err != nil && err != io.EOF {
+		return nil, 0, 0, err
+	}
+	buf = buf[:n]
+
+	start := 0
+	if offset > 0 {
+		newline := bytes.IndexByte(buf, '\n')
+		if newline < 0 {
+			return nil, 0, 0, nil
+		}
+		start = newline + 1
+	}
+	if start >= len(buf) {
+		return nil, 0, 0, nil
+	}
+
+	line := buf[start:]
+	length := len(line)
+	if newline := bytes.IndexByte(line, '\n'); newline >= 0 {
+		line = line[:newline]
+		length = newline + 1
+	} else if readFrom+int64(n) < p.size {
+		return nil, 0, 0, fmt.Errorf("line at offset %d is longer than %d bytes", readFrom+int64(start), pwnedMaxLine)
+	}
+
+	lineStart := readFrom + int64(start)
+	return line, lineStart, lineStart + int64(length), nil
+}
+
+// parsePwnedLine splits a HASH:COUNT line.
+func parsePwnedLine(line []byte) (string, int, error) {
+	hash, count, ok := strings.Cut(strings.TrimSpace(string(line)), ":")
+	if !ok {
+		return "", 0, fmt.Errorf("malformed Pwned Passwords line %q", line)
+	}
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return "", 0, fmt.Errorf("malformed Pwned Passwords count %q", count)
+	}
+	return hash, n, nil
+}
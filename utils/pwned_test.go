@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// pwnedHash returns the uppercase SHA-1 hex the corpus is keyed by.
+func pwnedHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// pwnedFixture is a small corpus: the passwords "breached0" to "breached199"
+// seen i+1 times, plus entries next to the hash of "breached0" so lookups at
+// the edge of its bucket and line are tested.
+func pwnedFixture() map[string]int {
+	corpus := map[string]int{}
+	for i := 0; i < 200; i++ {
+		corpus[pwnedHash(fmt.Sprintf("breached%d", i))] = i + 1
+	}
+	prefix := pwnedHash("breached0")[:pwnedPrefixLength]
+	corpus[prefix+strings.Repeat("0", 35)] = 9001
+	corpus[prefix+strings.Repeat("F", 35)] = 9002
+	// the hash of "unbreached" shares a bucket with a corpus entry
+	missing := pwnedHash("unbreached")
+	corpus[missing[:pwnedPrefixLength]+strings.Repeat("0", 35)] = 9003
+	return corpus
+}
+
+// writePwnedFile writes the corpus as one file of HASH:COUNT lines sorted by
+// hash, joined by newline.
+func writePwnedFile(t *testing.T, corpus map[string]int, newline string, final bool) string {
+	hashes := make([]string, 0, len(corpus))
+	for hash := range corpus {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+	lines := make([]string, len(hashes))
+	for i, hash := range hashes {
+		lines[i] = fmt.Sprintf("%s:%d", hash, corpus[hash])
+	}
+	content := strings.Join(lines, newline)
+	if final {
+		content += newline
+	}
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// writePwnedBuckets writes the corpus as a directory of prefix bucket files
+// holding SUFFIX:COUNT lines, as the downloader does.
+func writePwnedBuckets(t *testing.T, corpus map[string]int) string {
+	buckets := map[string][]string{}
+	for hash, count := range corpus {
+		prefix := hash[:pwnedPrefixLength]
+		buckets[prefix] = append(buckets[prefix], fmt.Sprintf("%s:%d\r\n", hash[pwnedPrefixLength:], count))
+	}
+	dir := t.TempDir()
+	for prefix, lines := range buckets {
+		sort.Strings(lines)
+		err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(strings.Join(lines, "")), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestPwnedPasswordsCount(t *testing.T) {
+	corpus := pwnedFixture()
+	layouts := []struct {
+		name string
+		path string
+	}{
+		{"sorted file", writePwnedFile(t, corpus, "\n", true)},
+		{"sorted file with CRLF and no final newline", writePwnedFile(t, corpus, "\r\n", false)},
+		{"bucket directory", writePwnedBuckets(t, corpus)},
+	}
+	for _, layout := range layouts {
+		t.Run(layout.name, func(t *testing.T) {
+			pwned, err := OpenPwnedPasswords(layout.path)
+			if err != nil {
+				t.Fatalf("OpenPwnedPasswords: %v", err)
+			}
+			defer pwned.Close()
+
+			// every line is found by its hash, the first and last lines
+			// and those at the edges of a bucket included
+			for hash, want := range corpus {
+				var count int
+				if pwned.dir != "" {
+					count, err = pwned.countInBucket(hash)
+				} else {
+					count, err = pwned.countInSortedFile(hash)
+				}
+				if err != nil || count != want {
+					t.Errorf("lookup of %s = %d, %v, want %d", hash, count, err, want)
+				}
+			}
+
+			for i := 0; i < 200; i++ {
+				password := fmt.Sprintf("breached%d", i)
+				count, err := pwned.Count(password)
+				if err != nil || count != i+1 {
+					t.Errorf("Count(%q) = %d, %v, want %d", password, count, err, i+1)
+				}
+			}
+			for _, password := range []string{"unbreached", "", "breached200", "Breached0"} {
+				count, err := pwned.Count(password)
+				if err != nil || count != 0 {
+					t.Errorf("Count(%q) = %d, %v, want 0", password, count, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPwnedPasswordsMalformed(t *testing.T) {
+	hash := pwnedHash("breached0")
+	tests := []struct {
+		name  string
+		lines string
+	}{
+		{"no count", hash + "\n"},
+		{"count not a number", hash + ":many\n"},
+		{"line too long", hash + ":" + strings.Repeat("1", 2*pwnedMaxLine) + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "pwned.txt")
+			err := os.WriteFile(path, []byte(test.lines), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pwned, err := OpenPwnedPasswords(path)
+			if err != nil {
+				t.Fatalf("OpenPwnedPasswords: %v", err)
+			}
+			defer pwned.Close()
+			_, err = pwned.Count("breached0")
+			if err == nil {
+				t.Error("Count of a malformed sorted file succeeded")
+			}
+
+			dir := t.TempDir()
+			err = os.WriteFile(filepath.Join(dir, hash[:pwnedPrefixLength]+".txt"), []byte(test.lines[pwnedPrefixLength:]), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			buckets, err := OpenPwnedPasswords(dir)
+			if err != nil {
+				t.Fatalf("OpenPwnedPasswords: %v", err)
+			}
+			_, err = buckets.Count("breached0")
+			if err == nil {
+				t.Error("Count of a malformed bucket succeeded")
+			}
+		})
+	}
+}
+
+func TestPwnedPasswordsCheckedByPolicy(t *testing.T) {
+	pwned, err := OpenPwnedPasswords(writePwnedBuckets(t, map[string]int{pwnedHash("Lantern-Orbit-57"): 12}))
+	if err != nil {
+		t.Fatalf("OpenPwnedPasswords: %v", err)
+	}
+	policy := DefaultPasswordPolicy()
+	policy.Breached = pwned
+
+	errs := policy.Check("alice", "Lantern-Orbit-57")
+	if len(errs) != 1 || errs[0].Rule != RuleBreached {
+		t.Errorf("Check of a breached password = %v, want only %s", errs, RuleBreached)
+	}
+	if errs := policy.Check("alice", "Lantern-Orbit-58"); errs != nil {
+		t.Errorf("Check of an unbreached password = %v, want nil", errs)
+	}
+
+	_, err = OpenPwnedPasswords(filepath.Join(t.TempDir(), "missing"))
+	if err == nil {
+		t.Error("OpenPwnedPasswords of a missing path succeeded")
+	}
+}
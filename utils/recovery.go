@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/rand"
+	"strings"
+)
+
+// recoveryAlphabet leaves out characters that are easy to misread on paper,
+// such as 0/o and 1/l.
+const recoveryAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// GenerateRecoveryCodes returns count random single-use recovery codes, each
+// formatted as two groups of five characters, e.g. "k7m2p-x9qrt".
+func GenerateRecoveryCodes(count int) []string {
+	// bytes at or above this are skipped so every character is equally likely
+	limit := 256 - 256%len(recoveryAlphabet)
+
+	codes := make([]string, count)
+	buf := make([]byte, 1)
+	for i := range codes {
+		var code strings.Builder
+		for code.Len() < 11 {
+			if code.Len() == 5 {
+				code.WriteByte('-')
+				continue
+			}
+			if _, err := rand.Read(buf); err != nil {
+				panic("could not generate recovery code: " + err.Error())
+			}
+			if int(buf[0]) >= limit {
+				continue
+			}
+			code.WriteByte(recoveryAlphabet[int(buf[0])%len(recoveryAlphabet)])
+		}
+		codes[i] = code.String()
+	}
+	return codes
+}
+
+// NormalizeRecoveryCode puts a recovery code typed by a user into the form it
+// was hashed in, ignoring case, spaces and the separating hyphen.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	return code
+}
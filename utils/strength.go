@@ -0,0 +1,297 @@
+package utils
+
+import (
+	_ "embed"
+	"math"
+	"strings"
+	"unicode"
+)
+
+//go:embed common_passwords.txt
+var commonPasswordList string
+
+// commonPasswords ranks the embedded list of the most used passwords, 1 being
+// the most common. It is both the default deny-list and the dictionary the
+// strength estimate looks words up in.
+var commonPasswords = func() map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(commonPasswordList) {
+		word = strings.ToLower(word)
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}()
+
+// keyboardRows are the rows of a QWERTY keyboard, walked by patterns like
+// "asdf" or "poiuy".
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// leetSubstitutions undoes common character swaps such as "p@ssw0rd".
+var leetSubstitutions = map[rune]rune{
+	'0': 'o', '1': 'i', '3': 'e', '4': 'a', '5': 's', '7': 't', '8': 'b', '@': 'a', '$': 's', '!': 'i',
+}
+
+const maxMatchLength = 32 // longest substring looked up as a word
+
+// Strength scores returned by PasswordStrength, in the style of zxcvbn.
+const (
+	StrengthTooGuessable   = 0 // under 10^3 guesses
+	StrengthVeryGuessable  = 1 // under 10^6 guesses
+	StrengthSomewhatSecure = 2 // under 10^8 guesses
+	StrengthSafelySecure   = 3 // under 10^10 guesses
+	StrengthVerySecure     = 4
+)
+
+// passwordMatch is a guessable pattern found in runes[start:end] of a
+// password, such as a common word or a keyboard walk.
+type passwordMatch struct {
+	start, end int
+	guesses    float64 // log10 of the guesses needed to find the pattern
+}
+
+/*
+PasswordStrength scores how hard the password is to guess, in the spirit of
+zxcvbn: the password is split into the cheapest sequence of guessable
+patterns (common passwords, leet and reversed words, repeats, sequences like
+"abc" or "987", keyboard walks, years and the user's own details), with any
+remaining characters brute-forced.
+
+Returns:
+
+- int: A score from StrengthTooGuessable (0) to StrengthVerySecure (4).
+*/
+func PasswordStrength(password string, userInputs ...string) int {
+	guesses := estimateGuesses(password, userInputs)
+	switch {
+	case guesses < 3:
+		return StrengthTooGuessable
+	case guesses < 6:
+		return StrengthVeryGuessable
+	case guesses < 8:
+		return StrengthSomewhatSecure
+	case guesses < 10:
+		return StrengthSafelySecure
+	default:
+		return StrengthVerySecure
+	}
+}
+
+// estimateGuesses returns log10 of the guesses an attacker trying the most
+// likely patterns first needs to find the password.
+func estimateGuesses(password string, userInputs []string) float64 {
+	runes := []rune(password)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	// the user's own details are the first thing an attacker tries
+	dictionary := func(word string) (int, bool) {
+		for _, input := range userInputs {
+			if len(input) >= 3 && strings.EqualFold(word, input) {
+				return 1, true
+			}
+		}
+		rank, ok := commonPasswords[word]
+		return rank, ok
+	}
+
+	matches := make(map[int][]passwordMatch) // end -> matches ending there
+	add := func(start, end int, guesses float64) {
+		matches[end] = append(matches[end], passwordMatch{start: start, end: end, guesses: math.Log10(guesses)})
+	}
+	dictionaryMatches(runes, lower, dictionary, add)
+	repeatMatches(lower, add)
+	sequenceMatches(lower, add)
+	keyboardMatches(lower, add)
+	yearMatches(runes, add)
+
+	// cheapest way to guess each prefix, one brute-forced character at a
+	// time or one pattern at a time
+	perChar := math.Log10(float64(charsetSize(runes)))
+	best := make([]float64, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		best[end] = best[end-1] + perChar
+		for _, m := range matches[end] {
+			best[end] = math.Min(best[end], best[m.start]+m.guesses)
+		}
+	}
+	return best[len(runes)]
+}
+
+// dictionaryMatches finds common passwords and user details, as written,
+// reversed or with leet substitutions undone.
+func dictionaryMatches(runes, lower []rune, dictionary func(string) (int, bool), add func(int, int, float64)) {
+	for start := range lower {
+		for end := start + 3; end <= len(lower) && end-start <= maxMatchLength; end++ {
+			word := string(lower[start:end])
+			caseGuesses := caseVariations(runes[start:end])
+			if rank, ok := dictionary(word); ok {
+				add(start, end, float64(rank)*caseGuesses)
+			}
+			if rank, ok := dictionary(reverse(word)); ok {
+				add(start, end, float64(rank)*caseGuesses*2)
+			}
+			if unleeted, subs := unleet(lower[start:end]); subs > 0 {
+				if rank, ok := dictionary(unleeted); ok {
+					add(start, end, float64(rank)*caseGuesses*math.Pow(2, float64(subs)))
+				}
+			}
+		}
+	}
+}
+
+// repeatMatches finds runs of a repeated block, such as "aaaa" or "abcabc".
+func repeatMatches(lower []rune, add func(int, int, float64)) {
+	for start := range lower {
+		for size := 1; size <= (len(lower)-start)/2; size++ {
+			block := string(lower[start : start+size])
+			count := 1
+			for next := start + size; next+size <= len(lower) && string(lower[next:next+size]) == block; next += size {
+				count++
+			}
+			if count >= 2 && count*size >= 3 {
+				blockGuesses := math.Pow(float64(charsetSize(lower[start:start+size])), float64(size))
+				if rank, ok := commonPasswords[block]; ok {
+					blockGuesses = math.Min(blockGuesses, float64(rank))
+				}
+				add(start, start+count*size, blockGuesses*float64(count))
+			}
+		}
+	}
+}
+
+// sequenceMatches finds runs of consecutive characters such as "abcd" or
+// "9876".
+func sequenceMatches(lower []rune, add func(int, int, float64)) {
+	for start := 0; start+2 < len(lower); {
+		delta := lower[start+1] - lower[start]
+		end := start + 1
+		for end < len(lower) && (delta == 1 || delta == -1) && lower[end]-lower[end-1] == delta {
+			end++
+		}
+		if end-start < 3 {
+			start++
+			continue
+		}
+
+		// obvious starting points are tried first
+		startGuesses := 26.0
+		switch first := lower[start]; {
+		case first == 'a' || first == 'z' || first == '0' || first == '1' || first == '9':
+			startGuesses = 4
+		case unicode.IsDigit(first):
+			startGuesses = 10
+		}
+		if delta < 0 {
+			startGuesses *= 2
+		}
+		add(start, end, startGuesses*float64(end-start))
+		start = end - 1
+	}
+}
+
+// keyboardMatches finds walks along a keyboard row such as "qwer" or "lkjh".
+func keyboardMatches(lower []rune, add func(int, int, float64)) {
+	for _, row := range keyboardRows {
+		for _, walk := range []string{row, reverse(row)} {
+			for start := range lower {
+				pos := strings.IndexRune(walk, lower[start])
+				if pos < 0 {
+					continue
+				}
+				end := start + 1
+				for end < len(lower) && pos+end-start < len(walk) && rune(walk[pos+end-start]) == lower[end] {
+					end++
+				}
+				if end-start >= 4 {
+					add(start, end, 50*float64(end-start))
+				}
+			}
+		}
+	}
+}
+
+// yearMatches finds recent years such as "1987", which are often birth
+// years.
+func yearMatches(runes []rune, add func(int, int, float64)) {
+	for start := 0; start+4 <= len(runes); start++ {
+		year := string(runes[start : start+4])
+		if year >= "1900" && year <= "2099" && strings.IndexFunc(year, func(r rune) bool { return !unicode.IsDigit(r) }) < 0 {
+			add(start, start+4, 120)
+		}
+	}
+}
+
+// charsetSize estimates how many characters an attacker must try for each
+// position, from the kinds of characters used.
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	size := 0
+	for _, kind := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if kind.used {
+			size += kind.size
+		}
+	}
+	return max(size, 1)
+}
+
+// caseVariations is the number of capitalisations an attacker tries for a
+// word: all lower or all upper or only the first letter upper are cheap,
+// anything else costs more.
+func caseVariations(runes []rune) float64 {
+	word := string(runes)
+	switch {
+	case word == strings.ToLower(word), word == strings.ToUpper(word):
+		return 1
+	case unicode.IsUpper(runes[0]) && string(runes[1:]) == strings.ToLower(string(runes[1:])):
+		return 2
+	default:
+		return 16
+	}
+}
+
+// unleet undoes leet substitutions, returning the word and how many
+// characters were swapped back.
+func unleet(runes []rune) (string, int) {
+	out := make([]rune, len(runes))
+	subs := 0
+	for i, r := range runes {
+		if plain, ok := leetSubstitutions[r]; ok {
+			out[i] = plain
+			subs++
+			continue
+		}
+		out[i] = r
+	}
+	return string(out), subs
+}
+
+// reverse returns the string with its characters in reverse order.
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
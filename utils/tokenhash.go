@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/Bevs-n-Devs/WebAuthentication/logs"
+)
+
+const (
+	logWarning = 2
+	logErr     = 3
+)
+
+// ErrNoTokenSecret is returned by CheckTokenSecret when TOKEN_SECRET is unset.
+var ErrNoTokenSecret = errors.New("TOKEN_SECRET is not set")
+
+var (
+	tokenKey     []byte
+	tokenKeyOnce sync.Once
+)
+
+// loadTokenKey reads the token hashing secret from the TOKEN_SECRET
+// environment variable. Without it a random key is used, so stored tokens
+// stop matching whenever the server restarts.
+func loadTokenKey() {
+	secret := os.Getenv("TOKEN_SECRET")
+	if secret != "" {
+		tokenKey = []byte(secret)
+		return
+	}
+
+	logs.Logs(logWarning, "TOKEN_SECRET is not set. Using a random key, sessions will not survive a restart.")
+	tokenKey = make([]byte, 32)
+	if _, err := rand.Read(tokenKey); err != nil {
+		panic("could not generate token key: " + err.Error())
+	}
+}
+
+// CheckTokenSecret returns ErrNoTokenSecret unless TOKEN_SECRET is set. A
+// store that outlives the process needs it: recovery codes are kept for good
+// as hashes under this key, and would all stop matching under a random one.
+func CheckTokenSecret() error {
+	if os.Getenv("TOKEN_SECRET") == "" {
+		return ErrNoTokenSecret
+	}
+	return nil
+}
+
+// HashToken returns the hex encoded HMAC-SHA256 of the token keyed with the
+// server secret. Session and CSRF tokens are stored in this form so a leaked
+// database does not hand out live sessions.
+func HashToken(token string) string {
+	tokenKeyOnce.Do(loadTokenKey)
+
+	mac := hmac.New(sha256.New, tokenKey)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,14 @@
+package utils
+
+import "testing"
+
+func TestCheckTokenSecret(t *testing.T) {
+	t.Setenv("TOKEN_SECRET", "")
+	if err := CheckTokenSecret(); err != ErrNoTokenSecret {
+		t.Fatalf("CheckTokenSecret without a secret = %v, want ErrNoTokenSecret", err)
+	}
+	t.Setenv("TOKEN_SECRET", "a long random secret")
+	if err := CheckTokenSecret(); err != nil {
+		t.Fatalf("CheckTokenSecret with a secret = %v", err)
+	}
+}
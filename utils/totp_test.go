@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of RFC 6238 Appendix B, "12345678901234567890",
+// base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// the RFC lists 8-digit codes, the last 6 digits are the 6-digit ones
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, test := range tests {
+		want := test.code[2:]
+		got, err := TOTPCode(rfc6238Secret, time.Unix(test.unix, 0))
+		if err != nil || got != want {
+			t.Errorf("TOTPCode at %d = %q, %v, want %q", test.unix, got, err, want)
+		}
+		// secrets are accepted in lower case too
+		if step, ok := ValidateTOTP(strings.ToLower(rfc6238Secret), want, time.Unix(test.unix, 0)); !ok || step != test.unix/30 {
+			t.Errorf("ValidateTOTP at %d = %d, %t, want step %d", test.unix, step, ok, test.unix/30)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := TOTPCode(rfc6238Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		offset time.Duration
+		ok     bool
+	}{
+		{"same period", 0, true},
+		{"checked a period later", 30 * time.Second, true},
+		{"checked a period earlier", -30 * time.Second, true},
+		{"checked two periods later", 60 * time.Second, false},
+		{"checked two periods earlier", -60 * time.Second, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// the matched step is the code's, so replays can be told apart
+			step, ok := ValidateTOTP(rfc6238Secret, code, now.Add(test.offset))
+			if ok != test.ok || (ok && step != now.Unix()/30) {
+				t.Fatalf("got step %d, %t, want %t with step %d", step, ok, test.ok, now.Unix()/30)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPMalformed(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	code, err := TOTPCode(rfc6238Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, code[:3]+" "+code[3:], now); !ok {
+		t.Error("a code typed with a space in the middle was refused")
+	}
+	for _, code := range []string{"", "12345", "1234567", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now); ok {
+			t.Errorf("code %q was accepted", code)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", code, now); ok {
+		t.Error("a malformed secret accepted a code")
+	}
+}
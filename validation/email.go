@@ -0,0 +1,31 @@
+package validation
+
+import (
+	"net/mail"
+	"strings"
+)
+
+/*
+Email checks that address is a bare email address, such as
+"user@example.com", and lowercases it so each address can only be registered
+once.
+
+Returns:
+
+- string: The normalized address.
+
+- Errors: The rules the address breaks, nil if it is valid.
+*/
+func Email(address string) (string, Errors) {
+	address = strings.TrimSpace(address)
+	parsed, err := mail.ParseAddress(address)
+	// reject display names and comments, only the address itself is wanted
+	if err != nil || parsed.Address != address {
+		return "", Errors{{
+			Field:   "email",
+			Rule:    "format",
+			Message: "Please enter a valid email address.",
+		}}
+	}
+	return strings.ToLower(address), nil
+}
@@ -0,0 +1,28 @@
+// Package validation checks and normalizes user input from forms, reporting
+// every rule broken as an Error the handlers can render next to the form.
+package validation
+
+import "strings"
+
+// Error is one rule a form field breaks, with a message to show the user.
+type Error struct {
+	Field   string // form field, such as "username"
+	Rule    string // rule broken, such as "min_length"
+	Message string
+}
+
+func (e Error) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// Errors lists every rule a form breaks. A nil Errors means the input is
+// valid.
+type Errors []Error
+
+func (e Errors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
@@ -0,0 +1,137 @@
+package validation
+
+import (
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// scriptGroups are combinations of scripts that are written together, so a
+// name using several of them is not mixed-script.
+var scriptGroups = [][]string{
+	{"Han", "Hiragana", "Katakana"}, // Japanese
+	{"Han", "Hangul"},               // Korean
+	{"Han", "Bopomofo"},             // Chinese with phonetic annotation
+}
+
+// confusables maps letters of other scripts, and Latin letters that read as
+// others, to the ASCII letter they are easily mistaken for. It covers what is
+// needed to spell the reserved names, not all of Unicode's confusables list.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'һ': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j',
+	'к': 'k', 'ӏ': 'l', 'м': 'm', 'н': 'h', 'о': 'o', 'р': 'p', 'с': 'c', 'т': 't',
+	'у': 'y', 'х': 'x', 'ѕ': 's', 'ԁ': 'd', 'ԛ': 'q', 'ԝ': 'w', 'п': 'n',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'γ': 'y', 'ω': 'w',
+	// Latin look-alikes
+	'ı': 'i', 'ȷ': 'j', 'ɡ': 'g', 'ɑ': 'a', 'ɩ': 'i', 'ʀ': 'r', 'ʏ': 'y', 'ᴅ': 'd', 'ᴍ': 'm',
+	'ɴ': 'n', 'ᴏ': 'o', 'ꜱ': 's', 'ᴛ': 't', 'ᴜ': 'u', 'ᴠ': 'v', 'ᴡ': 'w', 'ℓ': 'l',
+	// digits
+	'0': 'o', '1': 'l',
+}
+
+// scripts are the scripts letters are told apart by, most used first so
+// common names are placed after a check or two. Letters of any other script
+// count as one script, "Other".
+var scripts = []struct {
+	name  string
+	table *unicode.RangeTable
+}{
+	{"Latin", unicode.Latin},
+	{"Han", unicode.Han},
+	{"Cyrillic", unicode.Cyrillic},
+	{"Arabic", unicode.Arabic},
+	{"Devanagari", unicode.Devanagari},
+	{"Hiragana", unicode.Hiragana},
+	{"Katakana", unicode.Katakana},
+	{"Hangul", unicode.Hangul},
+	{"Greek", unicode.Greek},
+	{"Hebrew", unicode.Hebrew},
+	{"Thai", unicode.Thai},
+	{"Bengali", unicode.Bengali},
+	{"Tamil", unicode.Tamil},
+	{"Armenian", unicode.Armenian},
+	{"Georgian", unicode.Georgian},
+	{"Ethiopic", unicode.Ethiopic},
+	{"Bopomofo", unicode.Bopomofo},
+}
+
+/*
+scriptOf returns the name of the script a letter belongs to, "Other" for
+letters of scripts not listed in scripts, or "" for characters shared
+between scripts, such as digits and separators.
+
+Returns:
+
+- string: The script name, as used by unicode.Scripts.
+*/
+func scriptOf(r rune) string {
+	if unicode.In(r, unicode.Common, unicode.Inherited) {
+		return ""
+	}
+	for _, script := range scripts {
+		if unicode.Is(script.table, r) {
+			return script.name
+		}
+	}
+	return "Other"
+}
+
+/*
+mixedScript reports whether a name uses letters of more than one script, as
+in "pаypal" with a Cyrillic "а", other than combinations written together
+such as Japanese kanji and kana.
+
+Returns:
+
+- bool: true if the name mixes scripts.
+*/
+func mixedScript(name string) bool {
+	scripts := map[string]bool{}
+	for _, r := range name {
+		if script := scriptOf(r); script != "" {
+			scripts[script] = true
+		}
+	}
+	if len(scripts) <= 1 {
+		return false
+	}
+	for _, group := range scriptGroups {
+		covered := 0
+		for _, script := range group {
+			if scripts[script] {
+				covered++
+			}
+		}
+		if covered == len(scripts) {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+skeleton maps a name to what it looks like, so names that only differ in
+look-alike characters get the same skeleton: accents are dropped and
+confusable letters replaced by the ASCII letter they resemble. "аdmіn" in
+Cyrillic and "ádmin" have the skeleton "admin", "r00t" that of "root".
+
+Returns:
+
+- string: The skeleton of the name.
+*/
+func skeleton(name string) string {
+	var out []rune
+	for _, r := range norm.NFD.String(name) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if ascii, ok := confusables[r]; ok {
+			r = ascii
+		}
+		out = append(out, r)
+	}
+	return string(out)
+}
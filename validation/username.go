@@ -0,0 +1,133 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/secure/precis"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 32
+)
+
+// usernameSeparators may appear inside a username but not at either end.
+const usernameSeparators = "._-"
+
+// ReservedUsernames cannot be registered, so nobody can pose as the site or
+// its staff. Names are compared by their skeleton with separators removed,
+// so "Admin", "ad.min", "r00t" and a Cyrillic "аdmin" are refused too.
+var ReservedUsernames = map[string]bool{
+	"abuse": true, "account": true, "admin": true, "administrator": true, "anonymous": true,
+	"api": true, "dashboard": true, "guest": true, "help": true, "hostmaster": true,
+	"info": true, "login": true, "logout": true, "mail": true, "moderator": true,
+	"noreply": true, "null": true, "owner": true, "postmaster": true, "root": true,
+	"security": true, "staff": true, "support": true, "system": true, "undefined": true,
+	"webmaster": true, "www": true,
+}
+
+/*
+Username checks a username for registration and returns its canonical form,
+which is the form to store and compare. Canonicalization follows the PRECIS
+UsernameCaseMapped profile (RFC 8265): full-width characters are narrowed,
+the name is case-folded and NFC-normalized, so "Alice", "ALICE" and "ａｌｉｃｅ"
+are the same user. Names mixing letters of different scripts are refused, and
+the store refuses names with the UsernameSkeleton of an existing user, so
+nobody can register a look-alike of another user's name.
+
+Returns:
+
+- string: The canonical username.
+
+- Errors: The rules the username breaks, nil if it can be registered.
+*/
+func Username(name string) (string, Errors) {
+	canonical, err := precis.UsernameCaseMapped.String(strings.TrimSpace(name))
+	if err != nil {
+		return "", Errors{{
+			Field:   "username",
+			Rule:    "charset",
+			Message: "Usernames can only contain letters, digits, dots, hyphens and underscores.",
+		}}
+	}
+
+	var errs Errors
+	length := utf8.RuneCountInString(canonical)
+	if length < MinUsernameLength || length > MaxUsernameLength {
+		errs = append(errs, Error{
+			Field:   "username",
+			Rule:    "length",
+			Message: fmt.Sprintf("Usernames must be between %d and %d characters long.", MinUsernameLength, MaxUsernameLength),
+		})
+	}
+
+	// PRECIS allows most printable ASCII, narrow it down to what reads as a name
+	for _, r := range canonical {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(usernameSeparators, r) {
+			errs = append(errs, Error{
+				Field:   "username",
+				Rule:    "charset",
+				Message: "Usernames can only contain letters, digits, dots, hyphens and underscores.",
+			})
+			break
+		}
+	}
+	if canonical != "" && (strings.ContainsRune(usernameSeparators, rune(canonical[0])) ||
+		strings.ContainsRune(usernameSeparators, rune(canonical[len(canonical)-1]))) {
+		errs = append(errs, Error{
+			Field:   "username",
+			Rule:    "separator",
+			Message: "Usernames must start and end with a letter or digit.",
+		})
+	}
+
+	if mixedScript(canonical) {
+		errs = append(errs, Error{
+			Field:   "username",
+			Rule:    "script",
+			Message: "Usernames cannot mix letters from different alphabets.",
+		})
+	}
+
+	if ReservedUsernames[UsernameSkeleton(canonical)] {
+		errs = append(errs, Error{
+			Field:   "username",
+			Rule:    "reserved",
+			Message: "That username is reserved. Please choose another one.",
+		})
+	}
+
+	if errs != nil {
+		return "", errs
+	}
+	return canonical, nil
+}
+
+// UsernameSkeleton returns what a canonical username looks like, with
+// look-alike letters replaced and separators removed. Names that are easily
+// mistaken for each other, such as "paypal" and an all-Cyrillic "раураӏ", or
+// "alice" and "a.lice", share a skeleton, so only one of them may be
+// registered.
+func UsernameSkeleton(canonical string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(usernameSeparators, r) {
+			return -1
+		}
+		return r
+	}, skeleton(canonical))
+}
+
+// CanonicalUsername maps a username typed into a form, such as at login, to
+// the form it was stored in. Names that could never have been registered are
+// returned trimmed but otherwise as typed, so they simply match no user.
+func CanonicalUsername(name string) string {
+	name = strings.TrimSpace(name)
+	canonical, err := precis.UsernameCaseMapped.String(name)
+	if err != nil {
+		return name
+	}
+	return canonical
+}
@@ -0,0 +1,145 @@
+package validation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUsername(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string   // canonical form, if accepted
+		rules []string // rules broken, if refused
+	}{
+		{"ascii", "alice", "alice", nil},
+		{"case folded", "Alice", "alice", nil},
+		{"unicode case folded", "ÄRGER", "ärger", nil},
+		{"greek case folded", "ΩΜΕΓΑ", "ωμεγα", nil},
+		{"full-width mapped", "ＡＢＣ", "abc", nil},
+		{"spaces trimmed", "  bob  ", "bob", nil},
+		{"separators inside", "j.doe_2-x", "j.doe_2-x", nil},
+		{"japanese kanji and kana", "山田たろう", "山田たろう", nil},
+		{"korean hangul and hanja", "김金이", "김金이", nil},
+		{"cyrillic alone", "иван", "иван", nil},
+		{"digits with any script", "иван99", "иван99", nil},
+		{"minimum length", "abc", "abc", nil},
+		{"maximum length", strings.Repeat("a", MaxUsernameLength), strings.Repeat("a", MaxUsernameLength), nil},
+		{"too short", "ab", "", []string{"length"}},
+		{"full-width too short", "ＡＢ", "", []string{"length"}},
+		{"too long", strings.Repeat("a", MaxUsernameLength+1), "", []string{"length"}},
+		{"empty", "", "", []string{"length"}},
+		{"space inside", "al ice", "", []string{"charset"}},
+		{"symbol", "alice!", "", []string{"charset"}},
+		{"leading separator", ".alice", "", []string{"separator"}},
+		{"trailing separator", "alice-", "", []string{"separator"}},
+		{"latin with cyrillic", "pаypal", "", []string{"script"}},
+		{"latin with greek", "αlice", "", []string{"script"}},
+		{"cyrillic with greek", "иванα", "", []string{"script"}},
+		{"reserved", "admin", "", []string{"reserved"}},
+		{"reserved capitalized", "Admin", "", []string{"reserved"}},
+		{"reserved with separators", "ad.min", "", []string{"reserved"}},
+		{"reserved with digits for letters", "r00t", "", []string{"reserved"}},
+		{"reserved with accents", "ádmin", "", []string{"reserved"}},
+		{"reserved in cyrillic", "аdmіn", "", []string{"script", "reserved"}},
+		{"reserved all cyrillic", "аԁміп", "", []string{"reserved"}},
+		{"reserved full-width", "ＲＯＯＴ", "", []string{"reserved"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			canonical, errs := Username(test.input)
+			var rules []string
+			for _, err := range errs {
+				rules = append(rules, err.Rule)
+			}
+			if canonical != test.want || !reflect.DeepEqual(rules, test.rules) {
+				t.Errorf("Username(%q) = %q, %v, want %q, %v", test.input, canonical, rules, test.want, test.rules)
+			}
+		})
+	}
+}
+
+func TestCanonicalUsername(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"alice", "alice"},
+		{"ALICE", "alice"},
+		{" Alice ", "alice"},
+		{"ÄRGER", "ärger"},
+		{"Ärger", "ärger"},
+		{"ＡＢＣ", "abc"},
+		{"ａｌｉｃｅ", "alice"},
+		{"ΣΙΣΥΦΟΣ", "σισυφοσ"},
+		// names that could never be registered stay as typed
+		{"Al ice", "Al ice"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := CanonicalUsername(test.input); got != test.want {
+			t.Errorf("CanonicalUsername(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+
+	// every accepted name is already canonical, so logins find it
+	for _, input := range []string{"Alice", "ＡＢＣ", "ÄRGER", "山田たろう"} {
+		canonical, errs := Username(input)
+		if errs != nil || CanonicalUsername(input) != canonical || CanonicalUsername(canonical) != canonical {
+			t.Errorf("Username(%q) = %q, %v, which CanonicalUsername does not agree with", input, canonical, errs)
+		}
+	}
+}
+
+func TestUsernameSkeleton(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		// an all-Cyrillic name passes the mixed-script check on its own
+		{"paypal", "раураӏ", true},
+		{"alice", "a.lice", true},
+		{"bob1", "bobl", true},
+		{"rene", "rené", true},
+		{"alice", "alicia", false},
+		{"bob", "rob", false},
+	}
+	for _, test := range tests {
+		a, errs := Username(test.a)
+		if errs != nil {
+			t.Fatalf("Username(%q): %v", test.a, errs)
+		}
+		b, errs := Username(test.b)
+		if errs != nil {
+			t.Fatalf("Username(%q): %v", test.b, errs)
+		}
+		if same := UsernameSkeleton(a) == UsernameSkeleton(b); same != test.same {
+			t.Errorf("UsernameSkeleton(%q) == UsernameSkeleton(%q) is %t, want %t", a, b, same, test.same)
+		}
+	}
+}
+
+func TestScriptOf(t *testing.T) {
+	tests := []struct {
+		r    rune
+		want string
+	}{
+		{'a', "Latin"},
+		{'ä', "Latin"},
+		{'а', "Cyrillic"},
+		{'α', "Greek"},
+		{'山', "Han"},
+		{'た', "Hiragana"},
+		{'א', "Hebrew"},
+		{'7', ""},
+		{'_', ""},
+		{'́', ""},      // combining accent
+		{'ᚠ', "Other"}, // Runic
+	}
+	for _, test := range tests {
+		if got := scriptOf(test.r); got != test.want {
+			t.Errorf("scriptOf(%q) = %q, want %q", test.r, got, test.want)
+		}
+	}
+}
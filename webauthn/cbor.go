@@ -0,0 +1,226 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// maxCBORDepth bounds nesting so a hostile payload cannot exhaust the stack.
+const maxCBORDepth = 16
+
+var errCBOR = errors.New("webauthn: malformed CBOR")
+
+/*
+decodeCBOR decodes the first CBOR data item in data. It supports the subset of
+CBOR used by WebAuthn: integers, byte and text strings, arrays, maps, tags,
+booleans, null and floats. Indefinite length items are rejected, as
+authenticators must use the canonical encoding.
+
+Integers decode to int64, byte strings to []byte, text to string, arrays to
+[]interface{} and maps to map[interface{}]interface{}.
+
+Returns:
+
+- interface{}: The decoded item.
+
+- int: The number of bytes the item took up, so callers can find data that
+follows it.
+
+- error: An error if the item is malformed or truncated.
+*/
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	d := cborDecoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, 0, err
+	}
+	return value, d.pos, nil
+}
+
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// next returns the following n bytes and advances past them.
+func (d *cborDecoder) next(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, errCBOR
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// argument reads the argument that follows an initial byte with the given
+// additional information.
+func (d *cborDecoder) argument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info == 24:
+		b, err := d.next(1)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(b[0]), nil
+	case info == 25:
+		b, err := d.next(2)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case info == 26:
+		b, err := d.next(4)
+		if err != nil {
+			return 0, err
+		}
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	case info == 27:
+		b, err := d.next(8)
+		if err != nil {
+			return 0, err
+		}
+		return binary.BigEndian.Uint64(b), nil
+	default:
+		return 0, errCBOR
+	}
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errCBOR
+	}
+	b, err := d.next(1)
+	if err != nil {
+		return nil, err
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+
+	// simple values and floats use the argument bits differently
+	if major == 7 {
+		return d.decodeSimple(info)
+	}
+
+	arg, err := d.argument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return int64(arg), nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return -1 - int64(arg), nil
+	case 2:
+		raw, err := d.next(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case 3:
+		raw, err := d.next(arg)
+		if err != nil {
+			return nil, err
+		}
+		return string(raw), nil
+	case 4:
+		if arg > uint64(len(d.data)) {
+			return nil, errCBOR
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if arg > uint64(len(d.data)) {
+			return nil, errCBOR
+		}
+		entries := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, errCBOR // only integer and text keys are hashable here
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			entries[key] = value
+		}
+		return entries, nil
+	default: // 6, a tag: the tagged item is returned as is
+		return d.decode(depth + 1)
+	}
+}
+
+func (d *cborDecoder) decodeSimple(info byte) (interface{}, error) {
+	switch info {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23:
+		return nil, nil
+	case 25:
+		b, err := d.next(2)
+		if err != nil {
+			return nil, err
+		}
+		return float16(binary.BigEndian.Uint16(b)), nil
+	case 26:
+		b, err := d.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 27:
+		b, err := d.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	default:
+		return nil, errCBOR
+	}
+}
+
+// float16 converts an IEEE 754 half precision value to a float64.
+func float16(bits uint16) float64 {
+	exp := int(bits>>10) & 0x1f
+	mant := float64(bits & 0x3ff)
+	var value float64
+	switch exp {
+	case 0:
+		value = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mant+1024, exp-25)
+	}
+	if bits&0x8000 != 0 {
+		value = -value
+	}
+	return value
+}
@@ -0,0 +1,146 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// cborMap is a CBOR map with its entries in a fixed order, as authenticators
+// encode them.
+type cborMap []struct{ key, value interface{} }
+
+// cborHead encodes the initial byte and argument of a data item.
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= math.MaxUint8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+	}
+}
+
+// encodeCBOR encodes the subset of CBOR the tests need.
+func encodeCBOR(value interface{}) []byte {
+	switch v := value.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case []interface{}:
+		out := cborHead(4, uint64(len(v)))
+		for _, item := range v {
+			out = append(out, encodeCBOR(item)...)
+		}
+		return out
+	case cborMap:
+		out := cborHead(5, uint64(len(v)))
+		for _, entry := range v {
+			out = append(out, encodeCBOR(entry.key)...)
+			out = append(out, encodeCBOR(entry.value)...)
+		}
+		return out
+	}
+	panic("encodeCBOR: unsupported type")
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want interface{}
+		err  error
+	}{
+		{"small integer", []byte{0x17}, int64(23), nil},
+		{"one byte integer", []byte{0x18, 0xff}, int64(255), nil},
+		{"eight byte integer", encodeCBOR(math.MaxInt64), int64(math.MaxInt64), nil},
+		{"negative integer", []byte{0x38, 0x63}, int64(-100), nil},
+		{"COSE algorithm", encodeCBOR(algRS256), int64(algRS256), nil},
+		{"integer too large", []byte{0x1b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, nil, errCBOR},
+		{"byte string", []byte{0x43, 1, 2, 3}, []byte{1, 2, 3}, nil},
+		{"text string", []byte{0x62, 'h', 'i'}, "hi", nil},
+		{"array", encodeCBOR([]interface{}{1, "a"}), []interface{}{int64(1), "a"}, nil},
+		{"map", encodeCBOR(cborMap{{"fmt", "none"}, {-1, 1}}), map[interface{}]interface{}{"fmt": "none", int64(-1): int64(1)}, nil},
+		{"tag", []byte{0xc2, 0x41, 0x01}, []byte{1}, nil},
+		{"booleans and null", []byte{0x83, 0xf4, 0xf5, 0xf6}, []interface{}{false, true, nil}, nil},
+		{"half float", []byte{0xf9, 0x3c, 0x00}, 1.0, nil},
+		{"negative half float", []byte{0xf9, 0xc4, 0x00}, -4.0, nil},
+		{"single float", []byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, 100000.0, nil},
+		{"empty input", nil, nil, errCBOR},
+		{"truncated argument", []byte{0x19, 0x01}, nil, errCBOR},
+		{"truncated byte string", []byte{0x45, 1, 2}, nil, errCBOR},
+		{"truncated map", []byte{0xa2, 0x01, 0x02}, nil, errCBOR},
+		{"indefinite length", []byte{0x5f, 0x41, 0x01, 0xff}, nil, errCBOR},
+		{"reserved argument", []byte{0x1c}, nil, errCBOR},
+		{"array longer than input", []byte{0x9a, 0xff, 0xff, 0xff, 0xff}, nil, errCBOR},
+		{"byte string map key", []byte{0xa1, 0x41, 0x01, 0x01}, nil, errCBOR},
+		{"nested too deep", append(bytes.Repeat([]byte{0x81}, maxCBORDepth+1), 0x01), nil, errCBOR},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, n, err := decodeCBOR(test.data)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("got %#v, want %#v", got, test.want)
+			}
+			if n != len(test.data) {
+				t.Fatalf("consumed %d bytes, want %d", n, len(test.data))
+			}
+		})
+	}
+}
+
+func TestDecodeCBORTrailingData(t *testing.T) {
+	// the COSE key is followed by extensions in authenticator data, so the
+	// decoder must report where the first item ends
+	data := append(encodeCBOR(cborMap{{1, 2}}), 0xa0)
+	_, n, err := decodeCBOR(data)
+	if err != nil || n != len(data)-1 {
+		t.Fatalf("got %d bytes and error %v, want %d", n, err, len(data)-1)
+	}
+}
+
+func TestParsePublicKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  cborMap
+		err  error
+	}{
+		{"unknown algorithm", cborMap{{coseKty, ktyEC2}, {coseAlg, -35}}, ErrUnsupportedKey},
+		{"P-256 point not on curve", cborMap{
+			{coseKty, ktyEC2}, {coseAlg, algES256}, {coseCrv, crvP256},
+			{coseX, bytes.Repeat([]byte{1}, 32)}, {coseY, bytes.Repeat([]byte{2}, 32)},
+		}, ErrUnsupportedKey},
+		{"short Ed25519 key", cborMap{{coseKty, ktyOKP}, {coseAlg, algEdDSA}, {coseCrv, crvEd25519}, {coseX, []byte{1}}}, ErrUnsupportedKey},
+		{"short RSA modulus", cborMap{{coseKty, ktyRSA}, {coseAlg, algRS256}, {coseN, make([]byte, 128)}, {coseE, []byte{1, 0, 1}}}, ErrUnsupportedKey},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := parsePublicKey(encodeCBOR(test.key))
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+		})
+	}
+}
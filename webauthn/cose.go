@@ -0,0 +1,124 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers accepted for credentials, in order of preference.
+const (
+	algES256 = -7
+	algEdDSA = -8
+	algRS256 = -257
+)
+
+// COSE key parameters (RFC 9053).
+const (
+	coseKty = 1
+	coseAlg = 3
+
+	coseCrv = -1 // EC2 and OKP curve
+	coseX   = -2 // EC2 and OKP x coordinate
+	coseY   = -3 // EC2 y coordinate
+	coseN   = -1 // RSA modulus
+	coseE   = -2 // RSA public exponent
+
+	ktyOKP = 1
+	ktyEC2 = 2
+	ktyRSA = 3
+
+	crvP256    = 1
+	crvEd25519 = 6
+)
+
+// publicKey is a credential public key together with its COSE algorithm.
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+/*
+parsePublicKey decodes a COSE_Key as found in the attested credential data.
+
+Returns:
+
+- publicKey: The decoded key.
+
+- int: The number of bytes the key took up.
+
+- error: ErrUnsupportedKey if the key type or algorithm is not supported, or
+an error if the key is malformed.
+*/
+func parsePublicKey(data []byte) (publicKey, int, error) {
+	value, n, err := decodeCBOR(data)
+	if err != nil {
+		return publicKey{}, 0, err
+	}
+	params, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return publicKey{}, 0, errCBOR
+	}
+
+	kty, _ := params[int64(coseKty)].(int64)
+	alg, _ := params[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == ktyEC2 && alg == algES256:
+		crv, _ := params[int64(coseCrv)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		y, _ := params[int64(coseY)].([]byte)
+		if crv != crvP256 || len(x) != 32 || len(y) != 32 {
+			return publicKey{}, 0, fmt.Errorf("%w: invalid P-256 key", ErrUnsupportedKey)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return publicKey{}, 0, fmt.Errorf("%w: point is not on P-256", ErrUnsupportedKey)
+		}
+		return publicKey{alg: alg, key: key}, n, nil
+
+	case kty == ktyOKP && alg == algEdDSA:
+		crv, _ := params[int64(coseCrv)].(int64)
+		x, _ := params[int64(coseX)].([]byte)
+		if crv != crvEd25519 || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, 0, fmt.Errorf("%w: invalid Ed25519 key", ErrUnsupportedKey)
+		}
+		return publicKey{alg: alg, key: ed25519.PublicKey(x)}, n, nil
+
+	case kty == ktyRSA && alg == algRS256:
+		modulus, _ := params[int64(coseN)].([]byte)
+		exponent, _ := params[int64(coseE)].([]byte)
+		if len(modulus) < 256 || len(exponent) == 0 || len(exponent) > 4 {
+			return publicKey{}, 0, fmt.Errorf("%w: invalid RSA key", ErrUnsupportedKey)
+		}
+		e := 0
+		for _, b := range exponent {
+			e = e<<8 | int(b)
+		}
+		return publicKey{alg: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(modulus), E: e}}, n, nil
+
+	default:
+		return publicKey{}, 0, fmt.Errorf("%w: key type %d with algorithm %d", ErrUnsupportedKey, kty, alg)
+	}
+}
+
+// verify checks sig over data with the key's algorithm.
+func (k publicKey) verify(data, sig []byte) bool {
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(key, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig) == nil
+	default:
+		return false
+	}
+}
@@ -0,0 +1,418 @@
+/*
+Package webauthn implements the relying party side of the WebAuthn
+registration and authentication ceremonies for passkeys and security keys.
+
+Only the pieces needed for passwordless login are covered: credentials are
+created with attestation "none" (the attestation statement is not checked
+against any trust anchors) and ES256, EdDSA and RS256 keys are accepted.
+*/
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+const challengeSize = 32
+
+var (
+	ErrChallengeMismatch = errors.New("webauthn: challenge does not match")
+	ErrOriginMismatch    = errors.New("webauthn: origin does not match")
+	ErrRPIDMismatch      = errors.New("webauthn: relying party ID does not match")
+	ErrCeremonyType      = errors.New("webauthn: wrong ceremony type")
+	ErrUserNotVerified   = errors.New("webauthn: user was not verified by the authenticator")
+	ErrUnsupportedKey    = errors.New("webauthn: unsupported credential public key")
+	ErrInvalidSignature  = errors.New("webauthn: invalid signature")
+	ErrSignCount         = errors.New("webauthn: signature counter did not increase, the authenticator may be cloned")
+	ErrCredentialID      = errors.New("webauthn: credential ID does not match")
+	ErrMalformed         = errors.New("webauthn: malformed response")
+)
+
+/*
+Base64URL is a byte slice that is encoded in JSON as unpadded base64url, the
+form browsers use for binary WebAuthn fields once converted from
+ArrayBuffers.
+*/
+type Base64URL []byte
+
+func (b Base64URL) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+func (b *Base64URL) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// String returns the unpadded base64url form, used to store credential IDs.
+func (b Base64URL) String() string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+/*
+Config describes the relying party, the site credentials are bound to. RPID
+is the registrable domain (e.g. example.com, or localhost in development)
+and Origin is the exact scheme, host and port pages are served from.
+*/
+type Config struct {
+	RPID   string
+	RPName string
+	Origin string
+}
+
+/*
+Credential is a registered public key credential. PublicKey holds the raw
+COSE_Key and SignCount the last signature counter seen from the
+authenticator.
+*/
+type Credential struct {
+	ID         Base64URL
+	PublicKey  []byte
+	SignCount  uint32
+	Transports []string
+	UserHandle []byte
+}
+
+// NewChallenge returns a random challenge for a single ceremony.
+func NewChallenge() []byte {
+	challenge := make([]byte, challengeSize)
+	if _, err := rand.Read(challenge); err != nil {
+		panic("could not generate WebAuthn challenge: " + err.Error())
+	}
+	return challenge
+}
+
+type relyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type userEntity struct {
+	ID          Base64URL `json:"id"`
+	Name        string    `json:"name"`
+	DisplayName string    `json:"displayName"`
+}
+
+type credentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type credentialDescriptor struct {
+	Type       string    `json:"type"`
+	ID         Base64URL `json:"id"`
+	Transports []string  `json:"transports,omitempty"`
+}
+
+type authenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the JSON form of PublicKeyCredentialCreationOptions.
+type CreationOptions struct {
+	Challenge              Base64URL              `json:"challenge"`
+	RP                     relyingParty           `json:"rp"`
+	User                   userEntity             `json:"user"`
+	PubKeyCredParams       []credentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int                    `json:"timeout"`
+	ExcludeCredentials     []credentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection authenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the JSON form of PublicKeyCredentialRequestOptions.
+type RequestOptions struct {
+	Challenge        Base64URL              `json:"challenge"`
+	RPID             string                 `json:"rpId"`
+	Timeout          int                    `json:"timeout"`
+	AllowCredentials []credentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+func descriptors(credentials []Credential) []credentialDescriptor {
+	list := make([]credentialDescriptor, 0, len(credentials))
+	for _, cred := range credentials {
+		list = append(list, credentialDescriptor{Type: "public-key", ID: cred.ID, Transports: cred.Transports})
+	}
+	return list
+}
+
+/*
+CreationOptions returns the options to pass to navigator.credentials.create
+to register a new passkey for the user. Credentials the user already has are
+excluded so the same authenticator is not registered twice, and the passkey
+must be discoverable so it can log in without a username.
+*/
+func (c Config) CreationOptions(challenge, userHandle []byte, username string, existing []Credential) CreationOptions {
+	return CreationOptions{
+		Challenge: challenge,
+		RP:        relyingParty{ID: c.RPID, Name: c.RPName},
+		User:      userEntity{ID: userHandle, Name: username, DisplayName: username},
+		PubKeyCredParams: []credentialParameter{
+			{Type: "public-key", Alg: algES256},
+			{Type: "public-key", Alg: algEdDSA},
+			{Type: "public-key", Alg: algRS256},
+		},
+		Timeout:            60000,
+		ExcludeCredentials: descriptors(existing),
+		AuthenticatorSelection: authenticatorSelection{
+			ResidentKey:      "required",
+			UserVerification: "required",
+		},
+		Attestation: "none",
+	}
+}
+
+/*
+RequestOptions returns the options to pass to navigator.credentials.get. With
+no allowed credentials the browser offers any discoverable passkey for the
+site, so the user does not have to type a username.
+*/
+func (c Config) RequestOptions(challenge []byte, allowed []Credential) RequestOptions {
+	return RequestOptions{
+		Challenge:        challenge,
+		RPID:             c.RPID,
+		Timeout:          60000,
+		AllowCredentials: descriptors(allowed),
+		UserVerification: "required",
+	}
+}
+
+// RegistrationResponse is the JSON the browser posts after
+// navigator.credentials.create.
+type RegistrationResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AttestationObject Base64URL `json:"attestationObject"`
+		Transports        []string  `json:"transports"`
+	} `json:"response"`
+}
+
+// AssertionResponse is the JSON the browser posts after
+// navigator.credentials.get.
+type AssertionResponse struct {
+	ID       string    `json:"id"`
+	RawID    Base64URL `json:"rawId"`
+	Type     string    `json:"type"`
+	Response struct {
+		ClientDataJSON    Base64URL `json:"clientDataJSON"`
+		AuthenticatorData Base64URL `json:"authenticatorData"`
+		Signature         Base64URL `json:"signature"`
+		UserHandle        Base64URL `json:"userHandle"`
+	} `json:"response"`
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// verifyClientData checks the ceremony type, challenge and origin the
+// browser signed over.
+func (c Config) verifyClientData(raw []byte, ceremony string, challenge []byte) error {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return fmt.Errorf("%w: client data: %s", ErrMalformed, err.Error())
+	}
+	if data.Type != ceremony {
+		return ErrCeremonyType
+	}
+	received, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(data.Challenge, "="))
+	if err != nil || subtle.ConstantTimeCompare(received, challenge) != 1 {
+		return ErrChallengeMismatch
+	}
+	if data.Origin != c.Origin {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+// authenticatorData is the parsed form of the authenticator data structure.
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+}
+
+func parseAuthenticatorData(raw []byte) (authenticatorData, error) {
+	if len(raw) < 37 {
+		return authenticatorData{}, fmt.Errorf("%w: authenticator data too short", ErrMalformed)
+	}
+	data := authenticatorData{
+		rpIDHash:  raw[:32],
+		flags:     raw[32],
+		signCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	if data.flags&flagAttestedData == 0 {
+		return data, nil
+	}
+
+	// attested credential data: AAGUID, credential ID length, ID, COSE key
+	rest := raw[37:]
+	if len(rest) < 18 {
+		return authenticatorData{}, fmt.Errorf("%w: attested credential data too short", ErrMalformed)
+	}
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if len(rest) < idLength {
+		return authenticatorData{}, fmt.Errorf("%w: credential ID truncated", ErrMalformed)
+	}
+	data.credentialID = rest[:idLength]
+	rest = rest[idLength:]
+
+	_, keyLength, err := parsePublicKey(rest)
+	if err != nil {
+		return authenticatorData{}, err
+	}
+	data.publicKey = rest[:keyLength]
+	return data, nil
+}
+
+// verifyFlags checks the RP ID hash and that the user was present and
+// verified.
+func (c Config) verifyFlags(data authenticatorData) error {
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(data.rpIDHash, rpIDHash[:]) {
+		return ErrRPIDMismatch
+	}
+	if data.flags&flagUserPresent == 0 || data.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+	return nil
+}
+
+/*
+VerifyRegistration checks the browser's response to CreationOptions issued
+with the given challenge and returns the new credential to store.
+
+Returns:
+
+- Credential: The registered credential. UserHandle is left for the caller
+to fill in.
+
+- error: An error describing why the response was rejected.
+*/
+func (c Config) VerifyRegistration(challenge []byte, resp RegistrationResponse) (Credential, error) {
+	if resp.Type != "public-key" {
+		return Credential{}, fmt.Errorf("%w: credential type %q", ErrMalformed, resp.Type)
+	}
+	err := c.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge)
+	if err != nil {
+		return Credential{}, err
+	}
+
+	value, _, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return Credential{}, err
+	}
+	attestation, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: attestation object", ErrMalformed)
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return Credential{}, fmt.Errorf("%w: attestation object has no authData", ErrMalformed)
+	}
+
+	data, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return Credential{}, err
+	}
+	if err := c.verifyFlags(data); err != nil {
+		return Credential{}, err
+	}
+	if data.credentialID == nil {
+		return Credential{}, fmt.Errorf("%w: no attested credential data", ErrMalformed)
+	}
+	if !bytes.Equal(data.credentialID, resp.RawID) {
+		return Credential{}, ErrCredentialID
+	}
+
+	return Credential{
+		ID:         append(Base64URL(nil), data.credentialID...),
+		PublicKey:  append([]byte(nil), data.publicKey...),
+		SignCount:  data.signCount,
+		Transports: resp.Response.Transports,
+	}, nil
+}
+
+/*
+VerifyAssertion checks the browser's response to RequestOptions issued with
+the given challenge against the stored credential it claims to come from.
+
+Returns:
+
+- uint32: The new signature counter to store for the credential.
+
+- error: An error describing why the response was rejected.
+*/
+func (c Config) VerifyAssertion(challenge []byte, cred Credential, resp AssertionResponse) (uint32, error) {
+	if resp.Type != "public-key" {
+		return 0, fmt.Errorf("%w: credential type %q", ErrMalformed, resp.Type)
+	}
+	if !bytes.Equal(resp.RawID, cred.ID) {
+		return 0, ErrCredentialID
+	}
+	if len(resp.Response.UserHandle) > 0 && len(cred.UserHandle) > 0 &&
+		!bytes.Equal(resp.Response.UserHandle, cred.UserHandle) {
+		return 0, ErrCredentialID
+	}
+	err := c.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := parseAuthenticatorData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	if err := c.verifyFlags(data); err != nil {
+		return 0, err
+	}
+
+	key, _, err := parsePublicKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if !key.verify(signed, resp.Response.Signature) {
+		return 0, ErrInvalidSignature
+	}
+
+	// authenticators that keep no counter always report zero
+	if (data.signCount != 0 || cred.SignCount != 0) && data.signCount <= cred.SignCount {
+		return 0, ErrSignCount
+	}
+	return data.signCount, nil
+}
@@ -0,0 +1,301 @@
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+var testConfig = Config{RPID: "example.com", RPName: "Example", Origin: "https://example.com"}
+
+// softAuthenticator is a software authenticator holding a single credential,
+// producing the responses a browser would post for it.
+type softAuthenticator struct {
+	alg          int
+	key          crypto.Signer
+	credentialID []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T, alg int) *softAuthenticator {
+	t.Helper()
+	var key crypto.Signer
+	var err error
+	switch alg {
+	case algES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case algEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case algRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		t.Fatalf("unsupported algorithm %d", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{alg: alg, key: key, credentialID: NewChallenge()[:16]}
+}
+
+// coseKey encodes the authenticator's public key as a COSE_Key.
+func (a *softAuthenticator) coseKey() []byte {
+	switch key := a.key.Public().(type) {
+	case *ecdsa.PublicKey:
+		return encodeCBOR(cborMap{
+			{coseKty, ktyEC2}, {coseAlg, algES256}, {coseCrv, crvP256},
+			{coseX, key.X.FillBytes(make([]byte, 32))}, {coseY, key.Y.FillBytes(make([]byte, 32))},
+		})
+	case ed25519.PublicKey:
+		return encodeCBOR(cborMap{{coseKty, ktyOKP}, {coseAlg, algEdDSA}, {coseCrv, crvEd25519}, {coseX, []byte(key)}})
+	case *rsa.PublicKey:
+		e := binary.BigEndian.AppendUint32(nil, uint32(key.E))
+		return encodeCBOR(cborMap{{coseKty, ktyRSA}, {coseAlg, algRS256}, {coseN, key.N.Bytes()}, {coseE, bytes.TrimLeft(e, "\x00")}})
+	}
+	return nil
+}
+
+// sign signs data the way the authenticator's algorithm requires.
+func (a *softAuthenticator) sign(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var sig []byte
+	var err error
+	switch a.alg {
+	case algEdDSA:
+		sig, err = a.key.Sign(rand.Reader, data, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(data)
+		sig, err = a.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+// authenticatorData builds authenticator data for the RP ID, including the
+// attested credential data when attested is set.
+func (a *softAuthenticator) authenticatorData(rpID string, flags byte, attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	if attested {
+		data = append(data, make([]byte, 16)...) // AAGUID
+		data = binary.BigEndian.AppendUint16(data, uint16(len(a.credentialID)))
+		data = append(data, a.credentialID...)
+		data = append(data, a.coseKey()...)
+	}
+	return data
+}
+
+// ceremony describes what the authenticator and browser put in a response,
+// so tests can change one thing at a time.
+type ceremony struct {
+	kind      string
+	challenge []byte
+	origin    string
+	rpID      string
+	flags     byte
+}
+
+func validCeremony(kind string, challenge []byte) ceremony {
+	return ceremony{
+		kind:      kind,
+		challenge: challenge,
+		origin:    testConfig.Origin,
+		rpID:      testConfig.RPID,
+		flags:     flagUserPresent | flagUserVerified,
+	}
+}
+
+func (c ceremony) clientDataJSON() []byte {
+	data, _ := json.Marshal(clientData{
+		Type:      c.kind,
+		Challenge: base64.RawURLEncoding.EncodeToString(c.challenge),
+		Origin:    c.origin,
+	})
+	return data
+}
+
+func (a *softAuthenticator) register(c ceremony) RegistrationResponse {
+	var resp RegistrationResponse
+	resp.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	resp.RawID = a.credentialID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = c.clientDataJSON()
+	resp.Response.AttestationObject = encodeCBOR(cborMap{
+		{"fmt", "none"},
+		{"attStmt", cborMap{}},
+		{"authData", a.authenticatorData(c.rpID, c.flags|flagAttestedData, true)},
+	})
+	return resp
+}
+
+func (a *softAuthenticator) assert(t *testing.T, c ceremony) AssertionResponse {
+	t.Helper()
+	a.signCount++
+	var resp AssertionResponse
+	resp.ID = base64.RawURLEncoding.EncodeToString(a.credentialID)
+	resp.RawID = a.credentialID
+	resp.Type = "public-key"
+	resp.Response.ClientDataJSON = c.clientDataJSON()
+	resp.Response.AuthenticatorData = a.authenticatorData(c.rpID, c.flags, false)
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	resp.Response.Signature = a.sign(t, append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...))
+	return resp
+}
+
+var algorithms = []struct {
+	name string
+	alg  int
+}{
+	{"ES256", algES256},
+	{"EdDSA", algEdDSA},
+	{"RS256", algRS256},
+}
+
+func TestVerifyRegistration(t *testing.T) {
+	tests := []struct {
+		name   string
+		before func(c *ceremony)                // changes what the authenticator signs
+		after  func(resp *RegistrationResponse) // changes the response the browser posts
+		want   error
+	}{
+		{name: "valid"},
+		{name: "wrong origin", before: func(c *ceremony) { c.origin = "https://evil.example" }, want: ErrOriginMismatch},
+		{name: "wrong RP ID hash", before: func(c *ceremony) { c.rpID = "evil.example" }, want: ErrRPIDMismatch},
+		{name: "user not verified", before: func(c *ceremony) { c.flags = flagUserPresent }, want: ErrUserNotVerified},
+		{name: "wrong challenge", before: func(c *ceremony) { c.challenge = NewChallenge() }, want: ErrChallengeMismatch},
+		{name: "wrong ceremony", before: func(c *ceremony) { c.kind = "webauthn.get" }, want: ErrCeremonyType},
+		{name: "credential ID mismatch", after: func(resp *RegistrationResponse) { resp.RawID = []byte("another-id") }, want: ErrCredentialID},
+		{name: "truncated attestation", after: func(resp *RegistrationResponse) {
+			resp.Response.AttestationObject = resp.Response.AttestationObject[:len(resp.Response.AttestationObject)-1]
+		}, want: errCBOR},
+	}
+
+	for _, algorithm := range algorithms {
+		authenticator := newSoftAuthenticator(t, algorithm.alg)
+		for _, test := range tests {
+			t.Run(algorithm.name+"/"+test.name, func(t *testing.T) {
+				challenge := NewChallenge()
+				c := validCeremony("webauthn.create", challenge)
+				if test.before != nil {
+					test.before(&c)
+				}
+				resp := authenticator.register(c)
+				if test.after != nil {
+					test.after(&resp)
+				}
+
+				cred, err := testConfig.VerifyRegistration(challenge, resp)
+				if !errors.Is(err, test.want) {
+					t.Fatalf("got error %v, want %v", err, test.want)
+				}
+				if err != nil {
+					return
+				}
+				if !bytes.Equal(cred.ID, authenticator.credentialID) || !bytes.Equal(cred.PublicKey, authenticator.coseKey()) {
+					t.Fatal("registered credential does not match the authenticator")
+				}
+				if _, _, err := parsePublicKey(cred.PublicKey); err != nil {
+					t.Fatalf("registered public key does not parse: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyAssertion(t *testing.T) {
+	tests := []struct {
+		name   string
+		before func(c *ceremony, stored *Credential) // changes what is signed or stored
+		after  func(resp *AssertionResponse)         // changes the response the browser posts
+		want   error
+	}{
+		{name: "valid"},
+		{name: "bad signature", after: func(resp *AssertionResponse) {
+			resp.Response.Signature[len(resp.Response.Signature)/2] ^= 0xff
+		}, want: ErrInvalidSignature},
+		{name: "changed authenticator data", after: func(resp *AssertionResponse) {
+			resp.Response.AuthenticatorData[36]++
+		}, want: ErrInvalidSignature},
+		{name: "changed client data", after: func(resp *AssertionResponse) {
+			resp.Response.ClientDataJSON = append(resp.Response.ClientDataJSON[:len(resp.Response.ClientDataJSON)-1], ' ', '}')
+		}, want: ErrInvalidSignature},
+		{name: "stored key of another credential", before: func(c *ceremony, stored *Credential) {
+			stored.PublicKey = encodeCBOR(cborMap{{coseKty, ktyOKP}, {coseAlg, algEdDSA}, {coseCrv, crvEd25519}, {coseX, make([]byte, 32)}})
+		}, want: ErrInvalidSignature},
+		{name: "user not verified", before: func(c *ceremony, stored *Credential) { c.flags = flagUserPresent }, want: ErrUserNotVerified},
+		{name: "user not present", before: func(c *ceremony, stored *Credential) { c.flags = flagUserVerified }, want: ErrUserNotVerified},
+		{name: "sign count regressed", before: func(c *ceremony, stored *Credential) { stored.SignCount += 100 }, want: ErrSignCount},
+		{name: "sign count repeated", before: func(c *ceremony, stored *Credential) { stored.SignCount++ }, want: ErrSignCount},
+		{name: "wrong origin", before: func(c *ceremony, stored *Credential) { c.origin = "https://example.com.evil.example" }, want: ErrOriginMismatch},
+		{name: "wrong RP ID hash", before: func(c *ceremony, stored *Credential) { c.rpID = "evil.example" }, want: ErrRPIDMismatch},
+		{name: "wrong challenge", before: func(c *ceremony, stored *Credential) { c.challenge = NewChallenge() }, want: ErrChallengeMismatch},
+		{name: "wrong ceremony", before: func(c *ceremony, stored *Credential) { c.kind = "webauthn.create" }, want: ErrCeremonyType},
+		{name: "other credential", before: func(c *ceremony, stored *Credential) { stored.ID = []byte("another-id") }, want: ErrCredentialID},
+		{name: "other user handle", after: func(resp *AssertionResponse) {
+			resp.Response.UserHandle = []byte("someone-else")
+		}, want: ErrCredentialID},
+	}
+
+	for _, algorithm := range algorithms {
+		authenticator := newSoftAuthenticator(t, algorithm.alg)
+		for _, test := range tests {
+			t.Run(algorithm.name+"/"+test.name, func(t *testing.T) {
+				stored := Credential{
+					ID:         authenticator.credentialID,
+					PublicKey:  authenticator.coseKey(),
+					SignCount:  authenticator.signCount,
+					UserHandle: []byte("user-handle"),
+				}
+				challenge := NewChallenge()
+				c := validCeremony("webauthn.get", challenge)
+				if test.before != nil {
+					test.before(&c, &stored)
+				}
+				resp := authenticator.assert(t, c)
+				resp.Response.UserHandle = []byte("user-handle")
+				if test.after != nil {
+					test.after(&resp)
+				}
+
+				signCount, err := testConfig.VerifyAssertion(challenge, stored, resp)
+				if !errors.Is(err, test.want) {
+					t.Fatalf("got error %v, want %v", err, test.want)
+				}
+				if err == nil && signCount != authenticator.signCount {
+					t.Fatalf("got sign count %d, want %d", signCount, authenticator.signCount)
+				}
+			})
+		}
+	}
+}
+
+func TestVerifyAssertionZeroSignCount(t *testing.T) {
+	// authenticators without a counter always report zero, which must not
+	// be mistaken for a cloned authenticator
+	authenticator := newSoftAuthenticator(t, algES256)
+	stored := Credential{ID: authenticator.credentialID, PublicKey: authenticator.coseKey()}
+	for i := 0; i < 2; i++ {
+		challenge := NewChallenge()
+		resp := authenticator.assert(t, validCeremony("webauthn.get", challenge))
+		binary.BigEndian.PutUint32(resp.Response.AuthenticatorData[33:37], 0)
+		clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+		resp.Response.Signature = authenticator.sign(t, append(append([]byte(nil), resp.Response.AuthenticatorData...), clientDataHash[:]...))
+
+		signCount, err := testConfig.VerifyAssertion(challenge, stored, resp)
+		if err != nil || signCount != 0 {
+			t.Fatalf("assertion %d: got sign count %d and error %v", i, signCount, err)
+		}
+	}
+}